@@ -0,0 +1,604 @@
+package prettyx
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"io"
+	"math"
+	"math/big"
+	"strconv"
+	"time"
+)
+
+// maxBinaryDepth bounds the nesting of CBOR and MessagePack input, whose
+// decoders recurse, the same way encoding/json bounds JSON.
+const maxBinaryDepth = 10000
+
+// binaryStream converts a sequence of CBOR or MessagePack items to JSON
+// documents, one per top-level item, as described on ConvertReader. Values
+// are written straight to the output without building a tree.
+type binaryStream struct {
+	r       *bufio.Reader
+	cbor    bool
+	off     int64
+	start   int64
+	doc     int
+	scratch []byte
+	// enc is the byte string encoding requested by an enclosing CBOR tag:
+	// 0 for base64, 21 for base64url and 23 for hex.
+	enc uint64
+}
+
+func newBinaryStream(r io.Reader, cbor bool) *binaryStream {
+	return &binaryStream{r: bufio.NewReader(r), cbor: cbor}
+}
+
+func (s *binaryStream) next(dst []byte) ([]byte, error) {
+	if _, err := s.r.Peek(1); err != nil {
+		return dst, err
+	}
+	s.doc++
+	var err error
+	if s.cbor {
+		dst, err = s.cborValue(dst, 0)
+	} else {
+		dst, err = s.msgpackValue(dst, 0)
+	}
+	if err != nil {
+		return dst, err
+	}
+	return append(dst, '\n'), nil
+}
+
+func (s *binaryStream) errorf(msg string) error {
+	return &SyntaxError{Msg: msg, Offset: s.start, Document: s.doc}
+}
+
+// ioError turns an early end of input into a SyntaxError.
+func (s *binaryStream) ioError(err error) error {
+	if err == io.EOF || errors.Is(err, io.ErrUnexpectedEOF) {
+		return &SyntaxError{Msg: "unexpected end of input", Offset: s.off, Document: s.doc}
+	}
+	return err
+}
+
+func (s *binaryStream) readByte() (byte, error) {
+	b, err := s.r.ReadByte()
+	if err != nil {
+		return 0, s.ioError(err)
+	}
+	s.off++
+	return b, nil
+}
+
+func (s *binaryStream) peekByte() (byte, error) {
+	b, err := s.r.Peek(1)
+	if err != nil {
+		return 0, s.ioError(err)
+	}
+	return b[0], nil
+}
+
+// readUint reads a big-endian unsigned integer of size bytes.
+func (s *binaryStream) readUint(size int) (uint64, error) {
+	var buf [8]byte
+	if _, err := io.ReadFull(s.r, buf[8-size:]); err != nil {
+		return 0, s.ioError(err)
+	}
+	s.off += int64(size)
+	return binary.BigEndian.Uint64(buf[:]), nil
+}
+
+// readBytes reads n bytes into the scratch buffer, growing it as data
+// arrives so a corrupt length cannot allocate more than the input holds.
+func (s *binaryStream) readBytes(n uint64) ([]byte, error) {
+	s.scratch = s.scratch[:0]
+	for n > 0 {
+		chunk := int(min(n, 64<<10))
+		at := len(s.scratch)
+		s.scratch = append(s.scratch, make([]byte, chunk)...)
+		if _, err := io.ReadFull(s.r, s.scratch[at:]); err != nil {
+			return nil, s.ioError(err)
+		}
+		s.off += int64(chunk)
+		n -= uint64(chunk)
+	}
+	return s.scratch, nil
+}
+
+func (s *binaryStream) appendBytes(dst, b []byte) []byte {
+	dst = append(dst, '"')
+	switch s.enc {
+	case 21:
+		dst = base64.RawURLEncoding.AppendEncode(dst, b)
+	case 23:
+		dst = hex.AppendEncode(dst, b)
+	default:
+		dst = base64.StdEncoding.AppendEncode(dst, b)
+	}
+	return append(dst, '"')
+}
+
+func appendBinaryFloat(dst []byte, f float64, bits int) []byte {
+	switch {
+	case math.IsNaN(f):
+		return append(dst, "null"...)
+	case math.IsInf(f, 1):
+		return append(dst, maxFloat64Text...)
+	case math.IsInf(f, -1):
+		return append(append(dst, '-'), maxFloat64Text...)
+	}
+	return strconv.AppendFloat(dst, f, 'g', -1, bits)
+}
+
+// appendKey appends a map key decoded by value. Keys that convert to JSON
+// strings are used as they are; any other key becomes the string of its
+// JSON form.
+func (s *binaryStream) appendKey(dst []byte, depth int, value func([]byte, int) ([]byte, error)) ([]byte, error) {
+	b, err := s.peekByte()
+	if err != nil {
+		return dst, err
+	}
+	if (s.cbor && b>>5 == 3) || (!s.cbor && (b>>5 == 5 || (b >= 0xd9 && b <= 0xdb))) {
+		return value(dst, depth)
+	}
+	key, err := value(nil, depth)
+	if err != nil || key[0] == '"' {
+		return append(dst, key...), err
+	}
+	return append(dst, appendQuotedBytes(nil, key)...), nil
+}
+
+// cborHead reads the initial byte and argument of a CBOR item. indefinite
+// is set for additional information 31.
+func (s *binaryStream) cborHead() (major byte, info byte, arg uint64, indefinite bool, err error) {
+	s.start = s.off
+	b, err := s.readByte()
+	if err != nil {
+		return 0, 0, 0, false, err
+	}
+	major, info = b>>5, b&0x1f
+	switch {
+	case info < 24:
+		arg = uint64(info)
+	case info <= 27:
+		arg, err = s.readUint(1 << (info - 24))
+	case info == 31:
+		indefinite = true
+	default:
+		err = s.errorf("invalid additional information " + strconv.Itoa(int(info)))
+	}
+	return major, info, arg, indefinite, err
+}
+
+// cborBreak consumes the break code ending an indefinite-length item if it
+// is next.
+func (s *binaryStream) cborBreak() (bool, error) {
+	b, err := s.peekByte()
+	if err != nil || b != 0xff {
+		return false, err
+	}
+	s.r.ReadByte()
+	s.off++
+	return true, nil
+}
+
+func (s *binaryStream) cborValue(dst []byte, depth int) ([]byte, error) {
+	if depth > maxBinaryDepth {
+		return dst, s.errorf("exceeded max depth")
+	}
+	major, info, arg, indefinite, err := s.cborHead()
+	if err != nil {
+		return dst, err
+	}
+	if indefinite && (major < 2 || major == 6) {
+		return dst, s.errorf("invalid indefinite length")
+	}
+	switch major {
+	case 0:
+		return strconv.AppendUint(dst, arg, 10), nil
+	case 1:
+		if arg == math.MaxUint64 {
+			return append(dst, "-18446744073709551616"...), nil
+		}
+		return strconv.AppendUint(append(dst, '-'), arg+1, 10), nil
+	case 2, 3:
+		b, err := s.cborString(major, arg, indefinite)
+		if err != nil {
+			return dst, err
+		}
+		if major == 2 {
+			return s.appendBytes(dst, b), nil
+		}
+		return append(dst, appendQuotedBytes(nil, b)...), nil
+	case 4:
+		dst = append(dst, '[')
+		for i := uint64(0); indefinite || i < arg; i++ {
+			if indefinite {
+				if done, err := s.cborBreak(); err != nil || done {
+					return append(dst, ']'), err
+				}
+			}
+			if i > 0 {
+				dst = append(dst, ',')
+			}
+			if dst, err = s.cborValue(dst, depth+1); err != nil {
+				return dst, err
+			}
+		}
+		return append(dst, ']'), nil
+	case 5:
+		dst = append(dst, '{')
+		for i := uint64(0); indefinite || i < arg; i++ {
+			if indefinite {
+				if done, err := s.cborBreak(); err != nil || done {
+					return append(dst, '}'), err
+				}
+			}
+			if i > 0 {
+				dst = append(dst, ',')
+			}
+			if dst, err = s.appendKey(dst, depth+1, s.cborValue); err != nil {
+				return dst, err
+			}
+			dst = append(dst, ':')
+			if dst, err = s.cborValue(dst, depth+1); err != nil {
+				return dst, err
+			}
+		}
+		return append(dst, '}'), nil
+	case 6:
+		return s.cborTag(dst, arg, depth)
+	default:
+		return s.cborSimple(dst, info, arg, indefinite)
+	}
+}
+
+// cborString reads a byte or text string, joining the chunks of an
+// indefinite-length one.
+func (s *binaryStream) cborString(major byte, n uint64, indefinite bool) ([]byte, error) {
+	if !indefinite {
+		return s.readBytes(n)
+	}
+	var joined []byte
+	for {
+		if done, err := s.cborBreak(); err != nil || done {
+			return joined, err
+		}
+		chunkMajor, _, n, chunkIndefinite, err := s.cborHead()
+		if err != nil {
+			return nil, err
+		}
+		if chunkMajor != major || chunkIndefinite {
+			return nil, s.errorf("invalid chunk in indefinite-length string")
+		}
+		chunk, err := s.readBytes(n)
+		if err != nil {
+			return nil, err
+		}
+		joined = append(joined, chunk...)
+	}
+}
+
+func (s *binaryStream) cborTag(dst []byte, tag uint64, depth int) ([]byte, error) {
+	switch tag {
+	case 2, 3:
+		start := s.off
+		major, _, n, indefinite, err := s.cborHead()
+		if err != nil {
+			return dst, err
+		}
+		if major != 2 {
+			s.start = start
+			return dst, s.errorf("bignum tag without byte string")
+		}
+		b, err := s.cborString(2, n, indefinite)
+		if err != nil {
+			return dst, err
+		}
+		var v big.Int
+		v.SetBytes(b)
+		if tag == 3 {
+			v.Neg(&v)
+			v.Sub(&v, big.NewInt(1))
+		}
+		return v.Append(dst, 10), nil
+	case 4:
+		return s.cborDecimal(dst, depth)
+	case 21, 22, 23:
+		saved := s.enc
+		s.enc = tag
+		if tag == 22 {
+			s.enc = 0
+		}
+		dst, err := s.cborValue(dst, depth+1)
+		s.enc = saved
+		return dst, err
+	case 0, 1, 32, 33, 34, 35, 36, 55799:
+		// Date/time, URI, base64 text, regexp, MIME and self-describe
+		// tags wrap a value that reads the same way in JSON.
+		return s.cborValue(dst, depth+1)
+	default:
+		dst = append(dst, `{"tag":`...)
+		dst = strconv.AppendUint(dst, tag, 10)
+		dst = append(dst, `,"value":`...)
+		dst, err := s.cborValue(dst, depth+1)
+		if err != nil {
+			return dst, err
+		}
+		return append(dst, '}'), nil
+	}
+}
+
+// cborDecimal reads the [exponent, mantissa] array of a decimal fraction
+// and writes the number it stands for exactly.
+func (s *binaryStream) cborDecimal(dst []byte, depth int) ([]byte, error) {
+	start := s.off
+	major, _, n, indefinite, err := s.cborHead()
+	if err != nil {
+		return dst, err
+	}
+	if major != 4 || indefinite || n != 2 {
+		s.start = start
+		return dst, s.errorf("decimal fraction without exponent and mantissa")
+	}
+	start = s.off
+	e, err := s.cborValue(nil, depth+1)
+	if err != nil {
+		return dst, err
+	}
+	exp, err := strconv.Atoi(string(e))
+	if err != nil || exp < -maxDecimalExponent || exp > maxDecimalExponent {
+		s.start = start
+		return dst, s.errorf("invalid decimal fraction exponent")
+	}
+	start = s.off
+	mant, err := s.cborValue(nil, depth+1)
+	if err != nil {
+		return dst, err
+	}
+	if _, ok := new(big.Int).SetString(string(mant), 10); !ok {
+		s.start = start
+		return dst, s.errorf("invalid decimal fraction mantissa")
+	}
+	return appendDecimal(dst, mant, exp), nil
+}
+
+// maxDecimalExponent bounds decimal fraction exponents to what JSON number
+// parsers commonly accept.
+const maxDecimalExponent = 1 << 30
+
+// appendDecimal appends mant*10^exp as a JSON number, in positional notation
+// when that takes only a few extra zeros and with an exponent otherwise.
+func appendDecimal(dst, mant []byte, exp int) []byte {
+	if mant[0] == '-' {
+		dst = append(dst, '-')
+		mant = mant[1:]
+	}
+	switch k := len(mant); {
+	case exp == 0 || string(mant) == "0":
+		return append(dst, mant...)
+	case exp > 0 || -exp-k > 6:
+		dst = append(dst, mant...)
+		dst = append(dst, 'e')
+		return strconv.AppendInt(dst, int64(exp), 10)
+	case -exp < k:
+		dst = append(dst, mant[:k+exp]...)
+		dst = append(dst, '.')
+		return append(dst, mant[k+exp:]...)
+	default:
+		dst = append(dst, "0."...)
+		for i := 0; i < -exp-k; i++ {
+			dst = append(dst, '0')
+		}
+		return append(dst, mant...)
+	}
+}
+
+func (s *binaryStream) cborSimple(dst []byte, info byte, arg uint64, indefinite bool) ([]byte, error) {
+	switch {
+	case indefinite:
+		return dst, s.errorf("unexpected break")
+	case info == 20:
+		return append(dst, "false"...), nil
+	case info == 21:
+		return append(dst, "true"...), nil
+	case info == 22, info == 23:
+		return append(dst, "null"...), nil
+	case info == 25:
+		return appendBinaryFloat(dst, halfToFloat(uint16(arg)), 32), nil
+	case info == 26:
+		return appendBinaryFloat(dst, float64(math.Float32frombits(uint32(arg))), 32), nil
+	case info == 27:
+		return appendBinaryFloat(dst, math.Float64frombits(arg), 64), nil
+	default:
+		// Unassigned simple values have no JSON counterpart; keep the number.
+		return strconv.AppendUint(dst, arg, 10), nil
+	}
+}
+
+// halfToFloat converts an IEEE 754 half-precision float.
+func halfToFloat(h uint16) float64 {
+	exp := int(h>>10) & 0x1f
+	mant := float64(h & 0x3ff)
+	var f float64
+	switch exp {
+	case 0:
+		f = math.Ldexp(mant, -24)
+	case 0x1f:
+		if mant == 0 {
+			f = math.Inf(1)
+		} else {
+			f = math.NaN()
+		}
+	default:
+		f = math.Ldexp(mant+1024, exp-25)
+	}
+	if h&0x8000 != 0 {
+		f = -f
+	}
+	return f
+}
+
+func (s *binaryStream) msgpackValue(dst []byte, depth int) ([]byte, error) {
+	if depth > maxBinaryDepth {
+		return dst, s.errorf("exceeded max depth")
+	}
+	s.start = s.off
+	b, err := s.readByte()
+	if err != nil {
+		return dst, err
+	}
+	switch {
+	case b <= 0x7f:
+		return strconv.AppendUint(dst, uint64(b), 10), nil
+	case b <= 0x8f:
+		return s.msgpackMap(dst, uint64(b&0x0f), depth)
+	case b <= 0x9f:
+		return s.msgpackArray(dst, uint64(b&0x0f), depth)
+	case b <= 0xbf:
+		return s.msgpackString(dst, uint64(b&0x1f))
+	case b >= 0xe0:
+		return strconv.AppendInt(dst, int64(int8(b)), 10), nil
+	}
+	switch b {
+	case 0xc0:
+		return append(dst, "null"...), nil
+	case 0xc2:
+		return append(dst, "false"...), nil
+	case 0xc3:
+		return append(dst, "true"...), nil
+	case 0xc4, 0xc5, 0xc6:
+		n, err := s.readUint(1 << (b - 0xc4))
+		if err != nil {
+			return dst, err
+		}
+		data, err := s.readBytes(n)
+		if err != nil {
+			return dst, err
+		}
+		return s.appendBytes(dst, data), nil
+	case 0xc7, 0xc8, 0xc9:
+		n, err := s.readUint(1 << (b - 0xc7))
+		if err != nil {
+			return dst, err
+		}
+		return s.msgpackExt(dst, n)
+	case 0xca:
+		n, err := s.readUint(4)
+		return appendBinaryFloat(dst, float64(math.Float32frombits(uint32(n))), 32), err
+	case 0xcb:
+		n, err := s.readUint(8)
+		return appendBinaryFloat(dst, math.Float64frombits(n), 64), err
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		n, err := s.readUint(1 << (b - 0xcc))
+		return strconv.AppendUint(dst, n, 10), err
+	case 0xd0, 0xd1, 0xd2, 0xd3:
+		size := 1 << (b - 0xd0)
+		n, err := s.readUint(size)
+		// Sign-extend from the encoded width.
+		v := int64(n<<(64-8*size)) >> (64 - 8*size)
+		return strconv.AppendInt(dst, v, 10), err
+	case 0xd4, 0xd5, 0xd6, 0xd7, 0xd8:
+		return s.msgpackExt(dst, 1<<(b-0xd4))
+	case 0xd9, 0xda, 0xdb:
+		n, err := s.readUint(1 << (b - 0xd9))
+		if err != nil {
+			return dst, err
+		}
+		return s.msgpackString(dst, n)
+	case 0xdc, 0xdd:
+		n, err := s.readUint(2 << (b - 0xdc))
+		if err != nil {
+			return dst, err
+		}
+		return s.msgpackArray(dst, n, depth)
+	case 0xde, 0xdf:
+		n, err := s.readUint(2 << (b - 0xde))
+		if err != nil {
+			return dst, err
+		}
+		return s.msgpackMap(dst, n, depth)
+	default:
+		return dst, s.errorf("invalid type byte 0x" + strconv.FormatUint(uint64(b), 16))
+	}
+}
+
+func (s *binaryStream) msgpackString(dst []byte, n uint64) ([]byte, error) {
+	b, err := s.readBytes(n)
+	if err != nil {
+		return dst, err
+	}
+	return append(dst, appendQuotedBytes(nil, b)...), nil
+}
+
+func (s *binaryStream) msgpackArray(dst []byte, n uint64, depth int) ([]byte, error) {
+	dst = append(dst, '[')
+	for i := uint64(0); i < n; i++ {
+		if i > 0 {
+			dst = append(dst, ',')
+		}
+		var err error
+		if dst, err = s.msgpackValue(dst, depth+1); err != nil {
+			return dst, err
+		}
+	}
+	return append(dst, ']'), nil
+}
+
+func (s *binaryStream) msgpackMap(dst []byte, n uint64, depth int) ([]byte, error) {
+	dst = append(dst, '{')
+	for i := uint64(0); i < n; i++ {
+		if i > 0 {
+			dst = append(dst, ',')
+		}
+		var err error
+		if dst, err = s.appendKey(dst, depth+1, s.msgpackValue); err != nil {
+			return dst, err
+		}
+		dst = append(dst, ':')
+		if dst, err = s.msgpackValue(dst, depth+1); err != nil {
+			return dst, err
+		}
+	}
+	return append(dst, '}'), nil
+}
+
+// msgpackExt writes an extension value with n bytes of data. Type -1 is the
+// standard timestamp.
+func (s *binaryStream) msgpackExt(dst []byte, n uint64) ([]byte, error) {
+	typ, err := s.readByte()
+	if err != nil {
+		return dst, err
+	}
+	data, err := s.readBytes(n)
+	if err != nil {
+		return dst, err
+	}
+	if int8(typ) == -1 {
+		var sec int64
+		var nsec uint32
+		switch len(data) {
+		case 4:
+			sec = int64(binary.BigEndian.Uint32(data))
+		case 8:
+			v := binary.BigEndian.Uint64(data)
+			nsec, sec = uint32(v>>34), int64(v&(1<<34-1))
+		case 12:
+			nsec, sec = binary.BigEndian.Uint32(data), int64(binary.BigEndian.Uint64(data[4:]))
+		default:
+			return dst, s.errorf("invalid timestamp length")
+		}
+		dst = append(dst, '"')
+		dst = time.Unix(sec, int64(nsec)).UTC().AppendFormat(dst, time.RFC3339Nano)
+		return append(dst, '"'), nil
+	}
+	dst = append(dst, `{"type":`...)
+	dst = strconv.AppendInt(dst, int64(int8(typ)), 10)
+	dst = append(dst, `,"data":`...)
+	dst = s.appendBytes(dst, data)
+	return append(dst, '}'), nil
+}
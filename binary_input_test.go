@@ -0,0 +1,173 @@
+package prettyx
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func mustHex(t *testing.T, s string) string {
+	t.Helper()
+	b, err := hex.DecodeString(strings.ReplaceAll(s, " ", ""))
+	if err != nil {
+		t.Fatalf("bad hex %q: %v", s, err)
+	}
+	return string(b)
+}
+
+func TestConvertCBOR(t *testing.T) {
+	// Examples from RFC 8949 Appendix A.
+	cases := []struct {
+		hex  string
+		want string
+	}{
+		{"00", "0"},
+		{"1864", "100"},
+		{"1b000000e8d4a51000", "1000000000000"},
+		{"3bffffffffffffffff", "-18446744073709551616"},
+		{"c249010000000000000000", "18446744073709551616"},
+		{"c349010000000000000000", "-18446744073709551617"},
+		{"3903e7", "-1000"},
+		{"f93c00", "1"},
+		{"f93e00", "1.5"},
+		{"f90001", "5.9604645e-08"},
+		{"fa47c35000", "100000"},
+		{"fb3ff199999999999a", "1.1"},
+		{"f97c00", "1.7976931348623157e+308"},
+		{"f9fc00", "-1.7976931348623157e+308"},
+		{"f97e00", "null"},
+		{"f4", "false"},
+		{"f5", "true"},
+		{"f6", "null"},
+		{"f7", "null"},
+		{"f0", "16"},
+		{"c074323031332d30332d32315432303a30343a30305a", `"2013-03-21T20:04:00Z"`},
+		{"c11a514b67b0", "1363896240"},
+		{"d74401020304", `"01020304"`},
+		{"d54401020304", `"AQIDBA"`},
+		{"4401020304", `"AQIDBA=="`},
+		{"6449455446", `"IETF"`},
+		{"62225c", `"\"\\"`},
+		{"63e6b0b4", `"水"`},
+		{"83010203", "[1,2,3]"},
+		{"8301820203820405", "[1,[2,3],[4,5]]"},
+		{"a201020304", `{"1":2,"3":4}`},
+		{"a26161016162820203", `{"a":1,"b":[2,3]}`},
+		{"5f42010243030405ff", `"AQIDBAU="`},
+		{"7f657374726561646d696e67ff", `"streaming"`},
+		{"9f018202039f0405ffff", "[1,[2,3],[4,5]]"},
+		{"c48221196ab3", "273.15"},
+		{"c482201903e8", "100.0"},
+		{"c4820305", "5e3"},
+		{"c4823831 01", "1e-50"},
+		{"c4822120", "-0.01"},
+		{"c4822000", "0"},
+		{"c48236c249010000000000000000", "0.00018446744073709551616"},
+		{"bf61610161629f0203ffff", `{"a":1,"b":[2,3]}`},
+		{"a1820102f5", `{"[1,2]":true}`},
+		{"d9d9f7a0", "{}"},
+		{"d8648101", `{"tag":100,"value":[1]}`},
+	}
+	for _, tc := range cases {
+		got, err := convertString(t, mustHex(t, tc.hex), InputCBOR)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.hex, err)
+		}
+		if got != tc.want+"\n" {
+			t.Fatalf("%s: got %q, want %q", tc.hex, got, tc.want)
+		}
+	}
+}
+
+func TestConvertCBORSequence(t *testing.T) {
+	opts := *DefaultOptions
+	opts.Palette = "none"
+	var buf bytes.Buffer
+	r := ConvertReader(strings.NewReader(mustHex(t, "a1616101 83010203")), InputCBOR)
+	if err := PrettyStream(&buf, r, &opts); err != nil {
+		t.Fatalf("PrettyStream failed: %v", err)
+	}
+	want := "{\n  \"a\": 1\n}\n[\n  1,\n  2,\n  3\n]\n"
+	if buf.String() != want {
+		t.Fatalf("unexpected output\nexpected:\n%s\nactual:\n%s", want, buf.String())
+	}
+}
+
+func TestConvertMsgPack(t *testing.T) {
+	cases := []struct {
+		hex  string
+		want string
+	}{
+		{"7f", "127"},
+		{"e0", "-32"},
+		{"cc ff", "255"},
+		{"cd 0100", "256"},
+		{"cf ffffffffffffffff", "18446744073709551615"},
+		{"d0 80", "-128"},
+		{"d1 ff00", "-256"},
+		{"d3 8000000000000000", "-9223372036854775808"},
+		{"c0", "null"},
+		{"c2", "false"},
+		{"c3", "true"},
+		{"ca 3fc00000", "1.5"},
+		{"cb 3ff199999999999a", "1.1"},
+		{"cb 7ff8000000000000", "null"},
+		{"a3 616263", `"abc"`},
+		{"d9 03 616263", `"abc"`},
+		{"c4 03 010203", `"AQID"`},
+		{"93 01 a1 61 c0", `[1,"a",null]`},
+		{"82 a1 61 01 a1 62 92 02 03", `{"a":1,"b":[2,3]}`},
+		{"dc 0002 01 02", "[1,2]"},
+		{"de 0001 01 c3", `{"1":true}`},
+		{"81 c4 01 ff 01", `{"/w==":1}`},
+		{"d6 ff 5c8b3d80", `"2019-03-15T05:52:00Z"`},
+		{"d7 ff 0000000c 5c8b3d80", `"2019-03-15T05:52:00.000000003Z"`},
+		{"d4 05 ff", `{"type":5,"data":"/w=="}`},
+	}
+	for _, tc := range cases {
+		got, err := convertString(t, mustHex(t, tc.hex), InputMsgPack)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.hex, err)
+		}
+		if got != tc.want+"\n" {
+			t.Fatalf("%s: got %q, want %q", tc.hex, got, tc.want)
+		}
+	}
+}
+
+func TestConvertBinaryErrors(t *testing.T) {
+	cases := []struct {
+		format InputFormat
+		hex    string
+		msg    string
+		offset int64
+		doc    int
+	}{
+		{InputCBOR, "83 01 02", "unexpected end of input", 3, 1},
+		{InputCBOR, "01 1c", "invalid additional information 28", 1, 2},
+		{InputCBOR, "ff", "unexpected break", 0, 1},
+		{InputCBOR, "5a ffffffff 00", "unexpected end of input", 5, 1},
+		{InputCBOR, "c4 81 01", "decimal fraction without exponent and mantissa", 1, 1},
+		{InputCBOR, "c4 82 f4 01", "invalid decimal fraction exponent", 2, 1},
+		{InputCBOR, "c4 82 01 61 31", "invalid decimal fraction mantissa", 3, 1},
+		{InputMsgPack, "c1", "invalid type byte 0xc1", 0, 1},
+		{InputMsgPack, "01 92 01", "unexpected end of input", 3, 2},
+	}
+	for _, tc := range cases {
+		_, err := convertString(t, mustHex(t, tc.hex), tc.format)
+		var se *SyntaxError
+		if !errors.As(err, &se) {
+			t.Fatalf("%s: expected SyntaxError, got %v", tc.hex, err)
+		}
+		if se.Msg != tc.msg || se.Offset != tc.offset || se.Document != tc.doc || se.Line != 0 {
+			t.Fatalf("%s: got %q at %d doc %d, want %q at %d doc %d", tc.hex, se.Msg, se.Offset, se.Document, tc.msg, tc.offset, tc.doc)
+		}
+	}
+
+	deep := strings.Repeat("\x81", maxBinaryDepth+2) + "\x00"
+	if _, err := convertString(t, deep, InputCBOR); err == nil || !strings.Contains(err.Error(), "exceeded max depth") {
+		t.Fatalf("expected depth error, got %v", err)
+	}
+}
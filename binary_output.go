@@ -0,0 +1,391 @@
+package prettyx
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// CBORTo reads JSON documents from r and writes each to w as a CBOR
+// (RFC 8949) item, so the output is a CBOR sequence (RFC 8742). The input is
+// parsed as CompactTo parses it, so Unwrap, SortKeys, Recover, Repair,
+// Dialect and Path apply; the layout, colour, Log, Passthrough and Flatten
+// options are ignored.
+//
+// Integers are written as CBOR integers, or as bignums (tags 2 and 3) beyond
+// 64 bits. Other numbers are written as the smallest float that reads back
+// as the same decimal value, or as a decimal fraction (tag 4) when no binary
+// float does, so every number is preserved exactly. Maps use definite
+// lengths and keep the key order.
+func CBORTo(w io.Writer, r io.Reader, opts *Options) error {
+	return encodeBinary(w, r, opts, OutputCBOR)
+}
+
+// MsgPackTo reads JSON documents from r and writes each to w as a
+// MessagePack value, using the same options as CBORTo. MessagePack has no
+// big or decimal numbers, so integers beyond 64 bits and decimals that no
+// float represents exactly are written as the nearest float64.
+func MsgPackTo(w io.Writer, r io.Reader, opts *Options) error {
+	return encodeBinary(w, r, opts, OutputMsgPack)
+}
+
+func encodeBinary(w io.Writer, r io.Reader, opts *Options, format OutputFormat) error {
+	if opts == nil {
+		opts = DefaultOptions
+	}
+	bw := bufio.NewWriter(w)
+	enc := &binaryEncoder{w: bw, cbor: format == OutputCBOR}
+	if err := compactDocuments(r, opts, enc.encode); err != nil {
+		_ = bw.Flush()
+		return err
+	}
+	return bw.Flush()
+}
+
+// compactDocuments parses r as CompactTo does and passes each document to
+// fn as a line of compact JSON. Options that change the output format or
+// layout are cleared, so only the options that decide what is read apply.
+func compactDocuments(r io.Reader, opts *Options, fn func(line []byte) error) error {
+	o := *opts
+	o.Output = OutputJSON
+	o.Log = LogOff
+	o.Passthrough = false
+	o.Flatten = false
+	o.KeepComments = false
+	return CompactTo(&lineWriter{fn: fn}, r, &o)
+}
+
+// lineWriter receives compact JSON, one document per line, and passes each
+// line to fn as soon as it is complete.
+type lineWriter struct {
+	fn   func(line []byte) error
+	line []byte
+}
+
+func (lw *lineWriter) Write(p []byte) (int, error) {
+	n := len(p)
+	for len(p) > 0 {
+		i := bytes.IndexByte(p, '\n')
+		if i < 0 {
+			lw.line = append(lw.line, p...)
+			break
+		}
+		lw.line = append(lw.line, p[:i]...)
+		p = p[i+1:]
+		if err := lw.fn(lw.line); err != nil {
+			return 0, err
+		}
+		lw.line = lw.line[:0]
+	}
+	return n, nil
+}
+
+// binaryEncoder writes documents in binary form.
+type binaryEncoder struct {
+	w    io.Writer
+	cbor bool
+	out  []byte
+}
+
+func (e *binaryEncoder) encode(line []byte) error {
+	node, err := decodeJSONLine(line)
+	if err != nil {
+		return err
+	}
+	if e.cbor {
+		e.out = appendCBOR(e.out[:0], node)
+	} else {
+		e.out = appendMsgPack(e.out[:0], node)
+	}
+	_, err = e.w.Write(e.out)
+	return err
+}
+
+// decodeJSONLine decodes one compact JSON document.
+func decodeJSONLine(line []byte) (*inputNode, error) {
+	dec := json.NewDecoder(bytes.NewReader(line))
+	dec.UseNumber()
+	return decodeJSONNode(dec)
+}
+
+// decodeJSONNode reads one value from dec, keeping the member order.
+func decodeJSONNode(dec *json.Decoder) (*inputNode, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch v := tok.(type) {
+	case nil:
+		return &inputNode{}, nil
+	case bool:
+		return &inputNode{kind: inputBool, text: strconv.FormatBool(v)}, nil
+	case json.Number:
+		return &inputNode{kind: inputNumber, text: string(v)}, nil
+	case string:
+		return &inputNode{kind: inputString, text: v}, nil
+	}
+	node := &inputNode{kind: inputArray}
+	if tok == json.Delim('{') {
+		node.kind = inputObject
+	}
+	for dec.More() {
+		if node.kind == inputObject {
+			key, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			node.keys = append(node.keys, key.(string))
+		}
+		item, err := decodeJSONNode(dec)
+		if err != nil {
+			return nil, err
+		}
+		node.items = append(node.items, item)
+	}
+	if _, err := dec.Token(); err != nil {
+		return nil, err
+	}
+	return node, nil
+}
+
+// appendCBORHead appends a CBOR initial byte for major type major with the
+// shortest encoding of arg.
+func appendCBORHead(dst []byte, major byte, arg uint64) []byte {
+	major <<= 5
+	switch {
+	case arg < 24:
+		return append(dst, major|byte(arg))
+	case arg <= math.MaxUint8:
+		return append(dst, major|24, byte(arg))
+	case arg <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(dst, major|25), uint16(arg))
+	case arg <= math.MaxUint32:
+		return binary.BigEndian.AppendUint32(append(dst, major|26), uint32(arg))
+	default:
+		return binary.BigEndian.AppendUint64(append(dst, major|27), arg)
+	}
+}
+
+func appendCBOR(dst []byte, n *inputNode) []byte {
+	switch n.kind {
+	case inputNull:
+		return append(dst, 0xf6)
+	case inputBool:
+		if n.text == "true" {
+			return append(dst, 0xf5)
+		}
+		return append(dst, 0xf4)
+	case inputNumber:
+		return appendCBORNumber(dst, n.text)
+	case inputString:
+		return append(appendCBORHead(dst, 3, uint64(len(n.text))), n.text...)
+	case inputObject:
+		dst = appendCBORHead(dst, 5, uint64(len(n.keys)))
+		for i, key := range n.keys {
+			dst = append(appendCBORHead(dst, 3, uint64(len(key))), key...)
+			dst = appendCBOR(dst, n.items[i])
+		}
+		return dst
+	default:
+		dst = appendCBORHead(dst, 4, uint64(len(n.items)))
+		for _, item := range n.items {
+			dst = appendCBOR(dst, item)
+		}
+		return dst
+	}
+}
+
+func appendCBORNumber(dst []byte, num string) []byte {
+	if isJSONInteger(num) {
+		var v big.Int
+		v.SetString(num, 10)
+		return appendCBORInt(dst, &v)
+	}
+	if f, ok := exactFloat(num); ok {
+		if f32 := float32(f); float64(f32) == f {
+			return binary.BigEndian.AppendUint32(append(dst, 0xfa), math.Float32bits(f32))
+		}
+		return binary.BigEndian.AppendUint64(append(dst, 0xfb), math.Float64bits(f))
+	}
+	// A decimal fraction is [exponent, mantissa] meaning mantissa*10^exponent.
+	mant, exp := decimalParts(num)
+	var m big.Int
+	m.SetString(mant, 10)
+	dst = append(dst, 0xc4, 0x82)
+	dst = appendCBORInt(dst, big.NewInt(int64(exp)))
+	return appendCBORInt(dst, &m)
+}
+
+// appendCBORInt writes v as an integer, or as a bignum beyond 64 bits.
+func appendCBORInt(dst []byte, v *big.Int) []byte {
+	if v.Sign() >= 0 {
+		if v.IsUint64() {
+			return appendCBORHead(dst, 0, v.Uint64())
+		}
+		b := v.Bytes()
+		return append(appendCBORHead(append(dst, 0xc2), 2, uint64(len(b))), b...)
+	}
+	// Negative integers encode -1-v.
+	var arg big.Int
+	arg.Neg(v)
+	arg.Sub(&arg, big.NewInt(1))
+	if arg.IsUint64() {
+		return appendCBORHead(dst, 1, arg.Uint64())
+	}
+	b := arg.Bytes()
+	return append(appendCBORHead(append(dst, 0xc3), 2, uint64(len(b))), b...)
+}
+
+func appendMsgPack(dst []byte, n *inputNode) []byte {
+	switch n.kind {
+	case inputNull:
+		return append(dst, 0xc0)
+	case inputBool:
+		if n.text == "true" {
+			return append(dst, 0xc3)
+		}
+		return append(dst, 0xc2)
+	case inputNumber:
+		return appendMsgPackNumber(dst, n.text)
+	case inputString:
+		return appendMsgPackString(dst, n.text)
+	case inputObject:
+		dst = appendMsgPackLen(dst, len(n.keys), 0x80, 0xde)
+		for i, key := range n.keys {
+			dst = appendMsgPackString(dst, key)
+			dst = appendMsgPack(dst, n.items[i])
+		}
+		return dst
+	default:
+		dst = appendMsgPackLen(dst, len(n.items), 0x90, 0xdc)
+		for _, item := range n.items {
+			dst = appendMsgPack(dst, item)
+		}
+		return dst
+	}
+}
+
+// appendMsgPackLen writes a map or array header: the fix form below 16,
+// then the 16- and 32-bit forms that follow code16.
+func appendMsgPackLen(dst []byte, n int, fix, code16 byte) []byte {
+	switch {
+	case n < 16:
+		return append(dst, fix|byte(n))
+	case n <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(dst, code16), uint16(n))
+	default:
+		return binary.BigEndian.AppendUint32(append(dst, code16+1), uint32(n))
+	}
+}
+
+func appendMsgPackString(dst []byte, s string) []byte {
+	switch n := len(s); {
+	case n < 32:
+		dst = append(dst, 0xa0|byte(n))
+	case n <= math.MaxUint8:
+		dst = append(dst, 0xd9, byte(n))
+	case n <= math.MaxUint16:
+		dst = binary.BigEndian.AppendUint16(append(dst, 0xda), uint16(n))
+	default:
+		dst = binary.BigEndian.AppendUint32(append(dst, 0xdb), uint32(n))
+	}
+	return append(dst, s...)
+}
+
+func appendMsgPackNumber(dst []byte, num string) []byte {
+	if isJSONInteger(num) {
+		if v, err := strconv.ParseInt(num, 10, 64); err == nil {
+			return appendMsgPackInt(dst, v)
+		}
+		if v, err := strconv.ParseUint(num, 10, 64); err == nil {
+			return binary.BigEndian.AppendUint64(append(dst, 0xcf), v)
+		}
+	}
+	f, ok := exactFloat(num)
+	if ok {
+		if f32 := float32(f); float64(f32) == f {
+			return binary.BigEndian.AppendUint32(append(dst, 0xca), math.Float32bits(f32))
+		}
+	}
+	if math.IsInf(f, 0) {
+		f = math.Copysign(math.MaxFloat64, f)
+	}
+	return binary.BigEndian.AppendUint64(append(dst, 0xcb), math.Float64bits(f))
+}
+
+func appendMsgPackInt(dst []byte, v int64) []byte {
+	switch {
+	case v >= 0 && v <= math.MaxInt8:
+		return append(dst, byte(v))
+	case v >= -32 && v < 0:
+		return append(dst, byte(int8(v)))
+	case v >= 0 && v <= math.MaxUint8:
+		return append(dst, 0xcc, byte(v))
+	case v >= 0 && v <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(dst, 0xcd), uint16(v))
+	case v >= 0 && v <= math.MaxUint32:
+		return binary.BigEndian.AppendUint32(append(dst, 0xce), uint32(v))
+	case v >= 0:
+		return binary.BigEndian.AppendUint64(append(dst, 0xcf), uint64(v))
+	case v >= math.MinInt8:
+		return append(dst, 0xd0, byte(int8(v)))
+	case v >= math.MinInt16:
+		return binary.BigEndian.AppendUint16(append(dst, 0xd1), uint16(int16(v)))
+	case v >= math.MinInt32:
+		return binary.BigEndian.AppendUint32(append(dst, 0xd2), uint32(int32(v)))
+	default:
+		return binary.BigEndian.AppendUint64(append(dst, 0xd3), uint64(v))
+	}
+}
+
+// isJSONInteger reports whether the JSON number num has no fraction or
+// exponent.
+func isJSONInteger(num string) bool {
+	return !strings.ContainsAny(num, ".eE")
+}
+
+// exactFloat parses num and reports whether the float64 reads back as the
+// same decimal value, so writing the float loses nothing.
+func exactFloat(num string) (float64, bool) {
+	f, err := strconv.ParseFloat(num, 64)
+	if err != nil {
+		return f, false
+	}
+	m1, e1 := decimalParts(num)
+	m2, e2 := decimalParts(strconv.FormatFloat(f, 'g', -1, 64))
+	return f, m1 == m2 && e1 == e2
+}
+
+// decimalParts splits a JSON number into an integer mantissa, with sign and
+// without trailing zeros, and a power-of-ten exponent.
+func decimalParts(num string) (string, int) {
+	neg := strings.HasPrefix(num, "-")
+	num = strings.TrimPrefix(num, "-")
+	exp := 0
+	if i := strings.IndexAny(num, "eE"); i >= 0 {
+		exp, _ = strconv.Atoi(strings.TrimPrefix(num[i+1:], "+"))
+		num = num[:i]
+	}
+	if i := strings.IndexByte(num, '.'); i >= 0 {
+		exp -= len(num) - i - 1
+		num = num[:i] + num[i+1:]
+	}
+	num = strings.TrimLeft(num, "0")
+	for strings.HasSuffix(num, "0") {
+		num = num[:len(num)-1]
+		exp++
+	}
+	if num == "" {
+		return "0", 0
+	}
+	if neg {
+		num = "-" + num
+	}
+	return num, exp
+}
@@ -0,0 +1,112 @@
+package prettyx
+
+import (
+	"bytes"
+	"encoding/hex"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestCBORTo(t *testing.T) {
+	cases := []struct {
+		json string
+		hex  string
+	}{
+		{`0`, "00"},
+		{`100`, "1864"},
+		{`-1000`, "3903e7"},
+		{`18446744073709551615`, "1bffffffffffffffff"},
+		{`18446744073709551616`, "c249010000000000000000"},
+		{`-18446744073709551617`, "c349010000000000000000"},
+		{`1.5`, "fa3fc00000"},
+		{`1.1`, "fb3ff199999999999a"},
+		{`1e300`, "fb7e37e43c8800759c"},
+		{`0.10000000000000000001`, "c482331b8ac7230489e80001"},
+		{`[1,"a",true,null,{"b":false}]`, "85016161f5f6a16162f4"},
+		{`{"z":1,"a":2}`, "a2617a01616102"},
+	}
+	for _, tc := range cases {
+		var buf bytes.Buffer
+		if err := CBORTo(&buf, strings.NewReader(tc.json), nil); err != nil {
+			t.Fatalf("%s: %v", tc.json, err)
+		}
+		want := strings.ReplaceAll(tc.hex, " ", "")
+		if got := hex.EncodeToString(buf.Bytes()); got != want {
+			t.Fatalf("%s: got %s, want %s", tc.json, got, want)
+		}
+	}
+}
+
+func TestMsgPackTo(t *testing.T) {
+	cases := []struct {
+		json string
+		hex  string
+	}{
+		{`127`, "7f"},
+		{`-32`, "e0"},
+		{`-33`, "d0df"},
+		{`255`, "ccff"},
+		{`65536`, "ce00010000"},
+		{`-9223372036854775808`, "d38000000000000000"},
+		{`18446744073709551615`, "cfffffffffffffffff"},
+		{`1.5`, "ca3fc00000"},
+		{`1.1`, "cb3ff199999999999a"},
+		{`"abc"`, "a3616263"},
+		{`[1,null,true]`, "9301c0c3"},
+		{`{"a":[]}`, "81a16190"},
+	}
+	for _, tc := range cases {
+		var buf bytes.Buffer
+		if err := MsgPackTo(&buf, strings.NewReader(tc.json), nil); err != nil {
+			t.Fatalf("%s: %v", tc.json, err)
+		}
+		if got := hex.EncodeToString(buf.Bytes()); got != tc.hex {
+			t.Fatalf("%s: got %s, want %s", tc.json, got, tc.hex)
+		}
+	}
+}
+
+func TestBinaryOutputRoundTrip(t *testing.T) {
+	input := `{"name":"web","tags":["a","b"],"n":-42,"big":123456789012345678901234567890,"f":0.25,"d":0.10000000000000000000001,"nested":"{\"x\":[1,2]}","s":"` + strings.Repeat("x", 300) + `"}
+[1,2,3]
+"done"
+`
+	want := `{"name":"web","tags":["a","b"],"n":-42,"big":123456789012345678901234567890,"f":0.25,"d":0.10000000000000000000001,"nested":{"x":[1,2]},"s":"` + strings.Repeat("x", 300) + `"}
+[1,2,3]
+"done"
+`
+	for _, format := range []InputFormat{InputCBOR, InputMsgPack} {
+		opts := &Options{Unwrap: true, Output: OutputCBOR}
+		if format == InputMsgPack {
+			opts.Output = OutputMsgPack
+		}
+		var enc bytes.Buffer
+		if err := PrettyStream(&enc, strings.NewReader(input), opts); err != nil {
+			t.Fatalf("encode: %v", err)
+		}
+		got, err := io.ReadAll(ConvertReader(&enc, format))
+		if err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		w := want
+		if format == InputMsgPack {
+			w = strings.Replace(want, "123456789012345678901234567890", "1.2345678901234568e+29", 1)
+			w = strings.Replace(w, "0.10000000000000000000001", "0.1", 1)
+		}
+		if string(got) != w {
+			t.Fatalf("format %v: unexpected round trip\nexpected:\n%s\nactual:\n%s", format, w, got)
+		}
+	}
+}
+
+func TestCBORToSyntaxError(t *testing.T) {
+	var buf bytes.Buffer
+	err := CBORTo(&buf, strings.NewReader("{\"a\":1}\n{\"b\":}"), nil)
+	if err == nil || !strings.Contains(err.Error(), "document 2") {
+		t.Fatalf("expected syntax error in document 2, got %v", err)
+	}
+	if got := hex.EncodeToString(buf.Bytes()); got != "a1616101" {
+		t.Fatalf("expected the first document only, got %s", got)
+	}
+}
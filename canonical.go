@@ -0,0 +1,152 @@
+package prettyx
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"math"
+	"strconv"
+)
+
+// CanonicalTo writes each JSON document from r to w in the RFC 8785 JSON
+// Canonicalization Scheme (JCS), one document per line: object keys sorted by
+// UTF-16 code units, numbers serialised the way ECMAScript does, strings with
+// minimal escaping and no insignificant whitespace. When opts.Unwrap is true,
+// embedded JSON strings are decoded and canonicalised as nested values before
+// the outer document is serialised, as limited by the other Unwrap options.
+// opts.Recover skips malformed documents and opts.Path selects values as they
+// do for PrettyStream. Other options are ignored.
+//
+// Input that cannot be represented canonically, such as numbers outside the
+// IEEE 754 double range, invalid UTF-8, lone surrogates or duplicate member
+// names, is rejected with a *SyntaxError.
+func CanonicalTo(w io.Writer, r io.Reader, opts *Options) error {
+	if opts == nil {
+		opts = DefaultOptions
+	}
+	o := Options{
+		Unwrap:           opts.Unwrap,
+		UnwrapDepth:      opts.UnwrapDepth,
+		UnwrapMinLength:  opts.UnwrapMinLength,
+		UnwrapKeys:       opts.UnwrapKeys,
+		UnwrapSkipKeys:   opts.UnwrapSkipKeys,
+		UnwrapDecoders:   opts.UnwrapDecoders,
+		UnwrapMaxDecoded: opts.UnwrapMaxDecoded,
+		UnwrapJWT:        opts.UnwrapJWT,
+		SortKeys:         KeyOrderUTF16,
+		Recover:          opts.Recover,
+		Path:             opts.Path,
+	}
+	bw := bufio.NewWriter(w)
+	p := acquireParser()
+	defer releaseParser(p)
+	p.reset(r, bw, &o, NoColorPalette(), true)
+	p.canonical = true
+	if err := p.stream(); err != nil {
+		_ = bw.Flush()
+		return err
+	}
+	return bw.Flush()
+}
+
+// CanonicalToBuffer canonicalises JSON into memory. It preserves the
+// one-document-per-line behavior of CanonicalTo.
+func CanonicalToBuffer(r io.Reader, opts *Options) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := CanonicalTo(&buf, r, opts); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// parseCanonicalNumber validates a number like parseNumber but writes its
+// ECMAScript serialisation instead of the input text.
+func (p *parser) parseCanonicalNumber(first byte) error {
+	state, ok := numStartState(first)
+	if !ok {
+		return p.errorf("invalid number")
+	}
+	p.scratch = append(p.scratch[:0], first)
+	for {
+		b, err := p.scanner.peekByte()
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			return err
+		}
+		if isTerminator(b) {
+			break
+		}
+		next, ok := numNextState(state, b)
+		if !ok {
+			return p.errorfNext("invalid number")
+		}
+		state = next
+		_, _ = p.scanner.readByte()
+		p.scratch = append(p.scratch, b)
+	}
+	if !numIsTerminal(state) {
+		return p.errorfNext("invalid number")
+	}
+	f, err := strconv.ParseFloat(string(p.scratch), 64)
+	if err != nil || math.IsInf(f, 0) {
+		return p.errorfNext("number %s out of range", p.scratch)
+	}
+	p.scratch = appendCanonicalNumber(p.scratch[:0], f)
+	return p.formatter.writeBytes(p.scratch)
+}
+
+// appendCanonicalNumber appends f formatted by the ECMAScript
+// Number.prototype.toString algorithm referenced by RFC 8785 section 3.2.2.3.
+func appendCanonicalNumber(dst []byte, f float64) []byte {
+	if f == 0 {
+		return append(dst, '0')
+	}
+	if f < 0 {
+		dst = append(dst, '-')
+		f = -f
+	}
+	// Shortest round-trip digits as d.ddde±x; Go and ECMAScript agree on them.
+	var tmp [32]byte
+	e := strconv.AppendFloat(tmp[:0], f, 'e', -1, 64)
+	mark := bytes.IndexByte(e, 'e')
+	exp, _ := strconv.Atoi(string(e[mark+1:]))
+	var digitsBuf [24]byte
+	digits := append(digitsBuf[:0], e[0])
+	if mark > 1 {
+		digits = append(digits, e[2:mark]...)
+	}
+	k := len(digits)
+	n := exp + 1
+
+	switch {
+	case k <= n && n <= 21:
+		dst = append(dst, digits...)
+		for i := 0; i < n-k; i++ {
+			dst = append(dst, '0')
+		}
+	case 0 < n && n <= 21:
+		dst = append(dst, digits[:n]...)
+		dst = append(dst, '.')
+		dst = append(dst, digits[n:]...)
+	case -6 < n && n <= 0:
+		dst = append(dst, '0', '.')
+		for i := 0; i < -n; i++ {
+			dst = append(dst, '0')
+		}
+		dst = append(dst, digits...)
+	default:
+		dst = append(dst, digits[0])
+		if k > 1 {
+			dst = append(dst, '.')
+			dst = append(dst, digits[1:]...)
+		}
+		dst = append(dst, 'e')
+		if n-1 >= 0 {
+			dst = append(dst, '+')
+		}
+		dst = strconv.AppendInt(dst, int64(n-1), 10)
+	}
+	return dst
+}
@@ -0,0 +1,94 @@
+package prettyx
+
+import (
+	"errors"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestCanonical_RFC8785Example(t *testing.T) {
+	input := `{
+  "numbers": [333333333.33333329, 1E30, 4.50, 2e-3, 0.000000000000000000000000001],
+  "string": "\u20ac$\u000F\u000aA'\u0042\u0022\u005c\\\"\/",
+  "literals": [null, true, false]
+}`
+	const expected = `{"literals":[null,true,false],"numbers":[333333333.3333333,1e+30,4.5,0.002,1e-27],"string":"€$\u000f\nA'B\"\\\\\"/"}` + "\n"
+
+	out, err := CanonicalToBuffer(strings.NewReader(input), nil)
+	if err != nil {
+		t.Fatalf("CanonicalToBuffer failed: %v", err)
+	}
+	if string(out) != expected {
+		t.Fatalf("unexpected canonical output\nexpected:\n%s\nactual:\n%s", expected, out)
+	}
+}
+
+func TestCanonical_SortsByUTF16AndUnwraps(t *testing.T) {
+	input := `{"\u20ac":"Euro Sign","\r":"Carriage Return","\ufb33":"Hebrew Letter Dalet With Dagesh","1":"One","\ud83d\ude00":"Emoji: Grinning Face","\u0080":"Control","\u00f6":"Latin Small Letter O With Diaeresis"}
+{"sig":"x","payload":" {\"b\": 1.0, \"a\": [1e2, \"\\u0041\"]} "}`
+	const expected = "{\"\\r\":\"Carriage Return\",\"1\":\"One\",\"\u0080\":\"Control\",\"\u00f6\":\"Latin Small Letter O With Diaeresis\",\"\u20ac\":\"Euro Sign\",\"\U0001F600\":\"Emoji: Grinning Face\",\"\ufb33\":\"Hebrew Letter Dalet With Dagesh\"}\n" +
+		`{"payload":{"a":[100,"A"],"b":1},"sig":"x"}` + "\n"
+
+	opts := *DefaultOptions
+	opts.Unwrap = true
+	opts.SemiCompact = true
+	opts.Prefix = "> "
+	out, err := CanonicalToBuffer(strings.NewReader(input), &opts)
+	if err != nil {
+		t.Fatalf("CanonicalToBuffer failed: %v", err)
+	}
+	if string(out) != expected {
+		t.Fatalf("unexpected canonical output\nexpected:\n%s\nactual:\n%s", expected, out)
+	}
+
+	out, err = CanonicalToBuffer(strings.NewReader(`{"payload":"{\"b\":1,\"a\":2}"}`), nil)
+	if err != nil {
+		t.Fatalf("CanonicalToBuffer failed: %v", err)
+	}
+	if string(out) != `{"payload":"{\"b\":1,\"a\":2}"}`+"\n" {
+		t.Fatalf("expected strings untouched without Unwrap, got %s", out)
+	}
+}
+
+func TestCanonical_Rejects(t *testing.T) {
+	for _, input := range []string{`1e400`, `"\udc00"`, "\"\xff\"", "{\"\xff\":1}", `[1,]`, `{"a":1`, `{"a":1,"a":2}`, `[{"b":{"a":1,"c":2,"a":1}}]`} {
+		var se *SyntaxError
+		if _, err := CanonicalToBuffer(strings.NewReader(input), nil); !errors.As(err, &se) {
+			t.Fatalf("expected syntax error for %q, got %v", input, err)
+		}
+	}
+	if err := CanonicalTo(errWriter{}, strings.NewReader(`{"a":1}`), nil); err == nil {
+		t.Fatalf("expected writer error")
+	}
+}
+
+func TestAppendCanonicalNumber(t *testing.T) {
+	cases := []struct {
+		in   float64
+		want string
+	}{
+		{0, "0"},
+		{math.Copysign(0, -1), "0"},
+		{1, "1"},
+		{-1.5, "-1.5"},
+		{1e20, "100000000000000000000"},
+		{1e21, "1e+21"},
+		{123e18, "123000000000000000000"},
+		{1.5e-7, "1.5e-7"},
+		{1e-6, "0.000001"},
+		{1e-7, "1e-7"},
+		{9007199254740993, "9007199254740992"},
+		{5e-324, "5e-324"},
+		{math.MaxFloat64, "1.7976931348623157e+308"},
+		{-math.MaxFloat64, "-1.7976931348623157e+308"},
+		{0.30000000000000004, "0.30000000000000004"},
+		{295147905179352830000, "295147905179352830000"},
+		{4.5, "4.5"},
+	}
+	for _, tc := range cases {
+		if got := string(appendCanonicalNumber(nil, tc.in)); got != tc.want {
+			t.Fatalf("appendCanonicalNumber(%v) = %q, want %q", tc.in, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/spf13/pflag"
+
+	"pkt.systems/prettyx"
+)
+
+// runDiff implements "prettyx diff OLD NEW". Like diff(1) it returns 0 when
+// the documents are equal, 1 when they differ and 2 on errors.
+func runDiff(args []string, stdout, stderr io.Writer) int {
+	flags := pflag.NewFlagSet("prettyx diff", pflag.ContinueOnError)
+	flags.SetOutput(stderr)
+
+	input := addInputFlags(flags)
+	ignoreOrder := flags.Bool("ignore-key-order", false, "do not report objects whose members only changed order")
+	moves := flags.Bool("moves", false, "report array elements that moved to another index instead of removing and adding them")
+	sideBySide := flags.BoolP("side-by-side", "y", false, "print old and new values in two columns")
+	width := flags.IntP("width", "w", 120, "line width of the --side-by-side layout")
+	pathExpr := flags.StringP("path", "p", "", "compare only the value at a JSON Pointer or jq-style path in each document")
+	ignore := flags.StringArray("ignore", nil, "leave the values at a JSON Pointer or jq-style path, such as .items[].id, out of the comparison (repeatable)")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: prettyx diff [flags] OLD NEW")
+		fmt.Fprintln(flags.Output(), "Compares two JSON documents (files, URLs or - for stdin) structurally.")
+		fmt.Fprintln(flags.Output(), "Exit status is 0 when they are equal, 1 when they differ and 2 on errors.")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, pflag.ErrHelp) {
+			return 0
+		}
+		fmt.Fprintf(stderr, "prettyx: %v\n", err)
+		return 2
+	}
+	if flags.NArg() != 2 {
+		flags.Usage()
+		return 2
+	}
+
+	opts, in, err := input.options()
+	if err != nil {
+		fmt.Fprintf(stderr, "prettyx: %v\n", err)
+		return 2
+	}
+	if *pathExpr != "" {
+		path, err := prettyx.ParsePath(*pathExpr)
+		if err != nil {
+			fmt.Fprintf(stderr, "prettyx: %v\n", err)
+			return 2
+		}
+		opts.Path = path
+	}
+	// Each input is compacted on its own so errors and repairs name their
+	// file; the documents are then compared as they are.
+	var docs [2]bytes.Buffer
+	for i, path := range flags.Args() {
+		if err := compactInput(&docs[i], path, withRepairReport(&opts, path, stderr), in); err != nil {
+			reportError(stderr, err)
+			return 2
+		}
+	}
+	display := *prettyx.DefaultOptions
+	display.ForceColor = opts.ForceColor
+	display.Palette = opts.Palette
+	diffOpts := &prettyx.DiffOptions{
+		Options:        &display,
+		IgnoreKeyOrder: *ignoreOrder,
+		Moves:          *moves,
+		Width:          *width,
+	}
+	for _, expr := range *ignore {
+		path, err := prettyx.ParsePath(expr)
+		if err != nil {
+			fmt.Fprintf(stderr, "prettyx: %v\n", err)
+			return 2
+		}
+		diffOpts.Ignore = append(diffOpts.Ignore, path)
+	}
+	if *sideBySide {
+		diffOpts.Layout = prettyx.DiffSideBySide
+	}
+	changes, err := prettyx.Diff(&docs[0], &docs[1], diffOpts)
+	if err != nil {
+		reportError(stderr, err)
+		return 2
+	}
+	if err := prettyx.WriteDiff(stdout, changes, diffOpts); err != nil {
+		reportError(stderr, err)
+		return 2
+	}
+	if len(changes) > 0 {
+		return 1
+	}
+	return 0
+}
+
+// compactInput writes the documents of path to w as compact JSON, read with
+// opts; output options such as --output do not apply to it.
+func compactInput(w io.Writer, path string, opts *prettyx.Options, in inputOptions) error {
+	o := *opts
+	o.Output = prettyx.OutputJSON
+	o.Flatten = false
+	opts = &o
+	reader, closer, err := openInput(path, in)
+	if err != nil {
+		return err
+	}
+	if closer != nil {
+		defer closer.Close()
+	}
+	if err := prettyx.CompactTo(w, reader, opts); err != nil {
+		return fmt.Errorf("%s: %w", sourceName(path), err)
+	}
+	return nil
+}
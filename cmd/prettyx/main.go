@@ -1,6 +1,7 @@
 package main
 
 import (
+	"crypto/rand"
 	"crypto/tls"
 	"errors"
 	"fmt"
@@ -10,29 +11,70 @@ import (
 	"os"
 	"strings"
 
+	"github.com/mattn/go-isatty"
 	"github.com/spf13/pflag"
 
 	"pkt.systems/prettyx"
 )
 
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "diff":
+			os.Exit(runDiff(os.Args[2:], os.Stdout, os.Stderr))
+		case "patch":
+			os.Exit(runPatch(os.Args[2:], os.Stdout, os.Stderr))
+		case "apply":
+			os.Exit(runApply(os.Args[2:], os.Stdout, os.Stderr))
+		}
+	}
 	flags := pflag.NewFlagSet(os.Args[0], pflag.ContinueOnError)
 	flags.SetOutput(os.Stderr)
 
 	var forceColor bool
 	flags.BoolVarP(&forceColor, "color-force", "C", false, "force colorized output even when writing to a non-TTY")
 	noColor := flags.Bool("no-color", false, "disable colorized output, even when writing to a TTY")
-	unwrap := flags.BoolP("unwrap", "u", false, "decode JSON-looking strings recursively")
+	unwrap := addUnwrapFlags(flags)
 	compact := flags.BoolP("compact", "c", false, "compact output (one document per line, no color)")
+	canonical := flags.Bool("canonical", false, "emit RFC 8785 canonical JSON (JCS), one document per line, no color")
 	semiCompact := flags.Bool("semi-compact", false, "use tidwall-style semi-compact formatting (soft wraps to --width)")
 	width := flags.IntP("width", "w", prettyx.DefaultOptions.Width, "soft wrap width for --semi-compact (<= 0 always wraps)")
+	sortKeys := flags.StringP("sort-keys", "S", "", "sort object keys recursively: bytes (default when given) or utf16")
+	flags.Lookup("sort-keys").NoOptDefVal = "bytes"
+	logMode := flags.String("log", "", "render log records: tree (default when given) colours level/time/message, console prints one line per record")
+	flags.Lookup("log").NoOptDefVal = "tree"
+	levelKeys := flags.StringSlice("level-key", prettyx.DefaultLogKeys.Level, "member names recognised as the log level in --log mode")
+	timeKeys := flags.StringSlice("time-key", prettyx.DefaultLogKeys.Time, "member names recognised as the log timestamp in --log mode")
+	messageKeys := flags.StringSlice("message-key", prettyx.DefaultLogKeys.Message, "member names recognised as the log message in --log mode")
+	passthrough := flags.Bool("passthrough", false, "read line by line, format JSON found inside each line and copy other text unchanged")
+	recoverFlag := flags.Bool("recover", false, "skip malformed documents, report them on stderr and continue (exit status 3 when any were skipped)")
+	repair := flags.Bool("repair", false, "fix truncated and sloppy JSON (single quotes, True/None, trailing commas, bare keys) and report each fix on stderr")
+	inputDialect := flags.String("input-dialect", "json", "input syntax: json, jsonc (comments, trailing commas) or json5")
+	keepComments := flags.Bool("keep-comments", false, "keep jsonc/json5 comments in pretty output instead of stripping them")
+	inputFormatName := flags.String("input-format", "auto", "input format: auto (by file extension), json, yaml, toml, csv, cbor or msgpack")
+	output := flags.StringP("output", "o", "json", "output format: json, yaml, cbor, msgpack, or a table of array elements and documents: table, csv or tsv")
+	tableFlatten := flags.Bool("table-flatten", false, "split nested objects into dotted columns (metadata.name) in table output instead of JSON cells")
+	forceBinary := flags.Bool("force-binary", false, "write --output cbor or msgpack even when stdout is a terminal")
+	gron := flags.BoolP("gron", "g", false, "flatten to one gron-style assignment per value (json.items[0].name = \"x\";) for grepping")
+	ungron := flags.Bool("ungron", false, "rebuild JSON from gron-style assignment lines, then format it as usual")
+	pathExpr := flags.StringP("path", "p", "", "print only the values at a JSON Pointer (/items/3) or jq-style path (.items[3], .items[].name)")
+	redact := flags.Bool("redact", false, "mask common secrets: password, token, secret and authorization members, JWTs and AWS access keys")
+	redactKeys := flags.StringArray("redact-key", nil, "mask members whose name matches a glob such as '*password*' (case-insensitive, repeatable)")
+	redactPaths := flags.StringArray("redact-path", nil, "mask the value at a JSON Pointer or jq-style path (repeatable)")
+	redactValues := flags.StringArray("redact-value", nil, "mask the parts of string values matching a regular expression (repeatable)")
+	redactHash := flags.Bool("redact-hash", false, "replace redacted values with a short keyed SHA-256 digest instead of [REDACTED] (implies --redact without other rules)")
+	redactHashKey := flags.String("redact-hash-key", "", "key for --redact-hash digests, so they match across runs (default: a random key per run)")
 	insecure := flags.BoolP("insecure", "k", false, "allow insecure HTTPS connections for URL inputs (skip TLS verification)")
 	acceptAll := flags.Bool("accept-all", false, "send Accept: */* when fetching URLs (default sends JSON-focused Accept header)")
 	paletteName := flags.String("palette", "default", "palette name (use --list-palettes to see options)")
 	listPalettes := flags.Bool("list-palettes", false, "list available palette names and exit")
 	flags.Usage = func() {
 		fmt.Fprintf(flags.Output(), "Usage: %s [flags] [file_or_url...]\n", os.Args[0])
-		fmt.Fprintln(flags.Output(), "Reads from stdin when no files are provided.")
+		fmt.Fprintf(flags.Output(), "       %s diff [flags] OLD NEW\n", os.Args[0])
+		fmt.Fprintf(flags.Output(), "       %s patch [flags] OLD NEW\n", os.Args[0])
+		fmt.Fprintf(flags.Output(), "       %s apply [flags] PATCH [file_or_url...]\n", os.Args[0])
+		fmt.Fprintln(flags.Output(), "Reads from stdin when no files are provided. Run a subcommand with --help for its flags.")
+		fmt.Fprintln(flags.Output(), "Exit status is 1 on errors, 2 on usage errors and 3 when --recover skipped documents.")
 		flags.PrintDefaults()
 	}
 	if err := flags.Parse(os.Args[1:]); err != nil {
@@ -55,7 +97,10 @@ func main() {
 		args = []string{"-"}
 	}
 	opts := *prettyx.DefaultOptions
-	opts.Unwrap = *unwrap
+	if err := unwrap.apply(&opts); err != nil {
+		fmt.Fprintf(os.Stderr, "prettyx: %v\n", err)
+		os.Exit(2)
+	}
 	if forceColor {
 		opts.ForceColor = true
 	}
@@ -65,44 +110,318 @@ func main() {
 	}
 	opts.SemiCompact = *semiCompact
 	opts.Width = *width
-	urlOpts := urlOptions{
-		insecure:  *insecure,
-		acceptAll: *acceptAll,
+	keyOrder, err := parseKeyOrder(*sortKeys)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "prettyx: %v\n", err)
+		os.Exit(2)
+	}
+	opts.SortKeys = keyOrder
+	logLayout, err := parseLogLayout(*logMode)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "prettyx: %v\n", err)
+		os.Exit(2)
+	}
+	opts.Log = logLayout
+	opts.Passthrough = *passthrough
+	dialect, err := parseDialect(*inputDialect)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "prettyx: %v\n", err)
+		os.Exit(2)
+	}
+	opts.Dialect = dialect
+	opts.KeepComments = *keepComments
+	opts.Repair = *repair
+	opts.Flatten = *gron
+	outputFormat, err := parseOutputFormat(*output)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "prettyx: %v\n", err)
+		os.Exit(2)
+	}
+	if outputFormat != prettyx.OutputJSON && (*compact || *canonical) {
+		fmt.Fprintf(os.Stderr, "prettyx: --output %s cannot be combined with --compact or --canonical\n", *output)
+		os.Exit(2)
+	}
+	opts.Output = outputFormat
+	opts.TableFlatten = *tableFlatten
+	if *gron && outputFormat != prettyx.OutputJSON && outputFormat != prettyx.OutputYAML {
+		fmt.Fprintf(os.Stderr, "prettyx: --output %s cannot be combined with --gron\n", *output)
+		os.Exit(2)
 	}
+	if binaryOutput(outputFormat) {
+		if !*forceBinary && isatty.IsTerminal(os.Stdout.Fd()) {
+			fmt.Fprintf(os.Stderr, "prettyx: refusing to write %s to a terminal (redirect stdout or use --force-binary)\n", *output)
+			os.Exit(2)
+		}
+	}
+	inputFormat, detectFormat, err := parseInputFormat(*inputFormatName)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "prettyx: %v\n", err)
+		os.Exit(2)
+	}
+	if *ungron {
+		if inputFormat != prettyx.InputJSON {
+			fmt.Fprintf(os.Stderr, "prettyx: --input-format %s cannot be combined with --ungron\n", *inputFormatName)
+			os.Exit(2)
+		}
+		detectFormat = false
+	}
+	if *pathExpr != "" {
+		if *passthrough {
+			fmt.Fprintln(os.Stderr, "prettyx: --path cannot be combined with --passthrough")
+			os.Exit(2)
+		}
+		path, err := prettyx.ParsePath(*pathExpr)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "prettyx: %v\n", err)
+			os.Exit(2)
+		}
+		opts.Path = path
+	}
+	redactor, err := buildRedactor(*redact, *redactKeys, *redactPaths, *redactValues, *redactHash, *redactHashKey)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "prettyx: %v\n", err)
+		os.Exit(2)
+	}
+	opts.Redact = redactor
+	opts.LogKeys = &prettyx.LogKeys{
+		Level:   *levelKeys,
+		Time:    *timeKeys,
+		Message: *messageKeys,
+	}
+	in := inputOptions{
+		urlOptions: urlOptions{
+			insecure:  *insecure,
+			acceptAll: *acceptAll,
+		},
+		format:       inputFormat,
+		detectFormat: detectFormat,
+	}
+	skipped := 0
 	for _, path := range args {
+		source := sourceName(path)
+		if *repair {
+			opts.OnRepair = func(r prettyx.Repair) {
+				fmt.Fprintf(os.Stderr, "prettyx: %s: %s\n", source, r)
+			}
+		}
+		if *recoverFlag {
+			opts.Recover = func(se *prettyx.SyntaxError) {
+				skipped++
+				reportError(os.Stderr, fmt.Errorf("%s: %w", source, se))
+			}
+		}
 		var err error
-		if *compact {
-			err = streamCompact(path, &opts, urlOpts)
-		} else {
-			err = streamPretty(path, &opts, urlOpts)
+		switch {
+		case *ungron:
+			format := prettyx.PrettyStream
+			if *canonical {
+				format = prettyx.CanonicalTo
+			} else if *compact {
+				format = prettyx.CompactTo
+			}
+			err = streamUngron(path, &opts, in, format)
+		case *canonical:
+			err = streamCanonical(path, &opts, in)
+		case *compact:
+			err = streamCompact(path, &opts, in)
+		default:
+			err = streamPretty(path, &opts, in)
 		}
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "prettyx: %v\n", err)
+			reportError(os.Stderr, err)
 			os.Exit(1)
 		}
 	}
+	if skipped > 0 {
+		fmt.Fprintf(os.Stderr, "prettyx: skipped %d malformed document(s)\n", skipped)
+		os.Exit(exitSkipped)
+	}
+}
+
+// exitSkipped is the exit status when --recover skipped any documents.
+const exitSkipped = 3
+
+func sourceName(path string) string {
+	if path == "-" {
+		return "<stdin>"
+	}
+	return path
+}
+
+func parseKeyOrder(name string) (prettyx.KeyOrder, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "", "none":
+		return prettyx.KeyOrderInput, nil
+	case "bytes":
+		return prettyx.KeyOrderBytes, nil
+	case "utf16":
+		return prettyx.KeyOrderUTF16, nil
+	default:
+		return prettyx.KeyOrderInput, fmt.Errorf("unknown --sort-keys order %q (use bytes or utf16)", name)
+	}
+}
+
+func parseLogLayout(name string) (prettyx.LogLayout, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "", "off", "none":
+		return prettyx.LogOff, nil
+	case "tree":
+		return prettyx.LogTree, nil
+	case "console":
+		return prettyx.LogConsole, nil
+	default:
+		return prettyx.LogOff, fmt.Errorf("unknown --log layout %q (use tree or console)", name)
+	}
+}
+
+func parseDialect(name string) (prettyx.Dialect, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "", "json":
+		return prettyx.DialectJSON, nil
+	case "jsonc":
+		return prettyx.DialectJSONC, nil
+	case "json5":
+		return prettyx.DialectJSON5, nil
+	default:
+		return prettyx.DialectJSON, fmt.Errorf("unknown --input-dialect %q (use json, jsonc or json5)", name)
+	}
+}
+
+func parseOutputFormat(name string) (prettyx.OutputFormat, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "", "json":
+		return prettyx.OutputJSON, nil
+	case "yaml", "yml":
+		return prettyx.OutputYAML, nil
+	case "cbor":
+		return prettyx.OutputCBOR, nil
+	case "msgpack", "mpk":
+		return prettyx.OutputMsgPack, nil
+	case "csv":
+		return prettyx.OutputCSV, nil
+	case "tsv":
+		return prettyx.OutputTSV, nil
+	case "table":
+		return prettyx.OutputTable, nil
+	default:
+		return prettyx.OutputJSON, fmt.Errorf("unknown --output format %q (use json, yaml, cbor, msgpack, table, csv or tsv)", name)
+	}
+}
+
+// buildRedactor compiles the --redact flags. It returns nil when none were
+// given; with defaults set, or only hash, the other rules add to
+// prettyx.DefaultRedactRules. Digests are always keyed, with a random key
+// when hashKey is empty, since an unkeyed digest of a guessable secret can be
+// reversed by hashing candidates.
+func buildRedactor(defaults bool, keys, paths, values []string, hash bool, hashKey string) (*prettyx.Redactor, error) {
+	if len(keys) == 0 && len(paths) == 0 && len(values) == 0 {
+		if !defaults && !hash {
+			return nil, nil
+		}
+		defaults = true
+	}
+	rules := prettyx.RedactRules{Hash: hash}
+	if hash {
+		rules.HashKey = []byte(hashKey)
+		if hashKey == "" {
+			rules.HashKey = make([]byte, 32)
+			if _, err := rand.Read(rules.HashKey); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if defaults {
+		rules.Keys = append(rules.Keys, prettyx.DefaultRedactRules.Keys...)
+		rules.Values = append(rules.Values, prettyx.DefaultRedactRules.Values...)
+	}
+	rules.Keys = append(rules.Keys, keys...)
+	rules.Paths = append(rules.Paths, paths...)
+	rules.Values = append(rules.Values, values...)
+	return prettyx.NewRedactor(&rules)
+}
+
+func binaryOutput(format prettyx.OutputFormat) bool {
+	return format == prettyx.OutputCBOR || format == prettyx.OutputMsgPack
+}
+
+// parseInputFormat returns the input format and whether it is picked per
+// input from the file extension.
+func parseInputFormat(name string) (prettyx.InputFormat, bool, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "", "auto":
+		return prettyx.InputJSON, true, nil
+	case "json":
+		return prettyx.InputJSON, false, nil
+	case "yaml", "yml":
+		return prettyx.InputYAML, false, nil
+	case "toml":
+		return prettyx.InputTOML, false, nil
+	case "csv":
+		return prettyx.InputCSV, false, nil
+	case "cbor":
+		return prettyx.InputCBOR, false, nil
+	case "msgpack", "mpk":
+		return prettyx.InputMsgPack, false, nil
+	default:
+		return prettyx.InputJSON, false, fmt.Errorf("unknown --input-format %q (use auto, json, yaml, toml, csv, cbor or msgpack)", name)
+	}
+}
+
+// reportError prints err and, for syntax errors, the offending input line with
+// a caret under the byte the error points at.
+func reportError(w io.Writer, err error) {
+	fmt.Fprintf(w, "prettyx: %v\n", err)
+	var se *prettyx.SyntaxError
+	if !errors.As(err, &se) || se.Excerpt == "" {
+		return
+	}
+	var line, caret strings.Builder
+	for i, r := range se.Excerpt {
+		pad := ' '
+		switch {
+		case r == '\t':
+			pad = '\t'
+		case r < 0x20 || r == 0x7f:
+			r = '.'
+		}
+		line.WriteRune(r)
+		if i < se.ExcerptOffset {
+			caret.WriteRune(pad)
+		}
+	}
+	fmt.Fprintf(w, "  %s\n  %s^\n", line.String(), caret.String())
 }
 
-func streamPretty(path string, opts *prettyx.Options, urlOpts urlOptions) error {
-	reader, closer, err := openInput(path, urlOpts)
+func streamPretty(path string, opts *prettyx.Options, in inputOptions) error {
+	reader, closer, err := openInput(path, in)
 	if err != nil {
 		return err
 	}
 	if closer != nil {
 		defer closer.Close()
 	}
-	source := path
-	if path == "-" {
-		source = "<stdin>"
-	}
 	if err := prettyx.PrettyStream(os.Stdout, reader, opts); err != nil {
-		return fmt.Errorf("%s: %w", source, err)
+		return fmt.Errorf("%s: %w", sourceName(path), err)
+	}
+	return nil
+}
+
+func streamCanonical(path string, opts *prettyx.Options, in inputOptions) error {
+	reader, closer, err := openInput(path, in)
+	if err != nil {
+		return err
+	}
+	if closer != nil {
+		defer closer.Close()
+	}
+	if err := prettyx.CanonicalTo(os.Stdout, reader, opts); err != nil {
+		return fmt.Errorf("%s: %w", sourceName(path), err)
 	}
 	return nil
 }
 
-func streamCompact(path string, opts *prettyx.Options, urlOpts urlOptions) error {
-	reader, closer, err := openInput(path, urlOpts)
+func streamCompact(path string, opts *prettyx.Options, in inputOptions) error {
+	reader, closer, err := openInput(path, in)
 	if err != nil {
 		return err
 	}
@@ -110,11 +429,28 @@ func streamCompact(path string, opts *prettyx.Options, urlOpts urlOptions) error
 		defer closer.Close()
 	}
 	if err := prettyx.CompactTo(os.Stdout, reader, opts); err != nil {
-		source := path
-		if path == "-" {
-			source = "<stdin>"
-		}
-		return fmt.Errorf("%s: %w", source, err)
+		return fmt.Errorf("%s: %w", sourceName(path), err)
+	}
+	return nil
+}
+
+// streamUngron rebuilds JSON from the gron-style lines at path and formats
+// it with format.
+func streamUngron(path string, opts *prettyx.Options, in inputOptions, format func(io.Writer, io.Reader, *prettyx.Options) error) error {
+	reader, closer, err := openInput(path, in)
+	if err != nil {
+		return err
+	}
+	if closer != nil {
+		defer closer.Close()
+	}
+	pr, pw := io.Pipe()
+	defer pr.Close()
+	go func() {
+		_ = pw.CloseWithError(prettyx.Unflatten(pw, reader))
+	}()
+	if err := format(os.Stdout, pr, opts); err != nil {
+		return fmt.Errorf("%s: %w", sourceName(path), err)
 	}
 	return nil
 }
@@ -126,25 +462,41 @@ type urlOptions struct {
 	acceptAll bool
 }
 
-func openInput(path string, urlOpts urlOptions) (io.Reader, io.Closer, error) {
+// inputOptions controls how inputs are opened: how URLs are fetched and
+// which format is converted to JSON. With detectFormat set the format comes
+// from the file or URL path's extension, and stdin is read as format.
+type inputOptions struct {
+	urlOptions
+	format       prettyx.InputFormat
+	detectFormat bool
+}
+
+func openInput(path string, in inputOptions) (io.Reader, io.Closer, error) {
+	format := in.format
 	if path == "-" {
-		return os.Stdin, nil, nil
+		return prettyx.ConvertReader(os.Stdin, format), nil, nil
 	}
 	if parsedURL, isURL, err := parseHTTPURL(path); isURL {
 		if err != nil {
 			return nil, nil, fmt.Errorf("%s: %w", path, err)
 		}
-		reader, closer, err := openURL(parsedURL, urlOpts)
+		reader, closer, err := openURL(parsedURL, in.urlOptions)
 		if err != nil {
 			return nil, nil, fmt.Errorf("%s: %w", path, err)
 		}
-		return reader, closer, nil
+		if in.detectFormat {
+			format = prettyx.InputFormatForPath(parsedURL.Path)
+		}
+		return prettyx.ConvertReader(reader, format), closer, nil
 	}
 	file, err := os.Open(path)
 	if err != nil {
 		return nil, nil, fmt.Errorf("%s: %w", path, err)
 	}
-	return file, file, nil
+	if in.detectFormat {
+		format = prettyx.InputFormatForPath(path)
+	}
+	return prettyx.ConvertReader(file, format), file, nil
 }
 
 func parseHTTPURL(rawURL string) (*url.URL, bool, error) {
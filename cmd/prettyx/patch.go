@@ -0,0 +1,167 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/spf13/pflag"
+
+	"pkt.systems/prettyx"
+)
+
+// runPatch implements "prettyx patch OLD NEW", which prints the JSON Patch
+// or, with --merge, the JSON Merge Patch that turns OLD into NEW.
+func runPatch(args []string, stdout, stderr io.Writer) int {
+	flags := pflag.NewFlagSet("prettyx patch", pflag.ContinueOnError)
+	flags.SetOutput(stderr)
+	input := addInputFlags(flags)
+	output := addOutputFlags(flags)
+	merge := flags.Bool("merge", false, "generate an RFC 7396 JSON Merge Patch instead of an RFC 6902 JSON Patch")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: prettyx patch [flags] OLD NEW")
+		fmt.Fprintln(flags.Output(), "Prints the JSON Patch (or merge patch) that turns OLD into NEW.")
+		flags.PrintDefaults()
+	}
+	opts, in, code := parseSubcommand(flags, args, input, output, 2, stderr)
+	if code >= 0 {
+		return code
+	}
+
+	var docs [2]bytes.Buffer
+	for i, path := range flags.Args() {
+		if err := compactInput(&docs[i], path, withRepairReport(opts, path, stderr), in); err != nil {
+			reportError(stderr, err)
+			return 1
+		}
+	}
+	// The inputs are compact JSON now; only the formatting options apply.
+	read := prettyx.Options{}
+	generate, generateTo := prettyx.JSONPatch, prettyx.JSONPatchTo
+	if *merge {
+		generate, generateTo = prettyx.MergePatch, prettyx.MergePatchTo
+	}
+	var err error
+	if *output.compact {
+		var patch []byte
+		if patch, err = generate(&docs[0], &docs[1], &read); err == nil {
+			err = prettyx.CompactTo(stdout, bytes.NewReader(patch), &prettyx.Options{SortKeys: opts.SortKeys})
+		}
+	} else {
+		format := *opts
+		format.Unwrap = false
+		format.Repair = false
+		format.Dialect = prettyx.DialectJSON
+		err = generateTo(stdout, &docs[0], &docs[1], &format)
+	}
+	if err != nil {
+		reportError(stderr, err)
+		return 1
+	}
+	return 0
+}
+
+// runApply implements "prettyx apply PATCH [FILE...]", which applies a JSON
+// Patch or, with --merge, a JSON Merge Patch to every document of each file
+// and prints the results.
+func runApply(args []string, stdout, stderr io.Writer) int {
+	flags := pflag.NewFlagSet("prettyx apply", pflag.ContinueOnError)
+	flags.SetOutput(stderr)
+	input := addInputFlags(flags)
+	output := addOutputFlags(flags)
+	merge := flags.Bool("merge", false, "apply an RFC 7396 JSON Merge Patch instead of an RFC 6902 JSON Patch")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: prettyx apply [flags] PATCH [file_or_url...]")
+		fmt.Fprintln(flags.Output(), "Applies PATCH to every document of each input (stdin when none are given).")
+		flags.PrintDefaults()
+	}
+	opts, in, code := parseSubcommand(flags, args, input, output, -1, stderr)
+	if code >= 0 {
+		return code
+	}
+	args = flags.Args()
+	if len(args) == 0 {
+		flags.Usage()
+		return 2
+	}
+
+	// The patch is read as JSON in the format its name or --input-format
+	// gives, without unwrapping or repair.
+	var patch bytes.Buffer
+	if err := compactInput(&patch, args[0], &prettyx.Options{Dialect: opts.Dialect}, in); err != nil {
+		reportError(stderr, err)
+		return 1
+	}
+	files := args[1:]
+	if len(files) == 0 {
+		files = []string{"-"}
+	}
+	apply, applyTo := prettyx.ApplyJSONPatch, prettyx.ApplyJSONPatchTo
+	if *merge {
+		apply, applyTo = prettyx.ApplyMergePatch, prettyx.ApplyMergePatchTo
+	}
+	for _, path := range files {
+		reader, closer, err := openInput(path, in)
+		if err != nil {
+			reportError(stderr, err)
+			return 1
+		}
+		fileOpts := withRepairReport(opts, path, stderr)
+		if *output.compact {
+			var out []byte
+			if out, err = apply(reader, bytes.NewReader(patch.Bytes()), fileOpts); err == nil {
+				err = prettyx.CompactTo(stdout, bytes.NewReader(out), &prettyx.Options{SortKeys: opts.SortKeys})
+			}
+		} else {
+			err = applyTo(stdout, reader, bytes.NewReader(patch.Bytes()), fileOpts)
+		}
+		if closer != nil {
+			closer.Close()
+		}
+		if err != nil {
+			reportError(stderr, fmt.Errorf("%s: %w", sourceName(path), err))
+			return 1
+		}
+	}
+	return 0
+}
+
+// parseSubcommand parses args and builds the options of a subcommand that
+// prints JSON. It returns an exit status >= 0 when the command should stop;
+// nargs, when not negative, is the required number of arguments.
+func parseSubcommand(flags *pflag.FlagSet, args []string, input *inputFlags, output *outputFlags, nargs int, stderr io.Writer) (*prettyx.Options, inputOptions, int) {
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, pflag.ErrHelp) {
+			return nil, inputOptions{}, 0
+		}
+		fmt.Fprintf(stderr, "prettyx: %v\n", err)
+		return nil, inputOptions{}, 2
+	}
+	if nargs >= 0 && flags.NArg() != nargs {
+		flags.Usage()
+		return nil, inputOptions{}, 2
+	}
+	opts, in, err := input.options()
+	if err == nil {
+		err = output.apply(&opts)
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "prettyx: %v\n", err)
+		return nil, inputOptions{}, 2
+	}
+	return &opts, in, -1
+}
+
+// withRepairReport returns a copy of opts that reports repairs to path on
+// stderr when repair is enabled.
+func withRepairReport(opts *prettyx.Options, path string, stderr io.Writer) *prettyx.Options {
+	o := *opts
+	if o.Repair {
+		source := sourceName(path)
+		o.OnRepair = func(r prettyx.Repair) {
+			fmt.Fprintf(stderr, "prettyx: %s: %s\n", source, r)
+		}
+	}
+	return &o
+}
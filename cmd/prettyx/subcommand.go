@@ -0,0 +1,198 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/mattn/go-isatty"
+	"github.com/spf13/pflag"
+
+	"pkt.systems/prettyx"
+)
+
+// inputFlags are the flags the subcommands share with the main command for
+// choosing colours and reading documents.
+type inputFlags struct {
+	forceColor  *bool
+	noColor     *bool
+	palette     *string
+	unwrap      *unwrapFlags
+	repair      *bool
+	dialect     *string
+	inputFormat *string
+	insecure    *bool
+	acceptAll   *bool
+}
+
+// unwrapFlags are -u and the flags that limit which strings it decodes.
+type unwrapFlags struct {
+	enabled   *bool
+	depth     *int
+	minLength *int
+	keys      *[]string
+	skipKeys  *[]string
+	decoders  *[]string
+	jwt       *string
+}
+
+func addUnwrapFlags(flags *pflag.FlagSet) *unwrapFlags {
+	f := &unwrapFlags{
+		enabled:   flags.BoolP("unwrap", "u", false, "decode JSON-looking strings recursively"),
+		depth:     flags.Int("unwrap-depth", 0, "decode at most this many levels of JSON inside strings (0 uses the default of 10; implies -u)"),
+		minLength: flags.Int("unwrap-min-length", 0, "leave strings shorter than this many bytes undecoded (implies -u)"),
+		keys:      flags.StringArray("unwrap-key", nil, "decode only strings inside members whose name matches a glob (case-insensitive, repeatable; implies -u)"),
+		skipKeys:  flags.StringArray("unwrap-skip-key", nil, "never decode strings inside members whose name matches a glob (case-insensitive, repeatable; implies -u)"),
+		decoders:  flags.StringSlice("unwrap-decode", nil, "also decode JSON hidden in strings: base64 (and base64url), gzip (after base64), url (percent-encoding and form bodies) or all (default when given; implies -u)"),
+	}
+	flags.Lookup("unwrap-decode").NoOptDefVal = "all"
+	f.jwt = flags.String("unwrap-jwt", "decode", "show JWTs as decoded header and claims (decode), also with exp/iat/nbf as times (times; implies -u), or as strings (keep)")
+	return f
+}
+
+// apply sets the Unwrap options. Any of the other flags turns unwrapping on,
+// since they mean nothing without it.
+func (f *unwrapFlags) apply(opts *prettyx.Options) error {
+	decoders, err := parseUnwrapDecoders(*f.decoders)
+	if err != nil {
+		return err
+	}
+	jwt, err := parseJWTMode(*f.jwt)
+	if err != nil {
+		return err
+	}
+	opts.UnwrapJWT = jwt
+	opts.UnwrapDepth = *f.depth
+	opts.UnwrapMinLength = *f.minLength
+	opts.UnwrapKeys = *f.keys
+	opts.UnwrapSkipKeys = *f.skipKeys
+	opts.UnwrapDecoders = decoders
+	opts.Unwrap = *f.enabled || opts.UnwrapDepth > 0 || opts.UnwrapMinLength > 0 ||
+		len(opts.UnwrapKeys) > 0 || len(opts.UnwrapSkipKeys) > 0 || len(decoders) > 0 ||
+		jwt == prettyx.JWTDecodeTimes
+	return nil
+}
+
+func parseJWTMode(name string) (prettyx.JWTMode, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "", "decode":
+		return prettyx.JWTDecode, nil
+	case "times":
+		return prettyx.JWTDecodeTimes, nil
+	case "keep", "off":
+		return prettyx.JWTKeep, nil
+	default:
+		return prettyx.JWTDecode, fmt.Errorf("unknown --unwrap-jwt mode %q (use decode, times or keep)", name)
+	}
+}
+
+func parseUnwrapDecoders(names []string) ([]prettyx.UnwrapDecoder, error) {
+	var decoders []prettyx.UnwrapDecoder
+	for _, name := range names {
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "all":
+			decoders = append(decoders, prettyx.DefaultUnwrapDecoders...)
+		case "base64", "base64url":
+			decoders = append(decoders, prettyx.DecodeBase64)
+		case "gzip":
+			decoders = append(decoders, prettyx.DecodeGzip)
+		case "url":
+			decoders = append(decoders, prettyx.DecodeURLEncoded)
+		default:
+			return nil, fmt.Errorf("unknown --unwrap-decode decoder %q (use base64, gzip, url or all)", name)
+		}
+	}
+	return decoders, nil
+}
+
+func addInputFlags(flags *pflag.FlagSet) *inputFlags {
+	return &inputFlags{
+		forceColor:  flags.BoolP("color-force", "C", false, "force colorized output even when writing to a non-TTY"),
+		noColor:     flags.Bool("no-color", false, "disable colorized output, even when writing to a TTY"),
+		palette:     flags.String("palette", "default", "palette name (use prettyx --list-palettes to see options)"),
+		unwrap:      addUnwrapFlags(flags),
+		repair:      flags.Bool("repair", false, "fix truncated and sloppy JSON and report each fix on stderr"),
+		dialect:     flags.String("input-dialect", "json", "input syntax: json, jsonc (comments, trailing commas) or json5"),
+		inputFormat: flags.String("input-format", "auto", "input format: auto (by file extension), json, yaml, toml, csv, cbor or msgpack"),
+		insecure:    flags.BoolP("insecure", "k", false, "allow insecure HTTPS connections for URL inputs (skip TLS verification)"),
+		acceptAll:   flags.Bool("accept-all", false, "send Accept: */* when fetching URLs (default sends JSON-focused Accept header)"),
+	}
+}
+
+// options returns the Options and inputOptions the flags select.
+func (f *inputFlags) options() (prettyx.Options, inputOptions, error) {
+	opts := *prettyx.DefaultOptions
+	if err := f.unwrap.apply(&opts); err != nil {
+		return opts, inputOptions{}, err
+	}
+	opts.ForceColor = *f.forceColor
+	opts.Palette = *f.palette
+	if *f.noColor {
+		opts.Palette = "none"
+	}
+	opts.Repair = *f.repair
+	dialect, err := parseDialect(*f.dialect)
+	if err != nil {
+		return opts, inputOptions{}, err
+	}
+	opts.Dialect = dialect
+	format, detect, err := parseInputFormat(*f.inputFormat)
+	if err != nil {
+		return opts, inputOptions{}, err
+	}
+	in := inputOptions{
+		urlOptions: urlOptions{
+			insecure:  *f.insecure,
+			acceptAll: *f.acceptAll,
+		},
+		format:       format,
+		detectFormat: detect,
+	}
+	return opts, in, nil
+}
+
+// outputFlags are the formatting flags of the subcommands that print JSON.
+type outputFlags struct {
+	compact     *bool
+	semiCompact *bool
+	width       *int
+	sortKeys    *string
+	output      *string
+	forceBinary *bool
+}
+
+func addOutputFlags(flags *pflag.FlagSet) *outputFlags {
+	f := &outputFlags{
+		compact:     flags.BoolP("compact", "c", false, "compact output (one document per line, no color)"),
+		semiCompact: flags.Bool("semi-compact", false, "use tidwall-style semi-compact formatting (soft wraps to --width)"),
+		width:       flags.IntP("width", "w", prettyx.DefaultOptions.Width, "soft wrap width for --semi-compact (<= 0 always wraps)"),
+		sortKeys:    flags.StringP("sort-keys", "S", "", "sort object keys recursively: bytes (default when given) or utf16"),
+		output:      flags.StringP("output", "o", "json", "output format: json, yaml, cbor, msgpack, table, csv or tsv"),
+		forceBinary: flags.Bool("force-binary", false, "write --output cbor or msgpack even when stdout is a terminal"),
+	}
+	flags.Lookup("sort-keys").NoOptDefVal = "bytes"
+	return f
+}
+
+// apply sets the formatting options the flags select.
+func (f *outputFlags) apply(opts *prettyx.Options) error {
+	opts.SemiCompact = *f.semiCompact
+	opts.Width = *f.width
+	keyOrder, err := parseKeyOrder(*f.sortKeys)
+	if err != nil {
+		return err
+	}
+	opts.SortKeys = keyOrder
+	format, err := parseOutputFormat(*f.output)
+	if err != nil {
+		return err
+	}
+	if format != prettyx.OutputJSON && *f.compact {
+		return fmt.Errorf("--output %s cannot be combined with --compact", *f.output)
+	}
+	if binaryOutput(format) && !*f.forceBinary && isatty.IsTerminal(os.Stdout.Fd()) {
+		return fmt.Errorf("refusing to write %s to a terminal (redirect stdout or use --force-binary)", *f.output)
+	}
+	opts.Output = format
+	return nil
+}
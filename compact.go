@@ -1,9 +1,11 @@
 package prettyx
 
 import (
+	"bufio"
 	"bytes"
 	"errors"
 	"io"
+	"strings"
 
 	"pkt.systems/jpact"
 )
@@ -11,17 +13,31 @@ import (
 // CompactTo streams compacted JSON to the provided writer. It supports multiple
 // JSON documents in the input stream, emitting one compacted document per line.
 // When opts.Unwrap is true, JSON-looking strings are decoded recursively before
-// compaction.
+// compaction. When opts.SortKeys is set, object keys are sorted as well.
+// When opts.Passthrough is set, text around the JSON in each line is kept, and
+// when opts.Recover is set, malformed documents are skipped. opts.Repair fixes
+// up truncated and sloppy input, and opts.Dialect accepts JSONC or JSON5.
+// When opts.Path is set, only the values it selects are written, and
+// opts.Flatten and opts.Output select another output format, opts.Redact
+// masks secrets, and opts.Log set to LogConsole writes console log lines.
 func CompactTo(w io.Writer, r io.Reader, opts *Options) error {
 	if opts == nil {
 		opts = DefaultOptions
 	}
+	if opts.Output == OutputCBOR || opts.Output == OutputMsgPack {
+		return encodeBinary(w, r, opts, opts.Output)
+	}
+	if isTableOutput(opts.Output) {
+		return writeTable(w, r, opts, NoColorPalette())
+	}
+	if opts.SortKeys != KeyOrderInput || opts.Passthrough || opts.Recover != nil || opts.Repair ||
+		opts.Dialect != DialectJSON || opts.Path != nil || opts.Flatten ||
+		opts.Output != OutputJSON || opts.Redact != nil || opts.Log != LogOff ||
+		(opts.Unwrap && (len(opts.UnwrapKeys) > 0 || len(opts.UnwrapSkipKeys) > 0)) {
+		return compactParsed(w, r, opts)
+	}
 	if opts.Unwrap {
-		depth := MaxNestedJSONDepth
-		if depth <= 0 {
-			depth = 1
-		}
-		return compactWithUnwrap(w, r, opts, depth)
+		return compactWithUnwrap(w, r, opts, unwrapDepth(opts))
 	}
 	return compactRaw(w, r)
 }
@@ -50,15 +66,16 @@ func compactRaw(w io.Writer, r io.Reader) error {
 	vr := acquireValueReader(r)
 	defer releaseValueReader(vr)
 
-	for {
+	for doc := 1; ; doc++ {
 		if err := vr.Start(); err != nil {
 			if errors.Is(err, io.EOF) {
 				return nil
 			}
 			return err
 		}
-		if err := jpact.CompactWriter(w, vr, 0); err != nil {
-			return err
+		vr.sink.w = w
+		if err := jpact.CompactWriter(&vr.sink, vr, 0); err != nil {
+			return vr.docError(err, doc)
 		}
 		if err := writeNewline(w); err != nil {
 			return err
@@ -67,15 +84,11 @@ func compactRaw(w io.Writer, r io.Reader) error {
 	}
 }
 
-func compactWithUnwrap(w io.Writer, r io.Reader, _ *Options, _ int) error {
+func compactWithUnwrap(w io.Writer, r io.Reader, opts *Options, depth int) error {
 	vr := acquireValueReader(r)
 	defer releaseValueReader(vr)
-	depth := MaxNestedJSONDepth
-	if depth <= 0 {
-		depth = 1
-	}
 
-	for {
+	for doc := 1; ; doc++ {
 		if err := vr.Start(); err != nil {
 			if errors.Is(err, io.EOF) {
 				return nil
@@ -84,9 +97,14 @@ func compactWithUnwrap(w io.Writer, r io.Reader, _ *Options, _ int) error {
 		}
 
 		ur := acquireUnwrapReader(vr, depth)
-		if err := jpact.CompactWriter(w, ur, 0); err != nil {
+		ur.policy = newUnwrapPolicy(opts)
+		vr.sink.w = w
+		if err := jpact.CompactWriter(&vr.sink, ur, 0); err != nil {
+			if ur.err != nil {
+				err = ur.err
+			}
 			releaseUnwrapReader(ur)
-			return err
+			return vr.docError(err, doc)
 		}
 		releaseUnwrapReader(ur)
 
@@ -97,9 +115,38 @@ func compactWithUnwrap(w io.Writer, r io.Reader, _ *Options, _ int) error {
 	}
 }
 
+// compactParsed runs the streaming parser in compact mode for options jpact
+// does not implement, such as sorted keys and passthrough. The formatter
+// writes byte by byte, so output is buffered here.
+func compactParsed(w io.Writer, r io.Reader, opts *Options) error {
+	o := *opts
+	o.Prefix = ""
+	o.Indent = ""
+	bw := bufio.NewWriter(w)
+	if err := streamPretty(bw, r, &o, NoColorPalette(), true); err != nil {
+		_ = bw.Flush()
+		return err
+	}
+	return bw.Flush()
+}
+
 type valueReader struct {
 	scanner scanner
 
+	// sink wraps the destination so write failures can be told apart from
+	// syntax errors reported by the compactor.
+	sink errRecorder
+	// err keeps the first read failure from the underlying reader.
+	err error
+	// rec holds the current document (up to maxScratchCap bytes) so a failure
+	// can be replayed through the parser to locate it precisely. start* is
+	// the stream position of the document's first byte.
+	rec       []byte
+	recOver   bool
+	startOff  int64
+	startLine int
+	startCol  int
+
 	started bool
 	done    bool
 	mode    valueMode
@@ -127,6 +174,10 @@ func (v *valueReader) Reset() {
 	v.escape = false
 	v.hasPend = false
 	v.pending = 0
+	v.sink = errRecorder{}
+	v.err = nil
+	v.rec = v.rec[:0]
+	v.recOver = false
 }
 
 func (v *valueReader) Start() error {
@@ -140,6 +191,7 @@ func (v *valueReader) Start() error {
 	v.started = true
 	v.pending = b
 	v.hasPend = true
+	v.startOff, v.startLine, v.startCol = v.scanner.position(v.scanner.pos - 1)
 	switch b {
 	case '{', '[':
 		v.mode = modeStruct
@@ -173,20 +225,94 @@ func (v *valueReader) Read(p []byte) (int, error) {
 	for n < len(p) {
 		b, err := v.nextByte()
 		if err != nil {
+			v.record(p[:n])
 			if errors.Is(err, io.EOF) {
 				if n == 0 {
 					return 0, io.EOF
 				}
 				return n, nil
 			}
+			if v.err == nil {
+				v.err = err
+			}
 			return n, err
 		}
 		p[n] = b
 		n++
 	}
+	v.record(p[:n])
 	return n, nil
 }
 
+func (v *valueReader) record(b []byte) {
+	if v.recOver {
+		return
+	}
+	if len(v.rec)+len(b) > maxScratchCap {
+		v.recOver = true
+		return
+	}
+	v.rec = append(v.rec, b...)
+}
+
+// docError turns a failure while compacting the current document into a
+// *SyntaxError positioned in the input stream whenever possible.
+func (v *valueReader) docError(err error, doc int) error {
+	if v.sink.err != nil {
+		return v.sink.err
+	}
+	if v.err != nil {
+		return v.err
+	}
+	var se *SyntaxError
+	if !errors.As(err, &se) {
+		se = v.replay()
+	}
+	if se == nil {
+		// The document was too large to replay; report where reading stopped.
+		se = newSyntaxError(&v.scanner, v.scanner.pos, doc, "%s", strings.TrimPrefix(err.Error(), "json: "))
+		return se
+	}
+	se.rebase(v.startOff, v.startLine, v.startCol)
+	se.Document = doc
+	return se
+}
+
+// replay parses the recorded document to find the first syntax error. It
+// returns nil when nothing was recorded or the recording parses cleanly.
+func (v *valueReader) replay() *SyntaxError {
+	if v.recOver {
+		return nil
+	}
+	p := acquireParser()
+	defer releaseParser(p)
+	p.sliceReader.Reset(v.rec)
+	p.reset(&p.sliceReader, io.Discard, nil, ColorPalette{}, true)
+	err := p.parseValue(0)
+	if err == io.EOF {
+		err = p.errorfNext("unexpected end of input")
+	}
+	var se *SyntaxError
+	if errors.As(err, &se) {
+		return se
+	}
+	return nil
+}
+
+// errRecorder forwards writes and remembers the first failure.
+type errRecorder struct {
+	w   io.Writer
+	err error
+}
+
+func (e *errRecorder) Write(p []byte) (int, error) {
+	n, err := e.w.Write(p)
+	if err != nil && e.err == nil {
+		e.err = err
+	}
+	return n, err
+}
+
 func (v *valueReader) nextByte() (byte, error) {
 	if v.done {
 		return 0, io.EOF
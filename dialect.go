@@ -0,0 +1,274 @@
+package prettyx
+
+import (
+	"io"
+	"math/big"
+	"unicode/utf8"
+)
+
+// Dialect selects the input syntax accepted by the parser. Output is always
+// strict JSON, apart from comments kept with Options.KeepComments.
+type Dialect int
+
+const (
+	// DialectJSON accepts strict RFC 8259 JSON. This is the default.
+	DialectJSON Dialect = iota
+	// DialectJSONC adds // and /* */ comments and trailing commas.
+	DialectJSONC
+	// DialectJSON5 adds the rest of JSON5 to JSONC: single-quoted strings,
+	// unquoted keys, the extra JSON5 escapes and line continuations, and
+	// hexadecimal, signed and dot-leading or dot-trailing numbers as well as
+	// Infinity and NaN. Infinity is written as ±1.7976931348623157e+308 and
+	// NaN as null, as jq does.
+	DialectJSON5
+)
+
+const maxFloat64Text = "1.7976931348623157e+308"
+
+// dialectAllows reports whether kind is valid syntax in the input dialect
+// rather than something Repair has to fix.
+func (p *parser) dialectAllows(kind RepairKind) bool {
+	switch kind {
+	case RepairTrailingComma:
+		return p.dialect != DialectJSON
+	case RepairSingleQuotes, RepairUnquotedKey:
+		return p.dialect == DialectJSON5
+	default:
+		return false
+	}
+}
+
+// extendedKeys reports whether object keys may be single quoted or bare.
+func (p *parser) extendedKeys() bool {
+	return p.repair || p.dialect == DialectJSON5
+}
+
+// skipSpace is scanner.skipSpace that also skips comments in the JSONC and
+// JSON5 dialects.
+func (p *parser) skipSpace() error {
+	for {
+		if err := p.scanner.skipSpace(); err != nil {
+			return err
+		}
+		if p.dialect == DialectJSON {
+			return nil
+		}
+		if b, _ := p.scanner.peekByte(); b != '/' {
+			return nil
+		}
+		if err := p.skipComment(); err != nil {
+			return err
+		}
+	}
+}
+
+func (p *parser) readNonSpace() (byte, error) {
+	if err := p.skipSpace(); err != nil {
+		return 0, err
+	}
+	return p.scanner.readByte()
+}
+
+// skipComment consumes the comment at the scanner position, recording it
+// when comments are kept.
+func (p *parser) skipComment() error {
+	_, _ = p.scanner.readByte()
+	kind, err := p.scanner.readByte()
+	if err == io.EOF {
+		return p.errorfNext("unterminated comment")
+	}
+	if err != nil {
+		return err
+	}
+	if kind != '/' && kind != '*' {
+		return p.errorf("invalid comment")
+	}
+	if p.keepComment {
+		p.commentBuf = append(p.commentBuf, '/', kind)
+	}
+	var prev byte
+	for {
+		b, err := p.scanner.readByte()
+		if err == io.EOF && kind == '/' {
+			break
+		}
+		if err == io.EOF {
+			return p.errorfNext("unterminated comment")
+		}
+		if err != nil {
+			return err
+		}
+		if kind == '/' && b == '\n' {
+			break
+		}
+		if p.keepComment {
+			p.commentBuf = append(p.commentBuf, b)
+		}
+		if kind == '*' && prev == '*' && b == '/' {
+			break
+		}
+		prev = b
+	}
+	if p.keepComment {
+		if n := len(p.commentBuf); kind == '/' && n > 0 && p.commentBuf[n-1] == '\r' {
+			p.commentBuf = p.commentBuf[:n-1]
+		}
+		p.commentEnds = append(p.commentEnds, len(p.commentBuf))
+	}
+	return nil
+}
+
+// writeComments writes the kept comments before a token at depth, each on a
+// line of its own. When reindent is set the indentation for the token that
+// follows is written too.
+func (p *parser) writeComments(depth int, reindent bool) error {
+	if len(p.commentEnds) == 0 {
+		return nil
+	}
+	f := p.formatter
+	start := 0
+	for _, end := range p.commentEnds {
+		if err := f.ensureLineStart(depth); err != nil {
+			return err
+		}
+		if err := f.writeStyledBytes(f.pal.Comment, p.commentBuf[start:end]); err != nil {
+			return err
+		}
+		if err := f.writeByte('\n'); err != nil {
+			return err
+		}
+		f.lineLen = 0
+		start = end
+	}
+	p.dropComments()
+	if reindent {
+		return f.writeIndent(depth)
+	}
+	return nil
+}
+
+// writeClosingComments writes the kept comments that precede a closing
+// bracket on their own lines inside the container.
+func (p *parser) writeClosingComments(depth int) error {
+	f := p.formatter
+	start := 0
+	for _, end := range p.commentEnds {
+		if err := f.newline(depth); err != nil {
+			return err
+		}
+		if err := f.writeStyledBytes(f.pal.Comment, p.commentBuf[start:end]); err != nil {
+			return err
+		}
+		start = end
+	}
+	p.dropComments()
+	return nil
+}
+
+func (p *parser) dropComments() {
+	p.commentBuf = p.commentBuf[:0]
+	p.commentEnds = p.commentEnds[:0]
+}
+
+// readJSON5Escape decodes the JSON5 escapes JSON lacks. Any other character
+// escapes itself, except for the digits 1-9.
+func (p *parser) readJSON5Escape(esc byte) error {
+	switch {
+	case esc == 'v':
+		p.decodedBuf = append(p.decodedBuf, '\v')
+	case esc == '0':
+		p.decodedBuf = append(p.decodedBuf, 0)
+	case esc == 'x':
+		var r rune
+		for i := 0; i < 2; i++ {
+			b, err := p.scanner.readByte()
+			if err != nil {
+				return err
+			}
+			if !isHex(b) {
+				return p.errorf("invalid hex escape")
+			}
+			r = r<<4 | rune(fromHex(b))
+		}
+		p.decodedBuf = utf8.AppendRune(p.decodedBuf, r)
+	case esc == '\n':
+		// Line continuation.
+	case esc == '\r':
+		if b, err := p.scanner.peekByte(); err == nil && b == '\n' {
+			_, _ = p.scanner.readByte()
+		}
+	case esc >= '1' && esc <= '9':
+		return p.errorf("invalid escape sequence")
+	default:
+		p.decodedBuf = append(p.decodedBuf, esc)
+	}
+	return nil
+}
+
+// parseJSON5Number reads a JSON5 number and writes it as a JSON number.
+func (p *parser) parseJSON5Number(first byte) error {
+	p.scratch = append(p.scratch[:0], first)
+	for {
+		b, err := p.scanner.peekByte()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if isTerminator(b) || b == '/' {
+			break
+		}
+		_, _ = p.scanner.readByte()
+		p.scratch = append(p.scratch, b)
+	}
+
+	tok := p.scratch
+	out := p.decodedBuf[:0]
+	switch tok[0] {
+	case '-':
+		out = append(out, '-')
+		tok = tok[1:]
+	case '+':
+		tok = tok[1:]
+	}
+	switch {
+	case string(tok) == "NaN":
+		return p.formatter.writeLiteral("null", p.formatter.pal.Null)
+	case string(tok) == "Infinity":
+		out = append(out, maxFloat64Text...)
+	case len(tok) > 2 && tok[0] == '0' && (tok[1] == 'x' || tok[1] == 'X'):
+		var n big.Int
+		if _, ok := n.SetString(string(tok[2:]), 16); !ok {
+			return p.errorf("invalid number")
+		}
+		out = n.Append(out, 10)
+	default:
+		if len(tok) > 0 && tok[0] == '.' {
+			out = append(out, '0')
+		}
+		for i, c := range tok {
+			out = append(out, c)
+			if c == '.' && (i+1 == len(tok) || tok[i+1] < '0' || tok[i+1] > '9') {
+				out = append(out, '0')
+			}
+		}
+	}
+	p.decodedBuf = out
+
+	digits := out
+	if len(digits) > 0 && digits[0] == '-' {
+		digits = digits[1:]
+	}
+	if len(digits) == 0 {
+		return p.errorf("invalid number")
+	}
+	state, ok := numStartState(digits[0])
+	for i := 1; ok && i < len(digits); i++ {
+		state, ok = numNextState(state, digits[i])
+	}
+	if !ok || !numIsTerminal(state) {
+		return p.errorf("invalid number")
+	}
+	return p.formatter.writeStyledBytes(p.formatter.pal.Number, out)
+}
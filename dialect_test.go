@@ -0,0 +1,135 @@
+package prettyx
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+const json5Input = `// config
+{
+  // the name
+  name: 'app', /* inline */ port: 0x1F90,
+  ratio: .5, big: -Infinity, nan: NaN, list: [+1, 2, /* last */],
+  'multi': 'a\
+b\x41\v\'"',
+  trail: 5.,
+  empty: { /* nothing */ },
+}
+// end`
+
+func TestDialect_JSON5StripsToStrictJSON(t *testing.T) {
+	opts := *DefaultOptions
+	opts.Dialect = DialectJSON5
+	out, err := CompactToBuffer(strings.NewReader(json5Input), &opts)
+	if err != nil {
+		t.Fatalf("CompactToBuffer failed: %v", err)
+	}
+	want := `{"name":"app","port":8080,"ratio":0.5,"big":-1.7976931348623157e+308,"nan":null,"list":[1,2],"multi":"abA\u000b'\"","trail":5.0,"empty":{}}` + "\n"
+	if string(out) != want {
+		t.Fatalf("unexpected output\nexpected:\n%s\nactual:\n%s", want, out)
+	}
+
+	opts.SortKeys = KeyOrderBytes
+	out, err = CompactToBuffer(strings.NewReader(`{b: 1, 'a': 0x10,}`), &opts)
+	if err != nil || string(out) != `{"a":16,"b":1}`+"\n" {
+		t.Fatalf("unexpected sorted output %q, %v", out, err)
+	}
+}
+
+func TestDialect_KeepComments(t *testing.T) {
+	opts := *DefaultOptions
+	opts.Palette = "none"
+	opts.Dialect = DialectJSON5
+	opts.KeepComments = true
+	var buf bytes.Buffer
+	if err := PrettyStream(&buf, strings.NewReader(json5Input), &opts); err != nil {
+		t.Fatalf("PrettyStream failed: %v", err)
+	}
+	want := `// config
+{
+  // the name
+  "name": "app",
+  /* inline */
+  "port": 8080,
+  "ratio": 0.5,
+  "big": -1.7976931348623157e+308,
+  "nan": null,
+  "list": [
+    1,
+    2
+    /* last */
+  ],
+  "multi": "abA\u000b'\"",
+  "trail": 5.0,
+  "empty": {
+    /* nothing */
+  }
+}
+// end
+`
+	if buf.String() != want {
+		t.Fatalf("unexpected output\nexpected:\n%s\nactual:\n%s", want, buf.String())
+	}
+
+	opts.ForceColor = true
+	opts.Palette = "default"
+	got, err := Pretty([]byte("[1 // one\r\n]"), &opts)
+	if err != nil {
+		t.Fatalf("Pretty failed: %v", err)
+	}
+	pal, _ := resolvePalette(&opts, true)
+	if pal.Comment == "" || !bytes.Contains(got, []byte(pal.Comment+"// one\x1b[0m\n")) {
+		t.Fatalf("expected styled comment, got %q", got)
+	}
+}
+
+func TestDialect_JSONC(t *testing.T) {
+	opts := *DefaultOptions
+	opts.Dialect = DialectJSONC
+	out, err := CompactToBuffer(strings.NewReader("/* a */ {\"a\": [1, 2,], // b\n \"b\": {},}\n// tail"), &opts)
+	if err != nil || string(out) != `{"a":[1,2],"b":{}}`+"\n" {
+		t.Fatalf("unexpected output %q, %v", out, err)
+	}
+
+	var kinds []RepairKind
+	opts.Repair = true
+	opts.OnRepair = func(r Repair) { kinds = append(kinds, r.Kind) }
+	out, err = CompactToBuffer(strings.NewReader(`{'a': [1,],`), &opts)
+	if err != nil || string(out) != `{"a":[1]}`+"\n" {
+		t.Fatalf("unexpected repaired output %q, %v", out, err)
+	}
+	if len(kinds) != 2 || kinds[0] != RepairSingleQuotes || kinds[1] != RepairUnclosedObject {
+		t.Fatalf("expected only non-JSONC repairs to be reported, got %v", kinds)
+	}
+}
+
+func TestDialect_Errors(t *testing.T) {
+	cases := []struct {
+		dialect Dialect
+		input   string
+		msg     string
+	}{
+		{DialectJSONC, `{a: 1}`, "expected object key"},
+		{DialectJSONC, `[1 /* open`, "unterminated comment"},
+		{DialectJSONC, `[1 / 2]`, "invalid comment"},
+		{DialectJSON5, `[0xZZ]`, "invalid number"},
+		{DialectJSON5, `[1.2.3]`, "invalid number"},
+		{DialectJSON5, `['\1']`, "invalid escape sequence"},
+		{DialectJSON5, `['\xZ1']`, "invalid hex escape"},
+		{DialectJSON5, `[Nope]`, "invalid number"},
+		{DialectJSON5, `[True]`, "unexpected character 'T'"},
+		{DialectJSON, `[1,]`, "unexpected character ']'"},
+		{DialectJSON, `// c` + "\n1", "unexpected character '/'"},
+	}
+	for _, tc := range cases {
+		opts := *DefaultOptions
+		opts.Dialect = tc.dialect
+		var se *SyntaxError
+		if err := PrettyStream(io.Discard, strings.NewReader(tc.input), &opts); !errors.As(err, &se) || se.Msg != tc.msg {
+			t.Fatalf("dialect %d %q: expected %q, got %v", tc.dialect, tc.input, tc.msg, err)
+		}
+	}
+}
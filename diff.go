@@ -0,0 +1,688 @@
+package prettyx
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// ChangeKind classifies a Change.
+type ChangeKind int
+
+const (
+	// ChangeAdded is a member or element only present in the new document.
+	ChangeAdded ChangeKind = iota + 1
+	// ChangeRemoved is a member or element only present in the old document.
+	ChangeRemoved
+	// ChangeChanged is a value that differs in type or, for scalars, in
+	// value.
+	ChangeChanged
+	// ChangeMoved is an array element found unchanged at another index.
+	// It is only reported when DiffOptions.Moves is set.
+	ChangeMoved
+	// ChangeReordered is an object whose shared members appear in a
+	// different order. It is not reported when DiffOptions.IgnoreKeyOrder
+	// is set.
+	ChangeReordered
+)
+
+// String returns the lower-case name of the kind.
+func (k ChangeKind) String() string {
+	switch k {
+	case ChangeAdded:
+		return "added"
+	case ChangeRemoved:
+		return "removed"
+	case ChangeChanged:
+		return "changed"
+	case ChangeMoved:
+		return "moved"
+	case ChangeReordered:
+		return "reordered"
+	default:
+		return "ChangeKind(" + strconv.Itoa(int(k)) + ")"
+	}
+}
+
+// Change is one difference between two documents. Paths use the jq-style
+// syntax that ParsePath accepts, with "." for the whole document.
+type Change struct {
+	Kind ChangeKind
+	// OldPath locates the value in the old document and NewPath in the new
+	// one; OldPath is empty for ChangeAdded and NewPath for ChangeRemoved.
+	// They differ when array elements before the value were added or
+	// removed.
+	OldPath string
+	NewPath string
+	// Old and New hold the values as compact JSON, under the same rules as
+	// the paths. For ChangeReordered they are arrays of the shared keys in
+	// each document's order; for ChangeMoved both are unset.
+	Old json.RawMessage
+	New json.RawMessage
+}
+
+// DiffLayout selects how WriteDiff prints changes.
+type DiffLayout int
+
+const (
+	// DiffUnified prints each change under its path with the old value on
+	// lines starting with '-' and the new value on lines starting with '+'.
+	DiffUnified DiffLayout = iota
+	// DiffSideBySide prints the old and new values in two columns, divided
+	// by '|' for changed values and '<' or '>' for removed and added ones,
+	// as diff -y does.
+	DiffSideBySide
+)
+
+// DiffOptions controls Diff and WriteDiff.
+type DiffOptions struct {
+	// Options decides how both documents are read, as for CompactTo: with
+	// Unwrap, differences inside strings that hold JSON are reported at
+	// paths below the string. WriteDiff also takes the palette, colour and
+	// indentation from it. Nil uses DefaultOptions.
+	Options *Options
+	// IgnoreKeyOrder compares objects as unordered sets of members.
+	IgnoreKeyOrder bool
+	// Moves reports array elements that appear unchanged at another index
+	// as ChangeMoved instead of a removal and an addition.
+	Moves bool
+	// Layout selects the output of WriteDiff.
+	Layout DiffLayout
+	// Width is the line width of the side-by-side layout. Values that do
+	// not fit their column are cut short. When <= 0, 120 is used.
+	Width int
+	// Ignore lists paths whose values are left out of the comparison, such
+	// as timestamps and generated IDs. Object members they select are
+	// dropped from both documents; array elements they select compare as
+	// equal but still count when elements are added or removed.
+	Ignore []*Path
+}
+
+// maxDiffEdits bounds the work spent aligning two arrays. Arrays that need
+// more insertions and deletions than this are compared index by index after
+// their common prefix and suffix.
+const maxDiffEdits = 4096
+
+// Diff reads one JSON document from each of a and b and returns the changes
+// that turn the old document a into the new document b, in document order.
+// Objects are compared member by member and arrays are aligned on their
+// longest common subsequence, so an insertion is reported once rather than
+// as a change to every later element. Numbers are equal when their decimal
+// values are, so 1.0 equals 1. The documents are parsed with opts.Options;
+// a reader that holds no document or more than one is an error.
+func Diff(a, b io.Reader, opts *DiffOptions) ([]Change, error) {
+	if opts == nil {
+		opts = &DiffOptions{}
+	}
+	readOpts := opts.Options
+	if readOpts == nil {
+		readOpts = DefaultOptions
+	}
+	oldDoc, err := readOneDocument(a, readOpts, "diff: old input")
+	if err != nil {
+		return nil, err
+	}
+	newDoc, err := readOneDocument(b, readOpts, "diff: new input")
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range opts.Ignore {
+		oldDoc = pruneIgnored(oldDoc, path.segs)
+		newDoc = pruneIgnored(newDoc, path.segs)
+	}
+	d := &differ{opts: opts}
+	d.compare(".", ".", oldDoc, newDoc)
+	return d.changes, nil
+}
+
+// readOneDocument parses the single document in r; name describes r in the
+// error returned when it holds none or several.
+func readOneDocument(r io.Reader, opts *Options, name string) (*inputNode, error) {
+	errDocuments := errors.New(name + " must hold exactly one JSON document")
+	var doc *inputNode
+	err := compactDocuments(r, opts, func(line []byte) error {
+		if doc != nil {
+			return errDocuments
+		}
+		var err error
+		doc, err = decodeJSONLine(line)
+		return err
+	})
+	if err == nil && doc == nil {
+		err = errDocuments
+	}
+	return doc, err
+}
+
+// pruneIgnored returns n without the values segs select: matching members
+// are removed and matching elements become null.
+func pruneIgnored(n *inputNode, segs []pathSegment) *inputNode {
+	if len(segs) == 0 {
+		return &inputNode{kind: inputNull}
+	}
+	seg := &segs[0]
+	switch n.kind {
+	case inputObject:
+		keys, items := n.keys[:0], n.items[:0]
+		for i, key := range n.keys {
+			item := n.items[i]
+			if seg.wildcard || (seg.hasKey && key == seg.key) {
+				if len(segs) == 1 {
+					continue
+				}
+				item = pruneIgnored(item, segs[1:])
+			}
+			keys = append(keys, key)
+			items = append(items, item)
+		}
+		n.keys, n.items = keys, items
+	case inputArray:
+		for i, item := range n.items {
+			if seg.wildcard || seg.index == i {
+				n.items[i] = pruneIgnored(item, segs[1:])
+			}
+		}
+	}
+	return n
+}
+
+type differ struct {
+	opts    *DiffOptions
+	changes []Change
+}
+
+func (d *differ) add(kind ChangeKind, oldPath, newPath string, a, b *inputNode) {
+	c := Change{Kind: kind, OldPath: oldPath, NewPath: newPath}
+	if a != nil {
+		c.Old = appendInputJSON(nil, a)
+	}
+	if b != nil {
+		c.New = appendInputJSON(nil, b)
+	}
+	d.changes = append(d.changes, c)
+}
+
+func (d *differ) compare(oldPath, newPath string, a, b *inputNode) {
+	switch {
+	case a.kind != b.kind:
+		d.add(ChangeChanged, oldPath, newPath, a, b)
+	case a.kind == inputObject:
+		d.compareObjects(oldPath, newPath, a, b)
+	case a.kind == inputArray:
+		d.compareArrays(oldPath, newPath, a, b)
+	case !d.equal(a, b):
+		d.add(ChangeChanged, oldPath, newPath, a, b)
+	}
+}
+
+func (d *differ) compareObjects(oldPath, newPath string, a, b *inputNode) {
+	oldIndex, newIndex := memberIndex(a), memberIndex(b)
+	if !d.opts.IgnoreKeyOrder {
+		oldOrder := &inputNode{kind: inputArray}
+		for i, key := range a.keys {
+			if _, ok := newIndex[key]; ok && oldIndex[key] == i {
+				oldOrder.items = append(oldOrder.items, &inputNode{kind: inputString, text: key})
+			}
+		}
+		newOrder := &inputNode{kind: inputArray}
+		for i, key := range b.keys {
+			if _, ok := oldIndex[key]; ok && newIndex[key] == i {
+				newOrder.items = append(newOrder.items, &inputNode{kind: inputString, text: key})
+			}
+		}
+		if !d.equal(oldOrder, newOrder) {
+			d.add(ChangeReordered, oldPath, newPath, oldOrder, newOrder)
+		}
+	}
+	for i, key := range a.keys {
+		if oldIndex[key] != i {
+			continue
+		}
+		if j, ok := newIndex[key]; ok {
+			d.compare(memberPath(oldPath, key), memberPath(newPath, key), a.items[i], b.items[j])
+		} else {
+			d.add(ChangeRemoved, memberPath(oldPath, key), "", a.items[i], nil)
+		}
+	}
+	for j, key := range b.keys {
+		if _, ok := oldIndex[key]; !ok && newIndex[key] == j {
+			d.add(ChangeAdded, "", memberPath(newPath, key), nil, b.items[j])
+		}
+	}
+}
+
+// memberIndex maps each key of obj to the index of its first occurrence;
+// later duplicates are ignored.
+func memberIndex(obj *inputNode) map[string]int {
+	index := make(map[string]int, len(obj.keys))
+	for i, key := range obj.keys {
+		if _, ok := index[key]; !ok {
+			index[key] = i
+		}
+	}
+	return index
+}
+
+// compareArrays aligns the elements of a and b on their longest common
+// subsequence. Elements between two aligned pairs are compared index by
+// index, and whatever is left over on one side was removed or added. With
+// Moves set, unaligned elements that equal one on the other side are paired
+// first and reported as moved.
+func (d *differ) compareArrays(oldPath, newPath string, a, b *inputNode) {
+	oldKeys := make([]string, len(a.items))
+	for i, item := range a.items {
+		oldKeys[i] = d.key(item)
+	}
+	newKeys := make([]string, len(b.items))
+	for i, item := range b.items {
+		newKeys[i] = d.key(item)
+	}
+	pairs := alignSequences(oldKeys, newKeys)
+	// A sentinel pair past both ends closes the last gap.
+	pairs = append(pairs, [2]int{len(oldKeys), len(newKeys)})
+
+	moved := make(map[int]int)
+	movedTo := make(map[int]bool)
+	if d.opts.Moves {
+		unmatched := make(map[string][]int)
+		x := 0
+		for _, p := range pairs {
+			for ; x < p[0]; x++ {
+				unmatched[oldKeys[x]] = append(unmatched[oldKeys[x]], x)
+			}
+			x = p[0] + 1
+		}
+		y := 0
+		for _, p := range pairs {
+			for ; y < p[1]; y++ {
+				if olds := unmatched[newKeys[y]]; len(olds) > 0 {
+					moved[olds[0]] = y
+					movedTo[y] = true
+					unmatched[newKeys[y]] = olds[1:]
+				}
+			}
+			y = p[1] + 1
+		}
+	}
+
+	x, y := 0, 0
+	for _, p := range pairs {
+		var olds, news []int
+		for ; x < p[0]; x++ {
+			if _, ok := moved[x]; !ok {
+				olds = append(olds, x)
+			}
+		}
+		for ; y < p[1]; y++ {
+			if !movedTo[y] {
+				news = append(news, y)
+			}
+		}
+		n := min(len(olds), len(news))
+		for i := 0; i < n; i++ {
+			d.compare(indexPath(oldPath, olds[i]), indexPath(newPath, news[i]), a.items[olds[i]], b.items[news[i]])
+		}
+		for _, i := range olds[n:] {
+			d.add(ChangeRemoved, indexPath(oldPath, i), "", a.items[i], nil)
+		}
+		for _, j := range news[n:] {
+			d.add(ChangeAdded, "", indexPath(newPath, j), nil, b.items[j])
+		}
+		x, y = p[0]+1, p[1]+1
+	}
+	if len(moved) > 0 {
+		froms := make([]int, 0, len(moved))
+		for i := range moved {
+			froms = append(froms, i)
+		}
+		slices.Sort(froms)
+		for _, i := range froms {
+			d.changes = append(d.changes, Change{Kind: ChangeMoved, OldPath: indexPath(oldPath, i), NewPath: indexPath(newPath, moved[i])})
+		}
+	}
+}
+
+// equal reports whether a and b hold the same value.
+func (d *differ) equal(a, b *inputNode) bool {
+	return d.key(a) == d.key(b)
+}
+
+// key returns a string that is the same for equal values: compact JSON with
+// numbers in a normal form and, when key order is ignored, sorted members.
+func (d *differ) key(n *inputNode) string {
+	return string(d.appendKey(nil, n))
+}
+
+func (d *differ) appendKey(dst []byte, n *inputNode) []byte {
+	switch n.kind {
+	case inputNumber:
+		mant, exp := decimalParts(n.text)
+		dst = append(dst, mant...)
+		dst = append(dst, 'e')
+		return strconv.AppendInt(dst, int64(exp), 10)
+	case inputObject:
+		order := make([]int, len(n.keys))
+		for i := range order {
+			order[i] = i
+		}
+		if d.opts.IgnoreKeyOrder {
+			slices.SortStableFunc(order, func(i, j int) int { return strings.Compare(n.keys[i], n.keys[j]) })
+		}
+		dst = append(dst, '{')
+		for i, m := range order {
+			if i > 0 {
+				dst = append(dst, ',')
+			}
+			dst = appendJSONString(dst, n.keys[m])
+			dst = append(dst, ':')
+			dst = d.appendKey(dst, n.items[m])
+		}
+		return append(dst, '}')
+	case inputArray:
+		dst = append(dst, '[')
+		for i, item := range n.items {
+			if i > 0 {
+				dst = append(dst, ',')
+			}
+			dst = d.appendKey(dst, item)
+		}
+		return append(dst, ']')
+	default:
+		return appendInputJSON(dst, n)
+	}
+}
+
+// memberPath appends .key, or ["key"] when key is not an identifier.
+func memberPath(path, key string) string {
+	if path == "." {
+		path = ""
+	}
+	if isIdentifier([]byte(key)) {
+		return path + "." + key
+	}
+	return path + "[" + strconv.Quote(key) + "]"
+}
+
+func indexPath(path string, i int) string {
+	return path + "[" + strconv.Itoa(i) + "]"
+}
+
+// alignSequences returns the index pairs of a longest common subsequence of
+// a and b, found with Myers' O(ND) algorithm. When more than maxDiffEdits
+// edits are needed, only the common prefix and suffix are aligned.
+func alignSequences(a, b []string) [][2]int {
+	var pairs [][2]int
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		pairs = append(pairs, [2]int{prefix, prefix})
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+	ma, mb := a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]
+	for _, p := range myersPairs(ma, mb) {
+		pairs = append(pairs, [2]int{p[0] + prefix, p[1] + prefix})
+	}
+	for i := suffix; i > 0; i-- {
+		pairs = append(pairs, [2]int{len(a) - i, len(b) - i})
+	}
+	return pairs
+}
+
+func myersPairs(a, b []string) [][2]int {
+	n, m := len(a), len(b)
+	if n == 0 || m == 0 {
+		return nil
+	}
+	limit := min(n+m, maxDiffEdits)
+	offset := limit + 1
+	v := make([]int, 2*limit+3)
+	// trace[d] holds v[k] for k in [-d, d] after step d.
+	var trace [][]int
+	for d := 0; d <= limit; d++ {
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				trace = append(trace, slices.Clone(v[offset-d:offset+d+1]))
+				return backtrackMyers(trace, n, m)
+			}
+		}
+		trace = append(trace, slices.Clone(v[offset-d:offset+d+1]))
+	}
+	return nil
+}
+
+func backtrackMyers(trace [][]int, n, m int) [][2]int {
+	var pairs [][2]int
+	x, y := n, m
+	for d := len(trace) - 1; d > 0; d-- {
+		prev := trace[d-1]
+		at := func(k int) int { return prev[k+d-1] }
+		k := x - y
+		prevK := k - 1
+		if k == -d || (k != d && at(k-1) < at(k+1)) {
+			prevK = k + 1
+		}
+		prevX := at(prevK)
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			x--
+			y--
+			pairs = append(pairs, [2]int{x, y})
+		}
+		x, y = prevX, prevY
+	}
+	for x > 0 && y > 0 {
+		x--
+		y--
+		pairs = append(pairs, [2]int{x, y})
+	}
+	slices.Reverse(pairs)
+	return pairs
+}
+
+// WriteDiff prints changes in the layout chosen by opts. Values are
+// pretty-printed and coloured with the palette of opts.Options; the change
+// markers use its Info, Error and Warn colours for added, removed and
+// changed values.
+func WriteDiff(w io.Writer, changes []Change, opts *DiffOptions) error {
+	if opts == nil {
+		opts = &DiffOptions{}
+	}
+	base := opts.Options
+	if base == nil {
+		base = DefaultOptions
+	}
+	pal, err := resolvePalette(base, shouldColor(w, base))
+	if err != nil {
+		return err
+	}
+	o := Options{Indent: base.Indent, Prefix: base.Prefix, SemiCompact: base.SemiCompact, Width: base.Width}
+	dw := &diffWriter{w: bufio.NewWriter(w), opts: &o, pal: pal}
+	if opts.Layout == DiffSideBySide {
+		dw.width = opts.Width
+		if dw.width <= 0 {
+			dw.width = 120
+		}
+	}
+	for i := range changes {
+		if err := dw.change(&changes[i], opts.Layout); err != nil {
+			return err
+		}
+	}
+	return dw.w.Flush()
+}
+
+type diffWriter struct {
+	w     *bufio.Writer
+	opts  *Options
+	pal   ColorPalette
+	width int
+	line  []byte
+}
+
+func (dw *diffWriter) change(c *Change, layout DiffLayout) error {
+	marker, style := "~", dw.pal.Warn
+	switch c.Kind {
+	case ChangeAdded:
+		marker, style = "+", dw.pal.Info
+	case ChangeRemoved:
+		marker, style = "-", dw.pal.Error
+	case ChangeMoved:
+		marker, style = ">", dw.pal.Debug
+	}
+	dw.line = appendStyled(dw.line[:0], style, marker)
+	dw.line = append(dw.line, ' ')
+	path := c.OldPath
+	if path == "" {
+		path = c.NewPath
+	}
+	dw.line = appendStyled(dw.line, dw.pal.Key, path)
+	if c.OldPath != "" && c.NewPath != "" && c.OldPath != c.NewPath {
+		dw.line = appendStyled(dw.line, dw.pal.Punctuation, " -> ")
+		dw.line = appendStyled(dw.line, dw.pal.Key, c.NewPath)
+	}
+	if c.Kind == ChangeReordered {
+		dw.line = appendStyled(dw.line, dw.pal.Punctuation, " (key order)")
+	}
+	if err := dw.writeLine(); err != nil {
+		return err
+	}
+	if layout == DiffSideBySide {
+		return dw.sideBySide(c)
+	}
+	if err := dw.unified(c.Old, "-", dw.pal.Error); err != nil {
+		return err
+	}
+	return dw.unified(c.New, "+", dw.pal.Info)
+}
+
+func (dw *diffWriter) unified(value json.RawMessage, sign, style string) error {
+	if value == nil {
+		return nil
+	}
+	lines, _, err := dw.render(value, dw.pal)
+	if err != nil {
+		return err
+	}
+	for _, l := range lines {
+		dw.line = append(dw.line[:0], "  "...)
+		dw.line = appendStyled(dw.line, style, sign)
+		dw.line = append(dw.line, ' ')
+		dw.line = append(dw.line, l...)
+		if err := dw.writeLine(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sideBySide writes the old value on the left and the new one on the right.
+// Lines longer than their column are cut short and lose their colour.
+func (dw *diffWriter) sideBySide(c *Change) error {
+	if c.Old == nil && c.New == nil {
+		return nil
+	}
+	col := max((dw.width-3)/2, 1)
+	left, leftPlain, err := dw.renderColumn(c.Old, col)
+	if err != nil {
+		return err
+	}
+	right, _, err := dw.renderColumn(c.New, col)
+	if err != nil {
+		return err
+	}
+	sep, style := "|", dw.pal.Warn
+	switch {
+	case c.Old == nil:
+		sep, style = ">", dw.pal.Info
+	case c.New == nil:
+		sep, style = "<", dw.pal.Error
+	}
+	for i := 0; i < max(len(left), len(right)); i++ {
+		dw.line = dw.line[:0]
+		pad := col
+		if i < len(left) {
+			dw.line = append(dw.line, left[i]...)
+			pad -= utf8.RuneCountInString(leftPlain[i])
+		}
+		dw.line = appendSpaces(dw.line, pad)
+		dw.line = append(dw.line, ' ')
+		dw.line = appendStyled(dw.line, style, sep)
+		dw.line = append(dw.line, ' ')
+		if i < len(right) {
+			dw.line = append(dw.line, right[i]...)
+		}
+		if err := dw.writeLine(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// renderColumn renders value for a column of width col, returning the lines
+// to print and their uncoloured text.
+func (dw *diffWriter) renderColumn(value json.RawMessage, col int) ([]string, []string, error) {
+	if value == nil {
+		return nil, nil, nil
+	}
+	lines, plain, err := dw.render(value, dw.pal)
+	if err != nil {
+		return nil, nil, err
+	}
+	for i, l := range plain {
+		if utf8.RuneCountInString(l) <= col {
+			continue
+		}
+		runes := []rune(l)
+		plain[i] = string(runes[:col-1]) + "…"
+		lines[i] = plain[i]
+	}
+	return lines, plain, nil
+}
+
+// render pretty-prints value and returns its lines with and without colour.
+func (dw *diffWriter) render(value json.RawMessage, pal ColorPalette) ([]string, []string, error) {
+	var buf bytes.Buffer
+	if err := streamPretty(&buf, bytes.NewReader(value), dw.opts, pal, false); err != nil {
+		return nil, nil, err
+	}
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if pal == (ColorPalette{}) {
+		return lines, slices.Clone(lines), nil
+	}
+	buf.Reset()
+	if err := streamPretty(&buf, bytes.NewReader(value), dw.opts, NoColorPalette(), false); err != nil {
+		return nil, nil, err
+	}
+	plain := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(plain) != len(lines) {
+		return plain, slices.Clone(plain), nil
+	}
+	return lines, plain, nil
+}
+
+func (dw *diffWriter) writeLine() error {
+	dw.line = append(bytes.TrimRight(dw.line, " "), '\n')
+	_, err := dw.w.Write(dw.line)
+	return err
+}
@@ -0,0 +1,215 @@
+package prettyx
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func diffStrings(t *testing.T, a, b string, opts *DiffOptions) []string {
+	t.Helper()
+	changes, err := Diff(strings.NewReader(a), strings.NewReader(b), opts)
+	if err != nil {
+		t.Fatalf("Diff failed: %v", err)
+	}
+	var got []string
+	for _, c := range changes {
+		got = append(got, c.Kind.String()+" "+c.OldPath+" "+c.NewPath+" "+string(c.Old)+" "+string(c.New))
+	}
+	return got
+}
+
+func TestDiffObjects(t *testing.T) {
+	got := diffStrings(t,
+		`{"name":"web","replicas":3,"gone":{"a":1},"odd key":true,"n":1.0}`,
+		`{"name":"api","replicas":3,"odd key":false,"n":1,"new":[1]}`,
+		&DiffOptions{IgnoreKeyOrder: true})
+	want := []string{
+		"changed .name .name \"web\" \"api\"",
+		"removed .gone  {\"a\":1} ",
+		"changed [\"odd key\"] [\"odd key\"] true false",
+		"added  .new  [1]",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected changes\nexpected: %q\nactual:   %q", want, got)
+	}
+}
+
+func TestDiffKeyOrder(t *testing.T) {
+	a, b := `{"a":1,"b":{"x":1,"y":2}}`, `{"a":1,"b":{"y":2,"x":1}}`
+	got := diffStrings(t, a, b, nil)
+	want := []string{`reordered .b .b ["x","y"] ["y","x"]`}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected changes\nexpected: %q\nactual:   %q", want, got)
+	}
+	if got := diffStrings(t, a, b, &DiffOptions{IgnoreKeyOrder: true}); len(got) != 0 {
+		t.Fatalf("expected no changes, got %q", got)
+	}
+}
+
+func TestDiffArrays(t *testing.T) {
+	a := `[{"id":1},{"id":2},{"id":3},"x"]`
+	b := `[{"id":0},{"id":1},{"id":2},{"id":3,"v":true}]`
+	got := diffStrings(t, a, b, nil)
+	want := []string{
+		"added  .[0]  {\"id\":0}",
+		"added  .[3].v  true",
+		"removed .[3]  \"x\" ",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected changes\nexpected: %q\nactual:   %q", want, got)
+	}
+}
+
+func TestDiffMoves(t *testing.T) {
+	a, b := `{"c":[1,2,3,4]}`, `{"c":[4,1,2,3]}`
+	got := diffStrings(t, a, b, nil)
+	want := []string{"added  .c[0]  4", "removed .c[3]  4 "}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected changes\nexpected: %q\nactual:   %q", want, got)
+	}
+	got = diffStrings(t, a, b, &DiffOptions{Moves: true})
+	want = []string{"moved .c[3] .c[0]  "}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected changes\nexpected: %q\nactual:   %q", want, got)
+	}
+}
+
+func TestDiffLongArrays(t *testing.T) {
+	var a, b strings.Builder
+	a.WriteString("[")
+	b.WriteString("[")
+	for i := 0; i < 3*maxDiffEdits; i++ {
+		if i > 0 {
+			a.WriteString(",")
+			b.WriteString(",")
+		}
+		a.WriteString(strings.Repeat("1", i%7+1))
+		b.WriteString(strings.Repeat("2", i%5+1))
+	}
+	a.WriteString(",0]")
+	b.WriteString(",0]")
+	changes, err := Diff(strings.NewReader(a.String()), strings.NewReader(b.String()), nil)
+	if err != nil {
+		t.Fatalf("Diff failed: %v", err)
+	}
+	if len(changes) != 3*maxDiffEdits || changes[1].OldPath != ".[1]" || changes[1].NewPath != ".[1]" {
+		t.Fatalf("expected index by index changes, got %d starting %+v", len(changes), changes[:2])
+	}
+}
+
+func TestDiffUnwrap(t *testing.T) {
+	a, b := `{"cfg":"{\"x\":1,\"y\":[1,2]}"}`, `{"cfg":"{\"x\":1,\"y\":[1,3]}"}`
+	got := diffStrings(t, a, b, &DiffOptions{Options: &Options{Unwrap: true}})
+	if want := []string{"changed .cfg.y[1] .cfg.y[1] 2 3"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected changes\nexpected: %q\nactual:   %q", want, got)
+	}
+	got = diffStrings(t, a, b, nil)
+	if len(got) != 1 || !strings.HasPrefix(got[0], "changed .cfg .cfg ") {
+		t.Fatalf("unexpected changes without Unwrap: %q", got)
+	}
+}
+
+func TestDiffIgnore(t *testing.T) {
+	a := `{"id":"1","at":"2026-01-01","items":[{"id":7,"n":1},{"id":8,"n":2}],"meta":{"rev":1}}`
+	b := `{"id":"2","items":[{"id":9,"n":1},{"id":10,"n":3}],"meta":{"rev":2},"at":"2026-02-02"}`
+	opts := &DiffOptions{IgnoreKeyOrder: true}
+	for _, s := range []string{"/id", ".at", ".items[].id", "/meta/rev"} {
+		path, err := ParsePath(s)
+		if err != nil {
+			t.Fatalf("ParsePath(%q) failed: %v", s, err)
+		}
+		opts.Ignore = append(opts.Ignore, path)
+	}
+	got := diffStrings(t, a, b, opts)
+	if want := []string{"changed .items[1].n .items[1].n 2 3"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected changes\nexpected: %q\nactual:   %q", want, got)
+	}
+	root, _ := ParsePath("")
+	if got := diffStrings(t, a, b, &DiffOptions{Ignore: []*Path{root}}); len(got) != 0 {
+		t.Fatalf("ignoring the root still reported %q", got)
+	}
+}
+
+func TestDiffDocumentCount(t *testing.T) {
+	for _, tc := range []struct{ a, b, want string }{
+		{"", "1", "old input"},
+		{"1", "1 2", "new input"},
+	} {
+		_, err := Diff(strings.NewReader(tc.a), strings.NewReader(tc.b), nil)
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Fatalf("Diff(%q, %q): expected error about %s, got %v", tc.a, tc.b, tc.want, err)
+		}
+	}
+	if _, err := Diff(strings.NewReader("{"), strings.NewReader("1"), nil); err == nil {
+		t.Fatalf("expected syntax error")
+	}
+}
+
+func TestWriteDiff(t *testing.T) {
+	a := `{"n":3,"tags":["a"],"gone":{"k":"v"}}`
+	b := `{"n":5,"tags":["a","b"]}`
+	opts := &DiffOptions{Options: &Options{Indent: "  ", Palette: "none"}}
+	changes, err := Diff(strings.NewReader(a), strings.NewReader(b), opts)
+	if err != nil {
+		t.Fatalf("Diff failed: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := WriteDiff(&buf, changes, opts); err != nil {
+		t.Fatalf("WriteDiff failed: %v", err)
+	}
+	want := `~ .n
+  - 3
+  + 5
++ .tags[1]
+  + "b"
+- .gone
+  - {
+  -   "k": "v"
+  - }
+`
+	if buf.String() != want {
+		t.Fatalf("unexpected unified output\nexpected:\n%s\nactual:\n%s", want, buf.String())
+	}
+
+	buf.Reset()
+	opts.Layout = DiffSideBySide
+	opts.Width = 23
+	changes = append(changes, Change{Kind: ChangeChanged, OldPath: ".s", NewPath: ".s", Old: []byte(`"abcdefghijkl"`), New: []byte(`"x"`)})
+	if err := WriteDiff(&buf, changes, opts); err != nil {
+		t.Fatalf("WriteDiff failed: %v", err)
+	}
+	want = `~ .n
+3          | 5
++ .tags[1]
+           > "b"
+- .gone
+{          <
+  "k": "v" <
+}          <
+~ .s
+"abcdefgh… | "x"
+`
+	if buf.String() != want {
+		t.Fatalf("unexpected side-by-side output\nexpected:\n%s\nactual:\n%s", want, buf.String())
+	}
+}
+
+func TestWriteDiffColor(t *testing.T) {
+	opts := &DiffOptions{Options: &Options{Indent: "  ", ForceColor: true}}
+	pal, err := resolvePalette(opts.Options, true)
+	if err != nil {
+		t.Fatalf("resolvePalette failed: %v", err)
+	}
+	var buf bytes.Buffer
+	changes := []Change{{Kind: ChangeAdded, NewPath: ".a", New: []byte(`1`)}}
+	if err := WriteDiff(&buf, changes, opts); err != nil {
+		t.Fatalf("WriteDiff failed: %v", err)
+	}
+	for _, want := range []string{pal.Info + "+\x1b[0m " + pal.Key + ".a\x1b[0m", pal.Number + "1\x1b[0m"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Fatalf("expected %q in %q", want, buf.String())
+		}
+	}
+}
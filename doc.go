@@ -24,6 +24,9 @@
 //		log.Fatal(err)
 //	}
 //
+// Malformed input is reported as a *SyntaxError with the byte offset, line,
+// column and document index of the offending byte.
+//
 // Semi-compact formatting:
 //
 //	opts := &prettyx.Options{SemiCompact: true, Width: 80, Palette: "none"}
@@ -0,0 +1,69 @@
+package prettyx
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+)
+
+// Encoder writes Go values to an output stream as formatted JSON, like
+// encoding/json's Encoder but through prettyx's formatter. Colour is decided
+// once, when the encoder is created, from the writer and options just as
+// PrettyStream decides it. An Encoder is not safe for concurrent use.
+type Encoder struct {
+	w       io.Writer
+	opts    Options
+	pal     ColorPalette
+	err     error
+	compact bool
+	buf     bytes.Buffer
+	enc     *json.Encoder
+}
+
+// NewEncoder returns an encoder that writes to w with opts. Nil opts uses
+// DefaultOptions. The options are copied, so later changes to opts do not
+// affect the encoder.
+func NewEncoder(w io.Writer, opts *Options) *Encoder {
+	if opts == nil {
+		opts = DefaultOptions
+	}
+	e := &Encoder{w: w, opts: *opts}
+	e.pal, e.err = resolvePalette(&e.opts, shouldColor(w, &e.opts))
+	e.enc = json.NewEncoder(&e.buf)
+	e.enc.SetEscapeHTML(false)
+	return e
+}
+
+// SetCompact makes Encode write each value on a single line, coloured like
+// the pretty layout when colour is enabled.
+func (e *Encoder) SetCompact(compact bool) {
+	e.compact = compact
+}
+
+// Encode marshals v with encoding/json and writes it formatted, followed by
+// a newline. Marshalling errors are returned before anything is written.
+func (e *Encoder) Encode(v any) error {
+	if e.err != nil {
+		return e.err
+	}
+	e.buf.Reset()
+	if err := e.enc.Encode(v); err != nil {
+		return err
+	}
+	return e.write(&e.buf)
+}
+
+// write formats the JSON documents read from r with the encoder's options
+// and palette.
+func (e *Encoder) write(r io.Reader) error {
+	if e.err != nil {
+		return e.err
+	}
+	switch {
+	case e.opts.Output == OutputCBOR || e.opts.Output == OutputMsgPack:
+		return encodeBinary(e.w, r, &e.opts, e.opts.Output)
+	case isTableOutput(e.opts.Output):
+		return writeTable(e.w, r, &e.opts, e.pal)
+	}
+	return streamPretty(e.w, r, &e.opts, e.pal, e.compact)
+}
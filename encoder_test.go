@@ -0,0 +1,79 @@
+package prettyx
+
+import (
+	"bytes"
+	"math"
+	"strings"
+	"testing"
+
+	"pkt.systems/prettyx/internal/ansi"
+)
+
+func TestEncoder(t *testing.T) {
+	opts := *DefaultOptions
+	opts.Palette = "none"
+	opts.Unwrap = true
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf, &opts)
+	value := struct {
+		Name    string `json:"name"`
+		Payload string `json:"payload"`
+		HTML    string `json:"html"`
+	}{"a", `{"n":[1,2]}`, "<b>"}
+	if err := enc.Encode(value); err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+	enc.SetCompact(true)
+	if err := enc.Encode(map[string]int{"b": 2, "a": 1}); err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+	want := `{
+  "name": "a",
+  "payload": {
+    "n": [
+      1,
+      2
+    ]
+  },
+  "html": "<b>"
+}
+{"a":1,"b":2}
+`
+	if buf.String() != want {
+		t.Fatalf("unexpected output\nexpected:\n%s\nactual:\n%s", want, buf.String())
+	}
+
+	buf.Reset()
+	if err := enc.Encode(math.Inf(1)); err == nil {
+		t.Fatal("expected marshalling error")
+	}
+	if buf.Len() != 0 {
+		t.Fatalf("output written on error: %q", buf.String())
+	}
+}
+
+func TestEncoderColorAndFormats(t *testing.T) {
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf, &Options{ForceColor: true, Palette: "default"})
+	enc.SetCompact(true)
+	if err := enc.Encode(map[string]bool{"ok": true}); err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+	if !strings.Contains(buf.String(), ansi.Reset) || strings.Contains(buf.String(), "\n ") {
+		t.Fatalf("expected coloured compact output, got %q", buf.String())
+	}
+
+	buf.Reset()
+	enc = NewEncoder(&buf, &Options{Palette: "none", Output: OutputYAML})
+	if err := enc.Encode([]string{"x"}); err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+	if buf.String() != "- x\n" {
+		t.Fatalf("unexpected YAML %q", buf.String())
+	}
+
+	enc = NewEncoder(&buf, &Options{Palette: "no-such-palette"})
+	if err := enc.Encode(1); err == nil {
+		t.Fatal("expected palette error")
+	}
+}
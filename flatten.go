@@ -0,0 +1,585 @@
+package prettyx
+
+import (
+	"bufio"
+	"cmp"
+	"io"
+	"slices"
+	"strconv"
+
+	"pkt.systems/prettyx/internal/ansi"
+)
+
+// flattenRoot names the document in flattened output, as gron does.
+const flattenRoot = "json"
+
+// flattenDocument writes the value starting with first as gron-style
+// assignments, one line per value:
+//
+//	json = {};
+//	json.items = [];
+//	json.items[0] = "x";
+func (p *parser) flattenDocument(first byte) error {
+	p.flatPath = appendStyled(p.flatPath[:0], p.formatter.pal.Key, flattenRoot)
+	if p.sortKeys == KeyOrderInput && p.unwrapDepth <= 0 && p.redact == nil {
+		return p.flattenValue(first)
+	}
+	return p.replayDocument(first, (*parser).flattenValue)
+}
+
+// flattenValue writes the assignment for one value and, for objects and
+// arrays, the assignments for everything inside it.
+func (p *parser) flattenValue(first byte) error {
+	f := p.formatter
+	if err := f.ensureLineStart(0); err != nil {
+		return err
+	}
+	if err := f.writeBytes(p.flatPath); err != nil {
+		return err
+	}
+	if err := f.writePunctuation(" = "); err != nil {
+		return err
+	}
+	switch first {
+	case '{', '[':
+		closing := byte('}')
+		if first == '[' {
+			closing = ']'
+		}
+		if err := f.writeBracket(first); err != nil {
+			return err
+		}
+		if err := f.writeBracket(closing); err != nil {
+			return err
+		}
+		if err := p.endFlatLine(); err != nil {
+			return err
+		}
+		if first == '{' {
+			return p.flattenMembers()
+		}
+		return p.flattenElements()
+	default:
+		if err := p.parseValueWithFirst(0, first); err != nil {
+			return err
+		}
+		return p.endFlatLine()
+	}
+}
+
+func (p *parser) endFlatLine() error {
+	if err := p.formatter.writePunctuation(";"); err != nil {
+		return err
+	}
+	if err := p.formatter.writeByte('\n'); err != nil {
+		return err
+	}
+	p.formatter.lineLen = 0
+	return nil
+}
+
+func (p *parser) flattenMembers() error {
+	b, err := p.readNonSpace()
+	if err != nil {
+		if p.repairEOF(err, RepairUnclosedObject) {
+			return nil
+		}
+		return err
+	}
+	if b == '}' {
+		return nil
+	}
+	parent := len(p.flatPath)
+	for {
+		key, err := p.readMemberKey(b)
+		if err != nil {
+			return err
+		}
+		p.flatPath = p.appendFlatKey(p.flatPath, key)
+		if err := p.expectColon(); err != nil && !(p.repair && err == io.EOF) {
+			return err
+		}
+		if err := p.flattenMember(); err != nil {
+			return err
+		}
+		p.flatPath = p.flatPath[:parent]
+		var closed bool
+		if b, closed, err = p.nextMember('}'); err != nil || closed {
+			return err
+		}
+	}
+}
+
+func (p *parser) flattenElements() error {
+	b, err := p.readNonSpace()
+	if err != nil {
+		if p.repairEOF(err, RepairUnclosedArray) {
+			return nil
+		}
+		return err
+	}
+	if b == ']' {
+		return nil
+	}
+	parent := len(p.flatPath)
+	for i := 0; ; i++ {
+		p.flatPath = p.appendFlatIndex(p.flatPath, i)
+		if err := p.flattenValue(b); err != nil {
+			return err
+		}
+		p.flatPath = p.flatPath[:parent]
+		var closed bool
+		if b, closed, err = p.nextMember(']'); err != nil || closed {
+			return err
+		}
+	}
+}
+
+// flattenMember flattens a member value. In repair mode a value cut off by
+// the end of input is written as null.
+func (p *parser) flattenMember() error {
+	b, err := p.readNonSpace()
+	if err == nil {
+		return p.flattenValue(b)
+	}
+	if !p.repairEOF(err, RepairMissingValue) {
+		return err
+	}
+	f := p.formatter
+	if err := f.ensureLineStart(0); err != nil {
+		return err
+	}
+	if err := f.writeBytes(p.flatPath); err != nil {
+		return err
+	}
+	if err := f.writePunctuation(" = "); err != nil {
+		return err
+	}
+	if err := f.writeLiteral("null", f.pal.Null); err != nil {
+		return err
+	}
+	return p.endFlatLine()
+}
+
+// appendFlatKey appends .key for keys that are identifiers and ["key"] for
+// any other key.
+func (p *parser) appendFlatKey(dst []byte, key []byte) []byte {
+	pal := &p.formatter.pal
+	if isIdentifier(key) {
+		dst = appendStyled(dst, pal.Punctuation, ".")
+		return appendStyled(dst, pal.Key, string(key))
+	}
+	p.scratch = appendQuotedBytes(p.scratch[:0], key)
+	dst = appendStyled(dst, pal.Punctuation, "[")
+	dst = appendStyled(dst, pal.Key, string(p.scratch))
+	return appendStyled(dst, pal.Punctuation, "]")
+}
+
+func (p *parser) appendFlatIndex(dst []byte, i int) []byte {
+	pal := &p.formatter.pal
+	dst = appendStyled(dst, pal.Punctuation, "[")
+	if pal.Number != "" {
+		dst = append(dst, pal.Number...)
+	}
+	dst = strconv.AppendInt(dst, int64(i), 10)
+	if pal.Number != "" {
+		dst = append(dst, ansi.Reset...)
+	}
+	return appendStyled(dst, pal.Punctuation, "]")
+}
+
+func appendStyled(dst []byte, style string, s string) []byte {
+	if style == "" {
+		return append(dst, s...)
+	}
+	dst = append(dst, style...)
+	dst = append(dst, s...)
+	return append(dst, ansi.Reset...)
+}
+
+// isIdentifier reports whether key can follow a '.' in a flattened path.
+func isIdentifier(key []byte) bool {
+	if len(key) == 0 || (key[0] >= '0' && key[0] <= '9') {
+		return false
+	}
+	for _, c := range key {
+		if !isIdentByte(c) {
+			return false
+		}
+	}
+	return true
+}
+
+func isIdentByte(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9') || c == '_' || c == '$'
+}
+
+// Unflatten reverses flattened output: it reads gron-style assignment lines
+// from r and writes the JSON they describe to w, compacted, one document per
+// line. Lines may be filtered or reordered; containers that are never
+// assigned are created from the paths below them, and array elements that
+// are missing are written as null. An assignment to the root starts a new
+// document once the current one has content. Malformed lines are reported
+// as a *SyntaxError.
+func Unflatten(w io.Writer, r io.Reader) error {
+	bw := bufio.NewWriter(w)
+	p := acquireParser()
+	defer releaseParser(p)
+	var u unflattener
+	p.reset(r, &u.value, nil, NoColorPalette(), true)
+	p.doc = 1
+	err := u.run(p, bw)
+	if ferr := bw.Flush(); err == nil {
+		err = ferr
+	}
+	return err
+}
+
+type flatNodeKind uint8
+
+const (
+	flatUnset flatNodeKind = iota
+	flatObject
+	flatArray
+	flatScalar
+)
+
+// flatNode is one value of the document being rebuilt. Array elements are
+// kept sparse, ordered by index, so that a filtered listing that names only
+// element 5999 does not allocate the 5999 before it; the gaps are written as
+// null.
+type flatNode struct {
+	kind     flatNodeKind
+	key      string
+	index    int
+	value    []byte
+	children []int
+}
+
+type flatMember struct {
+	parent int
+	key    string
+}
+
+type unflattener struct {
+	nodes   []flatNode
+	members map[flatMember]int
+	value   byteSink
+}
+
+func (u *unflattener) clear() {
+	u.nodes = append(u.nodes[:0], flatNode{})
+	u.members = make(map[flatMember]int)
+}
+
+func (u *unflattener) run(p *parser, w *bufio.Writer) error {
+	u.clear()
+	for {
+		b, err := p.readNonSpace()
+		if err == io.EOF {
+			return u.flush(w)
+		}
+		if err != nil {
+			return err
+		}
+		if err := u.statement(p, b, w); err != nil {
+			if err == io.EOF {
+				return p.errorfNext("unexpected end of input")
+			}
+			return err
+		}
+	}
+}
+
+// statement reads one "path = value;" line whose first byte is first.
+func (u *unflattener) statement(p *parser, first byte, w *bufio.Writer) error {
+	if !isIdentByte(first) {
+		return p.errorf("expected %s", flattenRoot)
+	}
+	for {
+		b, err := p.scanner.peekByte()
+		if err != nil {
+			return err
+		}
+		if !isIdentByte(b) {
+			break
+		}
+		_, _ = p.scanner.readByte()
+	}
+
+	node := -1
+	for {
+		b, err := p.readNonSpace()
+		if err != nil {
+			return err
+		}
+		if b == '=' {
+			break
+		}
+		if node < 0 {
+			node = 0
+		}
+		switch b {
+		case '.':
+			key, err := u.readIdentifier(p)
+			if err != nil {
+				return err
+			}
+			if node, err = u.member(p, node, key); err != nil {
+				return err
+			}
+		case '[':
+			if node, err = u.subscript(p, node); err != nil {
+				return err
+			}
+		default:
+			return p.errorf("expected '.', '[' or '='")
+		}
+	}
+	if node < 0 {
+		if u.nodes[0].kind != flatUnset {
+			if err := u.flush(w); err != nil {
+				return err
+			}
+			u.clear()
+		}
+		node = 0
+	}
+
+	b, err := p.readNonSpace()
+	if err != nil {
+		return err
+	}
+	u.value.buf = u.value.buf[:0]
+	p.formatter.lineLen = 0
+	if b == '-' || (b >= '0' && b <= '9') {
+		err = u.readNumber(p, b)
+	} else {
+		err = p.parseValueWithFirst(0, b)
+	}
+	if err != nil {
+		return err
+	}
+	u.assign(node, u.value.buf)
+	if b, err = p.readNonSpace(); err != nil {
+		return err
+	}
+	if b != ';' {
+		return p.errorf("expected ';'")
+	}
+	return nil
+}
+
+// readNumber reads a number that ends at the ';' of its statement, which
+// parseNumber would not accept as a terminator.
+func (u *unflattener) readNumber(p *parser, first byte) error {
+	state, _ := numStartState(first)
+	u.value.buf = append(u.value.buf, first)
+	for {
+		b, err := p.scanner.peekByte()
+		if err != nil {
+			return err
+		}
+		if b == ';' || isTerminator(b) {
+			break
+		}
+		next, ok := numNextState(state, b)
+		if !ok {
+			return p.errorfNext("invalid number")
+		}
+		state = next
+		_, _ = p.scanner.readByte()
+		u.value.buf = append(u.value.buf, b)
+	}
+	if !numIsTerminal(state) {
+		return p.errorfNext("invalid number")
+	}
+	return nil
+}
+
+func (u *unflattener) readIdentifier(p *parser) (string, error) {
+	start := p.scanner.pos
+	p.scratch = p.scratch[:0]
+	for {
+		b, err := p.scanner.peekByte()
+		if err != nil && err != io.EOF {
+			return "", err
+		}
+		if err == io.EOF || !isIdentByte(b) {
+			break
+		}
+		_, _ = p.scanner.readByte()
+		p.scratch = append(p.scratch, b)
+	}
+	if len(p.scratch) == 0 {
+		return "", newSyntaxError(&p.scanner, start, p.doc, "expected member name")
+	}
+	return string(p.scratch), nil
+}
+
+// subscript reads ["key"] or [N] after the '[' and returns the node it
+// names inside node.
+func (u *unflattener) subscript(p *parser, node int) (int, error) {
+	b, err := p.readNonSpace()
+	if err != nil {
+		return 0, err
+	}
+	switch {
+	case b == '"':
+		key, err := p.readQuoted('"')
+		if err != nil {
+			return 0, err
+		}
+		if node, err = u.member(p, node, string(key)); err != nil {
+			return 0, err
+		}
+	case b >= '0' && b <= '9':
+		i := int(b - '0')
+		for {
+			c, err := p.scanner.peekByte()
+			if err != nil {
+				return 0, err
+			}
+			if c < '0' || c > '9' {
+				break
+			}
+			_, _ = p.scanner.readByte()
+			if i > (1<<31)/10 {
+				return 0, p.errorf("array index out of range")
+			}
+			i = i*10 + int(c-'0')
+		}
+		if node, err = u.element(p, node, i); err != nil {
+			return 0, err
+		}
+	default:
+		return 0, p.errorf("expected string or array index")
+	}
+	if b, err = p.readNonSpace(); err != nil {
+		return 0, err
+	}
+	if b != ']' {
+		return 0, p.errorf("expected ']'")
+	}
+	return node, nil
+}
+
+func (u *unflattener) member(p *parser, node int, key string) (int, error) {
+	switch u.nodes[node].kind {
+	case flatUnset, flatScalar:
+		u.nodes[node] = flatNode{kind: flatObject, key: u.nodes[node].key, index: u.nodes[node].index}
+	case flatArray:
+		return 0, p.errorf("member of an array")
+	}
+	if child, ok := u.members[flatMember{node, key}]; ok {
+		return child, nil
+	}
+	child := len(u.nodes)
+	u.nodes = append(u.nodes, flatNode{key: key})
+	u.nodes[node].children = append(u.nodes[node].children, child)
+	u.members[flatMember{node, key}] = child
+	return child, nil
+}
+
+func (u *unflattener) element(p *parser, node int, i int) (int, error) {
+	switch u.nodes[node].kind {
+	case flatUnset, flatScalar:
+		u.nodes[node] = flatNode{kind: flatArray, key: u.nodes[node].key, index: u.nodes[node].index}
+	case flatObject:
+		return 0, p.errorf("element of an object")
+	}
+	children := u.nodes[node].children
+	at, found := slices.BinarySearchFunc(children, i, func(child, i int) int {
+		return cmp.Compare(u.nodes[child].index, i)
+	})
+	if found {
+		return children[at], nil
+	}
+	child := len(u.nodes)
+	u.nodes = append(u.nodes, flatNode{index: i})
+	u.nodes[node].children = slices.Insert(children, at, child)
+	return child, nil
+}
+
+// assign sets node to the compact JSON value. Assigning {} or [] to a
+// container of the same kind keeps what is already inside it, so the order
+// of the lines does not matter.
+func (u *unflattener) assign(node int, value []byte) {
+	n := &u.nodes[node]
+	switch string(value) {
+	case "{}":
+		if n.kind != flatObject {
+			*n = flatNode{kind: flatObject, key: n.key, index: n.index}
+		}
+	case "[]":
+		if n.kind != flatArray {
+			*n = flatNode{kind: flatArray, key: n.key, index: n.index}
+		}
+	default:
+		*n = flatNode{kind: flatScalar, key: n.key, index: n.index, value: append([]byte(nil), value...)}
+	}
+}
+
+func (u *unflattener) flush(w *bufio.Writer) error {
+	if u.nodes[0].kind == flatUnset {
+		return nil
+	}
+	var scratch []byte
+	if err := u.write(w, 0, &scratch); err != nil {
+		return err
+	}
+	return w.WriteByte('\n')
+}
+
+func (u *unflattener) write(w *bufio.Writer, node int, scratch *[]byte) error {
+	n := &u.nodes[node]
+	switch n.kind {
+	case flatUnset:
+		_, err := w.WriteString("null")
+		return err
+	case flatScalar:
+		_, err := w.Write(n.value)
+		return err
+	}
+	open, closing := byte('['), byte(']')
+	if n.kind == flatObject {
+		open, closing = '{', '}'
+	}
+	if err := w.WriteByte(open); err != nil {
+		return err
+	}
+	// next counts the values written, including the nulls that fill the
+	// gaps between sparse array elements.
+	next := 0
+	for _, child := range n.children {
+		for ; n.kind == flatArray && next < u.nodes[child].index; next++ {
+			if next > 0 {
+				if err := w.WriteByte(','); err != nil {
+					return err
+				}
+			}
+			if _, err := w.WriteString("null"); err != nil {
+				return err
+			}
+		}
+		if next > 0 {
+			if err := w.WriteByte(','); err != nil {
+				return err
+			}
+		}
+		next++
+		if n.kind == flatObject {
+			*scratch = appendQuotedBytes(*scratch, []byte(u.nodes[child].key))
+			if _, err := w.Write(*scratch); err != nil {
+				return err
+			}
+			if err := w.WriteByte(':'); err != nil {
+				return err
+			}
+		}
+		if err := u.write(w, child, scratch); err != nil {
+			return err
+		}
+	}
+	return w.WriteByte(closing)
+}
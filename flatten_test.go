@@ -0,0 +1,197 @@
+package prettyx
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+)
+
+const flattenInput = `{"items":[{"name":"x","a b":[1,{}]},null],"empty":{},"$k":"é\n"}
+[true]`
+
+const flattenOutput = `json = {};
+json.items = [];
+json.items[0] = {};
+json.items[0].name = "x";
+json.items[0]["a b"] = [];
+json.items[0]["a b"][0] = 1;
+json.items[0]["a b"][1] = {};
+json.items[1] = null;
+json.empty = {};
+json.$k = "é\n";
+json = [];
+json[0] = true;
+`
+
+func TestFlatten(t *testing.T) {
+	opts := *DefaultOptions
+	opts.Palette = "none"
+	opts.Flatten = true
+	var buf bytes.Buffer
+	if err := PrettyStream(&buf, strings.NewReader(flattenInput), &opts); err != nil {
+		t.Fatalf("PrettyStream failed: %v", err)
+	}
+	if buf.String() != flattenOutput {
+		t.Fatalf("unexpected output\nexpected:\n%s\nactual:\n%s", flattenOutput, buf.String())
+	}
+
+	out, err := CompactToBuffer(strings.NewReader(flattenInput), &opts)
+	if err != nil || string(out) != flattenOutput {
+		t.Fatalf("unexpected compact output %q, %v", out, err)
+	}
+}
+
+func TestFlatten_SortedUnwrapped(t *testing.T) {
+	opts := *DefaultOptions
+	opts.Palette = "none"
+	opts.Flatten = true
+	opts.SortKeys = KeyOrderBytes
+	opts.Unwrap = true
+	var buf bytes.Buffer
+	if err := PrettyStream(&buf, strings.NewReader(`{"z":"{\"b\":1,\"a\":[2]}","y":"plain"}`), &opts); err != nil {
+		t.Fatalf("PrettyStream failed: %v", err)
+	}
+	want := `json = {};
+json.y = "plain";
+json.z = {};
+json.z.a = [];
+json.z.a[0] = 2;
+json.z.b = 1;
+`
+	if buf.String() != want {
+		t.Fatalf("unexpected output\nexpected:\n%s\nactual:\n%s", want, buf.String())
+	}
+}
+
+func TestFlatten_ColorAndRepair(t *testing.T) {
+	opts := *DefaultOptions
+	opts.ForceColor = true
+	opts.Flatten = true
+	opts.Repair = true
+	pal, err := resolvePalette(&opts, true)
+	if err != nil {
+		t.Fatalf("resolvePalette failed: %v", err)
+	}
+	out, err := Pretty([]byte(`{'a': [1,`), &opts)
+	if err != nil {
+		t.Fatalf("Pretty failed: %v", err)
+	}
+	for _, want := range []string{
+		pal.Key + "json" + "\x1b[0m",
+		pal.Punctuation + "." + "\x1b[0m" + pal.Key + "a" + "\x1b[0m",
+		pal.Punctuation + "[" + "\x1b[0m" + pal.Number + "0" + "\x1b[0m" + pal.Punctuation + "]" + "\x1b[0m",
+	} {
+		if !bytes.Contains(out, []byte(want)) {
+			t.Fatalf("expected %q in %q", want, out)
+		}
+	}
+	if n := bytes.Count(out, []byte("\n")); n != 3 {
+		t.Fatalf("expected 3 lines, got %d in %q", n, out)
+	}
+}
+
+func TestUnflatten(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Unflatten(&buf, strings.NewReader(flattenOutput)); err != nil {
+		t.Fatalf("Unflatten failed: %v", err)
+	}
+	want := `{"items":[{"name":"x","a b":[1,{}]},null],"empty":{},"$k":"é\n"}` + "\n[true]\n"
+	if buf.String() != want {
+		t.Fatalf("unexpected output\nexpected:\n%s\nactual:\n%s", want, buf.String())
+	}
+
+	buf.Reset()
+	filtered := "json.items[2].name = \"z\";\njson[\"a\\\"b\"].c = -1.5e3;\n  json.items[0] = [ 1 , 2 ] ;\n"
+	if err := Unflatten(&buf, strings.NewReader(filtered)); err != nil {
+		t.Fatalf("Unflatten failed: %v", err)
+	}
+	want = `{"items":[[1,2],null,{"name":"z"}],"a\"b":{"c":-1.5e3}}` + "\n"
+	if buf.String() != want {
+		t.Fatalf("unexpected output\nexpected:\n%s\nactual:\n%s", want, buf.String())
+	}
+}
+
+func TestUnflatten_SparseArrays(t *testing.T) {
+	cases := map[string]string{
+		"json[3] = 1;":                              "[null,null,null,1]",
+		"json.a[1] = 2;\njson.a[0] = 1;":            `{"a":[1,2]}`,
+		"json[2].n = 5999;\njson[2] = {};":          `[null,null,{"n":5999}]`,
+		"json[5] = 1;\njson[2] = [];\njson[5] = 2;": "[null,null,[],null,null,2]",
+	}
+	for input, want := range cases {
+		var buf bytes.Buffer
+		if err := Unflatten(&buf, strings.NewReader(input)); err != nil {
+			t.Fatalf("%q: Unflatten failed: %v", input, err)
+		}
+		if buf.String() != want+"\n" {
+			t.Fatalf("%q: expected %s, got %s", input, want, buf.String())
+		}
+	}
+
+	// A filtered listing names only the elements it kept; the nodes
+	// allocated do not depend on the indices.
+	var in strings.Builder
+	for i := 0; i < 100; i++ {
+		fmt.Fprintf(&in, "json.items[%d].n = %d;\n", 100000+i*1000, i)
+	}
+	var u unflattener
+	p := acquireParser()
+	defer releaseParser(p)
+	p.reset(strings.NewReader(in.String()), &u.value, nil, NoColorPalette(), true)
+	if err := u.run(p, bufio.NewWriter(io.Discard)); err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+	if len(u.nodes) > 1+1+100*2 {
+		t.Fatalf("sparse elements allocated %d nodes", len(u.nodes))
+	}
+}
+
+func TestUnflatten_Errors(t *testing.T) {
+	cases := map[string]string{
+		"json.a = 1\njson.b = 2;":  "expected ';'",
+		"json.a = 01;":             "invalid number",
+		"json.a = tru;":            "invalid literal",
+		"json a = 1;":              "expected '.', '[' or '='",
+		"json. = 1;":               "expected member name",
+		"json[x] = 1;":             "expected string or array index",
+		"json[1 = 1;":              "expected ']'",
+		"json = [];\njson.a = 1;":  "member of an array",
+		"json = {};\njson[0] = 1;": "element of an object",
+		"= 1;":                     "expected json",
+		"json.a = 1":               "unexpected end of input",
+	}
+	for input, msg := range cases {
+		var se *SyntaxError
+		err := Unflatten(&bytes.Buffer{}, strings.NewReader(input))
+		if !errors.As(err, &se) || se.Msg != msg {
+			t.Fatalf("%q: expected %q, got %v", input, msg, err)
+		}
+	}
+}
+
+func TestPrettyStream_NoAlloc_Flatten(t *testing.T) {
+	warmPools()
+
+	input := []byte(`{"a":1,"b":[1,2,3],"c":{"d e":"f"}}`)
+	opts := *DefaultOptions
+	opts.Palette = "none"
+	opts.Flatten = true
+
+	writer := discardStringByteWriter{}
+	reader := bytes.NewReader(input)
+
+	allocs := testing.AllocsPerRun(100, func() {
+		reader.Reset(input)
+		if err := PrettyStream(writer, reader, &opts); err != nil {
+			t.Fatalf("PrettyStream failed: %v", err)
+		}
+	})
+
+	if allocs != 0 {
+		t.Fatalf("expected zero allocations, got %.2f", allocs)
+	}
+}
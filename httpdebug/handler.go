@@ -0,0 +1,75 @@
+package httpdebug
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Handler returns middleware that dumps each request to next and its
+// response once next returns. The request body is dumped as far as next
+// read it; nothing extra is read from the client.
+func Handler(next http.Handler, opts *Options) http.Handler {
+	d := newDumper(opts)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		reqBody := d.newCapture()
+		if r.Body != nil && r.Body != http.NoBody && reqBody != nil {
+			r2 := r.WithContext(r.Context())
+			r2.Body = &teeBody{rc: r.Body, c: reqBody}
+			r = r2
+		}
+		rec := &responseRecorder{ResponseWriter: w, body: d.newCapture()}
+		defer func() {
+			var b bytes.Buffer
+			fmt.Fprintf(&b, "--> %s %s %s\n", r.Method, r.RequestURI, r.Proto)
+			d.writeHeaders(&b, r.Header)
+			d.writeBody(&b, reqBody, r.Header.Get("Content-Type"))
+			status := rec.status
+			if status == 0 {
+				status = http.StatusOK
+			}
+			fmt.Fprintf(&b, "<-- %d %s", status, http.StatusText(status))
+			writeDuration(&b, time.Since(start))
+			d.writeHeaders(&b, rec.Header())
+			d.writeBody(&b, rec.body, rec.Header().Get("Content-Type"))
+			d.flush(&b)
+		}()
+		next.ServeHTTP(rec, r)
+	})
+}
+
+// responseRecorder passes everything through to the real ResponseWriter and
+// keeps the status and a capture of the body.
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	body   *capture
+}
+
+func (rec *responseRecorder) WriteHeader(code int) {
+	if rec.status == 0 && code >= 200 {
+		rec.status = code
+	}
+	rec.ResponseWriter.WriteHeader(code)
+}
+
+func (rec *responseRecorder) Write(p []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	n, err := rec.ResponseWriter.Write(p)
+	rec.body.write(p[:n])
+	return n, err
+}
+
+// Flush lets handlers that stream with http.Flusher keep doing so.
+func (rec *responseRecorder) Flush() {
+	_ = http.NewResponseController(rec.ResponseWriter).Flush()
+}
+
+// Unwrap gives http.ResponseController access to the real ResponseWriter.
+func (rec *responseRecorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
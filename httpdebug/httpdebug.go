@@ -0,0 +1,249 @@
+// Package httpdebug dumps HTTP traffic with prettyx formatting. Handler wraps
+// an http.Handler and Transport wraps an http.RoundTripper; both copy each
+// request and response, with JSON bodies pretty-printed, to a debug writer
+// while the real peer sees exactly the bytes it would have seen unwrapped.
+//
+//	client := &http.Client{Transport: httpdebug.Transport(nil, nil)}
+//	http.ListenAndServe(":8080", httpdebug.Handler(mux, &httpdebug.Options{
+//		Format: &prettyx.Options{Unwrap: true, Indent: "  "},
+//	}))
+package httpdebug
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mattn/go-isatty"
+
+	"pkt.systems/prettyx"
+)
+
+// DefaultMaxBodySize is the number of bytes of each body that is dumped when
+// Options.MaxBodySize is zero.
+const DefaultMaxBodySize = 64 << 10
+
+// DefaultRedactHeaders lists the headers whose values are masked when
+// Options.RedactHeaders is nil.
+var DefaultRedactHeaders = []string{
+	"Authorization",
+	"Proxy-Authorization",
+	"Cookie",
+	"Set-Cookie",
+	"X-Api-Key",
+	"X-Auth-Token",
+}
+
+// headerMask replaces the values of redacted headers.
+const headerMask = "[REDACTED]"
+
+// Options configure Handler and Transport.
+type Options struct {
+	// Out receives the dumps. Nil writes to os.Stderr. Each exchange is
+	// written with a single Write.
+	Out io.Writer
+	// Format formats JSON bodies. Nil uses prettyx.DefaultOptions. Set
+	// Unwrap to decode JSON held in strings and Redact to mask secrets in
+	// bodies. Colour follows Out as it does for prettyx.PrettyStream, and
+	// only JSON and YAML output are used.
+	Format *prettyx.Options
+	// MaxBodySize limits how many bytes of each body are dumped; a body cut
+	// off by the limit is closed up with prettyx's Repair and marked as
+	// truncated. Zero uses DefaultMaxBodySize and a negative size dumps no
+	// bodies at all.
+	MaxBodySize int
+	// RedactHeaders names the headers whose values are masked in dumps.
+	// Nil uses DefaultRedactHeaders; an empty slice masks nothing.
+	RedactHeaders []string
+}
+
+// dumper formats exchanges and writes them to the debug writer.
+type dumper struct {
+	mu     sync.Mutex
+	out    io.Writer
+	format prettyx.Options
+	limit  int
+	redact map[string]bool
+}
+
+func newDumper(opts *Options) *dumper {
+	if opts == nil {
+		opts = &Options{}
+	}
+	d := &dumper{out: opts.Out, limit: opts.MaxBodySize}
+	if d.out == nil {
+		d.out = os.Stderr
+	}
+	if opts.Format != nil {
+		d.format = *opts.Format
+	} else {
+		d.format = *prettyx.DefaultOptions
+	}
+	if d.format.Output != prettyx.OutputYAML {
+		d.format.Output = prettyx.OutputJSON
+	}
+	d.format.Passthrough = false
+	d.format.Recover = nil
+	// Bodies are formatted into memory, so colour is decided here from the
+	// real destination.
+	if f, ok := d.out.(interface{ Fd() uintptr }); ok && isatty.IsTerminal(f.Fd()) {
+		d.format.ForceColor = true
+	}
+	if d.limit == 0 {
+		d.limit = DefaultMaxBodySize
+	}
+	headers := opts.RedactHeaders
+	if headers == nil {
+		headers = DefaultRedactHeaders
+	}
+	d.redact = make(map[string]bool, len(headers))
+	for _, h := range headers {
+		d.redact[http.CanonicalHeaderKey(h)] = true
+	}
+	return d
+}
+
+// flush writes one finished dump.
+func (d *dumper) flush(b *bytes.Buffer) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	_, _ = d.out.Write(b.Bytes())
+}
+
+// writeHeaders writes h sorted by name, one value per line, with the values
+// of redacted headers masked.
+func (d *dumper) writeHeaders(b *bytes.Buffer, h http.Header) {
+	names := make([]string, 0, len(h))
+	for name := range h {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		for _, v := range h[name] {
+			if d.redact[http.CanonicalHeaderKey(name)] {
+				v = headerMask
+			}
+			fmt.Fprintf(b, "    %s: %s\n", name, v)
+		}
+	}
+}
+
+// writeBody writes the captured body: pretty-printed when it is JSON,
+// summarised otherwise.
+func (d *dumper) writeBody(b *bytes.Buffer, c *capture, contentType string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.total == 0 {
+		return
+	}
+	body := c.buf.Bytes()
+	if !isJSON(contentType, body) {
+		mediaType := contentType
+		if mediaType == "" {
+			mediaType = "unknown content type"
+		}
+		fmt.Fprintf(b, "    (%d bytes of %s)\n", c.total, mediaType)
+		return
+	}
+	truncated := c.total > int64(len(body))
+	format := d.format
+	format.Repair = format.Repair || truncated
+	format.OnRepair = nil
+	start := b.Len()
+	if err := prettyx.PrettyStream(b, bytes.NewReader(body), &format); err != nil {
+		b.Truncate(start)
+		b.Write(body)
+		if len(body) > 0 && body[len(body)-1] != '\n' {
+			b.WriteByte('\n')
+		}
+		fmt.Fprintf(b, "    (invalid JSON: %v)\n", err)
+	}
+	if truncated {
+		fmt.Fprintf(b, "    (truncated: %d of %d bytes shown)\n", len(body), c.total)
+	}
+}
+
+// isJSON reports whether a body is JSON by its content type or, when there
+// is none, by its first byte.
+func isJSON(contentType string, body []byte) bool {
+	if contentType == "" {
+		trimmed := bytes.TrimLeft(body, " \t\r\n")
+		return len(trimmed) > 0 && (trimmed[0] == '{' || trimmed[0] == '[')
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	switch mediaType {
+	case "application/json", "text/json", "application/x-ndjson", "application/ndjson", "application/jsonl":
+		return true
+	}
+	return strings.HasSuffix(mediaType, "+json")
+}
+
+func writeDuration(b *bytes.Buffer, elapsed time.Duration) {
+	fmt.Fprintf(b, " (%s)\n", elapsed.Round(time.Microsecond))
+}
+
+// capture keeps the first limit bytes of a body and counts the rest.
+type capture struct {
+	mu    sync.Mutex
+	buf   bytes.Buffer
+	limit int
+	total int64
+}
+
+func (d *dumper) newCapture() *capture {
+	if d.limit < 0 {
+		return nil
+	}
+	return &capture{limit: d.limit}
+}
+
+func (c *capture) write(p []byte) {
+	if c == nil || len(p) == 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.total += int64(len(p))
+	if room := c.limit - c.buf.Len(); room > 0 {
+		c.buf.Write(p[:min(room, len(p))])
+	}
+}
+
+// teeBody copies what is read from a body into a capture and calls done
+// once, at the end of the body or when it is closed.
+type teeBody struct {
+	rc   io.ReadCloser
+	c    *capture
+	once sync.Once
+	done func()
+}
+
+func (t *teeBody) Read(p []byte) (int, error) {
+	n, err := t.rc.Read(p)
+	t.c.write(p[:n])
+	if err == io.EOF && t.done != nil {
+		t.once.Do(t.done)
+	}
+	return n, err
+}
+
+func (t *teeBody) Close() error {
+	err := t.rc.Close()
+	if t.done != nil {
+		t.once.Do(t.done)
+	}
+	return err
+}
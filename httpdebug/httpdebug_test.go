@@ -0,0 +1,185 @@
+package httpdebug
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"pkt.systems/prettyx"
+)
+
+// syncBuffer is a bytes.Buffer safe for the concurrent writes of a server
+// and a client.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+const (
+	requestJSON  = `{"user":"bob","payload":"{\"n\":1}"}`
+	responseJSON = `{"ok":true,"items":[1,2]}`
+)
+
+func echoHandler(t *testing.T) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Errorf("reading request body: %v", err)
+		}
+		if string(body) != requestJSON {
+			t.Errorf("server saw body %q", body)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Set-Cookie", "session=abc")
+		w.WriteHeader(http.StatusCreated)
+		io.WriteString(w, responseJSON)
+	})
+}
+
+func TestHandler(t *testing.T) {
+	var out syncBuffer
+	srv := httptest.NewServer(Handler(echoHandler(t), &Options{
+		Out:    &out,
+		Format: &prettyx.Options{Palette: "none", Indent: "  ", Unwrap: true},
+	}))
+	defer srv.Close()
+
+	req, _ := http.NewRequest("POST", srv.URL+"/items?x=1", strings.NewReader(requestJSON))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer secret")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated || string(body) != responseJSON {
+		t.Fatalf("client saw %d %q", resp.StatusCode, body)
+	}
+
+	dump := out.String()
+	for _, want := range []string{
+		"--> POST /items?x=1 HTTP/1.1\n",
+		"    Authorization: [REDACTED]\n",
+		"{\n  \"user\": \"bob\",\n  \"payload\": {\n    \"n\": 1\n  }\n}\n",
+		"<-- 201 Created (",
+		"    Set-Cookie: [REDACTED]\n",
+		"{\n  \"ok\": true,\n  \"items\": [\n    1,\n    2\n  ]\n}\n",
+	} {
+		if !strings.Contains(dump, want) {
+			t.Fatalf("dump lacks %q:\n%s", want, dump)
+		}
+	}
+	if strings.Contains(dump, "secret") || strings.Contains(dump, "session=abc") {
+		t.Fatalf("dump leaks a redacted header:\n%s", dump)
+	}
+}
+
+func TestTransport(t *testing.T) {
+	srv := httptest.NewServer(echoHandler(t))
+	defer srv.Close()
+
+	var out syncBuffer
+	client := &http.Client{Transport: Transport(nil, &Options{
+		Out:           &out,
+		Format:        &prettyx.Options{Palette: "none"},
+		MaxBodySize:   12,
+		RedactHeaders: []string{},
+	})}
+	req, _ := http.NewRequest("PUT", srv.URL+"/x", strings.NewReader(requestJSON))
+	req.Header.Set("Authorization", "Bearer visible")
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	if out.String() != "" {
+		t.Fatalf("dump written before the body was read:\n%s", out.String())
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != responseJSON {
+		t.Fatalf("client saw %q", body)
+	}
+
+	dump := out.String()
+	for _, want := range []string{
+		"--> PUT " + srv.URL + "/x\n",
+		"    Authorization: Bearer visible\n",
+		"{\n\"user\": \"bob\"\n}\n    (truncated: 12 of 36 bytes shown)\n",
+		"<-- 201 Created (",
+		"    (truncated: 12 of 25 bytes shown)\n",
+	} {
+		if !strings.Contains(dump, want) {
+			t.Fatalf("dump lacks %q:\n%s", want, dump)
+		}
+	}
+	if strings.Count(dump, "-->") != 1 {
+		t.Fatalf("expected one dump:\n%s", dump)
+	}
+}
+
+func TestTransportErrorsAndOtherBodies(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		io.WriteString(w, "<p>hi</p>")
+	}))
+	var out syncBuffer
+	client := &http.Client{Transport: Transport(nil, &Options{Out: &out, Format: &prettyx.Options{Palette: "none"}})}
+	withUser := strings.Replace(srv.URL, "://", "://alice:s3cret@", 1)
+	resp, err := client.Get(withUser)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	if strings.Contains(out.String(), "s3cret") || !strings.Contains(out.String(), "://alice:xxxxx@") {
+		t.Fatalf("password not redacted from URL:\n%s", out.String())
+	}
+	if !strings.Contains(out.String(), "    (9 bytes of text/html)\n") {
+		t.Fatalf("unexpected dump:\n%s", out.String())
+	}
+
+	srv.Close()
+	if _, err := client.Get(srv.URL); err == nil {
+		t.Fatal("expected error from closed server")
+	}
+	if !strings.Contains(out.String(), "<-- error: ") {
+		t.Fatalf("error not dumped:\n%s", out.String())
+	}
+}
+
+func TestIsJSON(t *testing.T) {
+	cases := []struct {
+		contentType, body string
+		want              bool
+	}{
+		{"application/json; charset=utf-8", "", true},
+		{"application/problem+json", "", true},
+		{"application/x-ndjson", "", true},
+		{"text/plain", "{}", false},
+		{"", " [1]", true},
+		{"", "hello", false},
+		{"bad;;", "{}", false},
+	}
+	for _, tc := range cases {
+		if got := isJSON(tc.contentType, []byte(tc.body)); got != tc.want {
+			t.Fatalf("isJSON(%q, %q) = %v, want %v", tc.contentType, tc.body, got, tc.want)
+		}
+	}
+}
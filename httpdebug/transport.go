@@ -0,0 +1,65 @@
+package httpdebug
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Transport returns a RoundTripper that dumps each request sent through next
+// and its response. The dump is written once the response body has been read
+// to the end or closed, so callers must close bodies as usual; failed round
+// trips and responses without a body are dumped straight away. Nil next uses
+// http.DefaultTransport.
+func Transport(next http.RoundTripper, opts *Options) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &transport{next: next, d: newDumper(opts)}
+}
+
+type transport struct {
+	next http.RoundTripper
+	d    *dumper
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	d := t.d
+	start := time.Now()
+	reqBody := d.newCapture()
+	sent := req
+	if req.Body != nil && req.Body != http.NoBody && reqBody != nil {
+		// RoundTrippers must not modify the request, so the body is teed on
+		// a shallow copy.
+		sent = new(http.Request)
+		*sent = *req
+		sent.Body = &teeBody{rc: req.Body, c: reqBody}
+	}
+	resp, err := t.next.RoundTrip(sent)
+	elapsed := time.Since(start)
+
+	dump := func(respBody *capture) {
+		var b bytes.Buffer
+		fmt.Fprintf(&b, "--> %s %s\n", req.Method, req.URL.Redacted())
+		d.writeHeaders(&b, req.Header)
+		d.writeBody(&b, reqBody, req.Header.Get("Content-Type"))
+		if err != nil {
+			fmt.Fprintf(&b, "<-- error: %v", err)
+			writeDuration(&b, elapsed)
+		} else {
+			fmt.Fprintf(&b, "<-- %s", resp.Status)
+			writeDuration(&b, elapsed)
+			d.writeHeaders(&b, resp.Header)
+			d.writeBody(&b, respBody, resp.Header.Get("Content-Type"))
+		}
+		d.flush(&b)
+	}
+	if err != nil || resp.Body == nil || resp.Body == http.NoBody || resp.StatusCode == http.StatusSwitchingProtocols {
+		dump(nil)
+		return resp, err
+	}
+	respBody := d.newCapture()
+	resp.Body = &teeBody{rc: resp.Body, c: respBody, done: func() { dump(respBody) }}
+	return resp, nil
+}
@@ -0,0 +1,358 @@
+package prettyx
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"io"
+	"math/big"
+	"path"
+	"strings"
+)
+
+// InputFormat names an input syntax that ConvertReader turns into JSON.
+type InputFormat int
+
+const (
+	// InputJSON is JSON, passed through unchanged.
+	InputJSON InputFormat = iota
+	// InputYAML is a YAML stream; each document becomes one JSON document.
+	InputYAML
+	// InputTOML is a TOML document.
+	InputTOML
+	// InputCSV is CSV with a header row; each record becomes a JSON object
+	// keyed by the header, with every cell as a string. Cells that hold
+	// JSON, such as NDJSON exports, are decoded by Unwrap like any other
+	// string.
+	InputCSV
+	// InputCBOR is a sequence of CBOR (RFC 8949) items; each becomes one JSON
+	// document. See ConvertReader for how binary values map to JSON.
+	InputCBOR
+	// InputMsgPack is a sequence of MessagePack values; each becomes one JSON
+	// document.
+	InputMsgPack
+)
+
+// InputFormatForPath picks the input format from a file name's extension.
+// Unknown extensions, including none, are JSON.
+func InputFormatForPath(name string) InputFormat {
+	switch strings.ToLower(path.Ext(name)) {
+	case ".yaml", ".yml":
+		return InputYAML
+	case ".toml":
+		return InputTOML
+	case ".csv":
+		return InputCSV
+	case ".cbor":
+		return InputCBOR
+	case ".msgpack", ".mpk":
+		return InputMsgPack
+	default:
+		return InputJSON
+	}
+}
+
+// ConvertReader returns a reader that yields the input from r as JSON
+// documents, one per line, so PrettyStream, CompactTo and the other
+// functions can format YAML, TOML, CSV, CBOR and MessagePack unchanged. YAML,
+// CSV and the binary formats are converted a document, record or item at a
+// time; TOML is read whole. Syntax errors in the input are returned from Read
+// as a *SyntaxError; for binary input only its Offset and Document are set.
+// For InputJSON, r is returned as it is.
+//
+// In CBOR and MessagePack, byte strings become base64 strings (CBOR tags 21
+// to 23 select base64url, base64 or hex instead) and map keys that are not
+// strings become the string of their JSON form, so 1 becomes "1". CBOR date,
+// bignum, URI and self-describe tags resolve to their value, decimal
+// fractions to the exact number, and any other tag becomes
+// {"tag":N,"value":...}. MessagePack timestamps become RFC 3339
+// strings and other extension types {"type":N,"data":"<base64>"}. Undefined
+// and NaN become null and infinities ±1.7976931348623157e+308.
+func ConvertReader(r io.Reader, format InputFormat) io.Reader {
+	switch format {
+	case InputYAML:
+		return &convertReader{next: newYAMLStream(r).next}
+	case InputTOML:
+		return &convertReader{next: newTOMLStream(r).next}
+	case InputCSV:
+		return &convertReader{next: newCSVStream(r).next}
+	case InputCBOR:
+		return &convertReader{next: newBinaryStream(r, true).next}
+	case InputMsgPack:
+		return &convertReader{next: newBinaryStream(r, false).next}
+	default:
+		return r
+	}
+}
+
+// convertReader serves the JSON produced by next, which appends the next
+// document to its argument and returns io.EOF once the input is used up.
+type convertReader struct {
+	next func([]byte) ([]byte, error)
+	buf  []byte
+	off  int
+	err  error
+}
+
+func (c *convertReader) Read(p []byte) (int, error) {
+	for c.off == len(c.buf) {
+		if c.err != nil {
+			return 0, c.err
+		}
+		c.buf, c.err = c.next(c.buf[:0])
+		c.off = 0
+		if c.err != nil {
+			// Drop a partly converted document.
+			c.buf = c.buf[:0]
+		}
+	}
+	n := copy(p, c.buf[c.off:])
+	c.off += n
+	return n, nil
+}
+
+type inputKind uint8
+
+const (
+	inputNull inputKind = iota
+	inputBool
+	inputNumber
+	inputString
+	inputObject
+	inputArray
+)
+
+// inputNode is a decoded YAML or TOML value. text holds the JSON number or
+// literal for scalars other than strings, and the decoded string for
+// strings.
+type inputNode struct {
+	kind  inputKind
+	text  string
+	keys  []string
+	items []*inputNode
+}
+
+// member returns the value of key in an object, or nil.
+func (n *inputNode) member(key string) *inputNode {
+	for i, k := range n.keys {
+		if k == key {
+			return n.items[i]
+		}
+	}
+	return nil
+}
+
+func (n *inputNode) set(key string, v *inputNode) {
+	n.keys = append(n.keys, key)
+	n.items = append(n.items, v)
+}
+
+// appendInputJSON appends n as compact JSON.
+func appendInputJSON(dst []byte, n *inputNode) []byte {
+	switch n.kind {
+	case inputNull:
+		return append(dst, "null"...)
+	case inputBool, inputNumber:
+		return append(dst, n.text...)
+	case inputString:
+		return appendJSONString(dst, n.text)
+	case inputObject:
+		dst = append(dst, '{')
+		for i, key := range n.keys {
+			if i > 0 {
+				dst = append(dst, ',')
+			}
+			dst = appendJSONString(dst, key)
+			dst = append(dst, ':')
+			dst = appendInputJSON(dst, n.items[i])
+		}
+		return append(dst, '}')
+	default:
+		dst = append(dst, '[')
+		for i, item := range n.items {
+			if i > 0 {
+				dst = append(dst, ',')
+			}
+			dst = appendInputJSON(dst, item)
+		}
+		return append(dst, ']')
+	}
+}
+
+func appendJSONString(dst []byte, s string) []byte {
+	return append(dst, appendQuotedBytes(nil, []byte(s))...)
+}
+
+// appendJSONNumber appends the decimal number s, which has already been
+// matched against the input syntax, as a JSON number: a '+' sign and leading
+// zeros are dropped and a bare '.' gets a 0 on the side that lacks digits.
+// Hexadecimal, octal and binary integers with a 0x, 0o or 0b prefix are
+// converted to decimal.
+func appendJSONNumber(dst []byte, s string) ([]byte, bool) {
+	if s == "" {
+		return dst, false
+	}
+	neg := s[0] == '-'
+	if s[0] == '-' || s[0] == '+' {
+		s = s[1:]
+	}
+	if s == "" {
+		return dst, false
+	}
+	if len(s) > 2 && s[0] == '0' {
+		base := 0
+		switch s[1] {
+		case 'x', 'X':
+			base = 16
+		case 'o', 'O':
+			base = 8
+		case 'b', 'B':
+			base = 2
+		}
+		if base != 0 {
+			var n big.Int
+			if _, ok := n.SetString(s[2:], base); !ok {
+				return dst, false
+			}
+			if neg {
+				n.Neg(&n)
+			}
+			return n.Append(dst, 10), true
+		}
+	}
+	mantissa, _, _ := strings.Cut(strings.ToLower(s), "e")
+	if !strings.ContainsAny(mantissa, "0123456789") {
+		return dst, false
+	}
+	start := len(dst)
+	if neg {
+		dst = append(dst, '-')
+	}
+	intEnd := strings.IndexAny(s, ".eE")
+	if intEnd < 0 {
+		intEnd = len(s)
+	}
+	digits := strings.TrimLeft(s[:intEnd], "0")
+	if digits == "" {
+		digits = "0"
+	}
+	dst = append(dst, digits...)
+	s = s[intEnd:]
+	if s != "" && s[0] == '.' {
+		frac := s[1:]
+		expAt := strings.IndexAny(frac, "eE")
+		if expAt < 0 {
+			expAt = len(frac)
+		}
+		dst = append(dst, '.')
+		if expAt == 0 {
+			dst = append(dst, '0')
+		}
+		dst = append(dst, frac[:expAt]...)
+		s = frac[expAt:]
+	}
+	if s != "" {
+		exp := s[1:]
+		if exp != "" && exp[0] == '+' {
+			exp = exp[1:]
+		}
+		dst = append(dst, 'e')
+		dst = append(dst, exp...)
+	}
+	if !validJSONNumber(dst[start:]) {
+		return dst[:start], false
+	}
+	return dst, true
+}
+
+func validJSONNumber(num []byte) bool {
+	if len(num) > 0 && num[0] == '-' {
+		num = num[1:]
+	}
+	if len(num) == 0 {
+		return false
+	}
+	state, ok := numStartState(num[0])
+	for i := 1; ok && i < len(num); i++ {
+		state, ok = numNextState(state, num[i])
+	}
+	return ok && numIsTerminal(state)
+}
+
+// textError builds a SyntaxError for src[pos] in a document whose first byte
+// is at stream offset base on the given 1-based line.
+func textError(src []byte, pos int, base int64, line int, doc int, msg string) *SyntaxError {
+	if pos > len(src) {
+		pos = len(src)
+	}
+	lineStart := bytes.LastIndexByte(src[:pos], '\n') + 1
+	line += bytes.Count(src[:pos], newlineBytes)
+	lineEnd := bytes.IndexByte(src[pos:], '\n')
+	if lineEnd < 0 {
+		lineEnd = len(src)
+	} else {
+		lineEnd += pos
+	}
+	start := max(lineStart, pos-excerptRadius)
+	end := min(lineEnd, pos+excerptRadius)
+	if end > pos && src[end-1] == '\r' {
+		end--
+	}
+	return &SyntaxError{
+		Msg:           msg,
+		Offset:        base + int64(pos),
+		Line:          line,
+		Column:        pos - lineStart + 1,
+		Document:      doc,
+		Excerpt:       string(src[start:end]),
+		ExcerptOffset: pos - start,
+	}
+}
+
+// csvStream converts CSV records to JSON objects keyed by the header row.
+type csvStream struct {
+	r      *csv.Reader
+	header []string
+	row    int
+}
+
+func newCSVStream(r io.Reader) *csvStream {
+	cr := csv.NewReader(bufio.NewReader(r))
+	cr.ReuseRecord = true
+	return &csvStream{r: cr}
+}
+
+func (s *csvStream) next(dst []byte) ([]byte, error) {
+	for {
+		rec, err := s.r.Read()
+		if err != nil {
+			var pe *csv.ParseError
+			if errors.As(err, &pe) {
+				return dst, &SyntaxError{
+					Msg:      pe.Err.Error(),
+					Offset:   s.r.InputOffset(),
+					Line:     pe.Line,
+					Column:   pe.Column,
+					Document: s.row + 1,
+				}
+			}
+			return dst, err
+		}
+		if s.header == nil {
+			s.header = append([]string(nil), rec...)
+			continue
+		}
+		s.row++
+		dst = append(dst, '{')
+		for i, cell := range rec {
+			if i > 0 {
+				dst = append(dst, ',')
+			}
+			dst = appendJSONString(dst, s.header[i])
+			dst = append(dst, ':')
+			dst = appendJSONString(dst, cell)
+		}
+		return append(dst, '}', '\n'), nil
+	}
+}
@@ -0,0 +1,282 @@
+package prettyx
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+)
+
+func convertString(t *testing.T, input string, format InputFormat) (string, error) {
+	t.Helper()
+	out, err := io.ReadAll(ConvertReader(strings.NewReader(input), format))
+	return string(out), err
+}
+
+func TestConvertYAML(t *testing.T) {
+	input := `%YAML 1.2
+# leading comment
+---
+apiVersion: v1
+kind: Pod
+metadata:
+  name: web   # trailing comment
+  labels: {app: web, "tier": 'front'}
+spec:
+  containers:
+  - name: app
+    image: nginx:1.25
+    args: [--port, "8080"]
+    env:
+      - name: A
+        value: plain text
+          that folds
+  - name: side
+    command:
+    - - nested
+      - seq
+notes:
+  literal: |
+    one
+      two
+  folded: >-
+    a
+    b
+
+    c
+  keep: |+
+    x
+
+types: [~, null, true, False, 012, 0o17, 0x1f, +1.5, .5, 1e3, -.inf, .nan, 1.2.3, "true", !!str 42, yes]
+anchors:
+  base: &base {a: 1, b: 2}
+  merged:
+    <<: *base
+    b: 3
+  ref: *base
+quotes: ["it''s", 'it''s', "tab\tnl\nu\u00e9"]
+...
+--- [1, 2]
+---
+# empty document is skipped
+---
+plain scalar
+`
+	want := `{"apiVersion":"v1","kind":"Pod","metadata":{"name":"web","labels":{"app":"web","tier":"front"}},"spec":{"containers":[{"name":"app","image":"nginx:1.25","args":["--port","8080"],"env":[{"name":"A","value":"plain text that folds"}]},{"name":"side","command":[["nested","seq"]]}]},"notes":{"literal":"one\n  two\n","folded":"a b\nc","keep":"x\n\n"},"types":[null,null,true,false,12,15,31,1.5,0.5,1e3,-1.7976931348623157e+308,null,"1.2.3","true","42","yes"],"anchors":{"base":{"a":1,"b":2},"merged":{"b":3,"a":1},"ref":{"a":1,"b":2}},"quotes":["it''s","it's","tab\tnl\nué"]}
+[1,2]
+"plain scalar"
+`
+	got, err := convertString(t, input, InputYAML)
+	if err != nil {
+		t.Fatalf("convert failed: %v", err)
+	}
+	if got != want {
+		t.Fatalf("unexpected output\nexpected:\n%s\nactual:\n%s", want, got)
+	}
+}
+
+func TestConvertYAMLPretty(t *testing.T) {
+	opts := *DefaultOptions
+	opts.Palette = "none"
+	var buf bytes.Buffer
+	r := ConvertReader(strings.NewReader("a: 1\n---\nb: [x]\n"), InputYAML)
+	if err := PrettyStream(&buf, r, &opts); err != nil {
+		t.Fatalf("PrettyStream failed: %v", err)
+	}
+	want := "{\n  \"a\": 1\n}\n{\n  \"b\": [\n    \"x\"\n  ]\n}\n"
+	if buf.String() != want {
+		t.Fatalf("unexpected output\nexpected:\n%s\nactual:\n%s", want, buf.String())
+	}
+}
+
+// yamlLaughs nests nine-element aliases eight levels deep, which expands to
+// tens of millions of nodes.
+var yamlLaughs = func() string {
+	var b strings.Builder
+	b.WriteString("a: &a [x, x, x, x, x, x, x, x, x]\n")
+	for c := 'b'; c <= 'h'; c++ {
+		prev := "*" + string(c-1)
+		fmt.Fprintf(&b, "%c: &%c [%s]\n", c, c, strings.Repeat(prev+", ", 8)+prev)
+	}
+	return b.String()
+}()
+
+func TestConvertYAMLErrors(t *testing.T) {
+	cases := []struct {
+		input string
+		msg   string
+		line  int
+		col   int
+		doc   int
+	}{
+		{"a: 1\n  b: 2\n", "unexpected indentation", 2, 3, 1},
+		{"a: [1, 2\n", "unterminated flow collection", 1, 4, 1},
+		{"a: 1\n---\nb: *nope\n", "unknown alias", 3, 4, 2},
+		{"a: \"x\n", "unterminated quoted scalar", 1, 4, 1},
+		{"a: \"\\q\"\n", "invalid escape sequence", 1, 5, 1},
+		{"- a\nb: 1\n", "unexpected content", 2, 1, 1},
+		{yamlLaughs, "too many nodes expanded from aliases", 7, 8, 1},
+	}
+	for _, tc := range cases {
+		_, err := convertString(t, tc.input, InputYAML)
+		var se *SyntaxError
+		if !errors.As(err, &se) {
+			t.Fatalf("%q: expected SyntaxError, got %v", tc.input, err)
+		}
+		if se.Msg != tc.msg || se.Line != tc.line || se.Column != tc.col || se.Document != tc.doc {
+			t.Fatalf("%q: got %q at %d:%d doc %d, want %q at %d:%d doc %d", tc.input, se.Msg, se.Line, se.Column, se.Document, tc.msg, tc.line, tc.col, tc.doc)
+		}
+	}
+}
+
+func TestConvertTOML(t *testing.T) {
+	input := `# config
+title = "TOML \"Example\" \u00e9"
+path = 'C:\temp'
+multi = """
+Roses are red \
+    violets"""
+raw = '''
+keep \n as is'''
+ints = [1_000, 0xDEAD_beef, 0o17, 0b101, -17, +3]
+floats = [6.626e-34, 1e+3, inf, -inf, nan]
+ok = true
+dates = [1979-05-27T07:32:00-08:00, 1979-05-27, 07:32:00]
+local = 1979-05-27 07:32:00
+nested = [
+  [1, 2], # comment
+  ["a"],
+]
+inline = { x = 1, y.z = "w" }
+a.b.c = 1
+
+[server]
+host = "localhost"
+
+[server.tls]
+enabled = false
+
+[[products]]
+name = "Hammer"
+
+[[products]]
+name = "Nail"
+
+[products.meta]
+sku = 284758393
+`
+	want := `{"title":"TOML \"Example\" é","path":"C:\\temp","multi":"Roses are red violets","raw":"keep \\n as is","ints":[1000,3735928559,15,5,-17,3],"floats":[6.626e-34,1e3,1.7976931348623157e+308,-1.7976931348623157e+308,null],"ok":true,"dates":["1979-05-27T07:32:00-08:00","1979-05-27","07:32:00"],"local":"1979-05-27 07:32:00","nested":[[1,2],["a"]],"inline":{"x":1,"y":{"z":"w"}},"a":{"b":{"c":1}},"server":{"host":"localhost","tls":{"enabled":false}},"products":[{"name":"Hammer"},{"name":"Nail","meta":{"sku":284758393}}]}
+`
+	got, err := convertString(t, input, InputTOML)
+	if err != nil {
+		t.Fatalf("convert failed: %v", err)
+	}
+	if got != want {
+		t.Fatalf("unexpected output\nexpected:\n%s\nactual:\n%s", want, got)
+	}
+	if got, err := convertString(t, "", InputTOML); err != nil || got != "{}\n" {
+		t.Fatalf("empty document = %q, %v", got, err)
+	}
+}
+
+func TestConvertTOMLErrors(t *testing.T) {
+	cases := []struct {
+		input string
+		msg   string
+		line  int
+	}{
+		{"a = 1\na = 2\n", `duplicate key "a"`, 2},
+		{"[t]\nx = 1\n[t]\n", "duplicate table", 3},
+		{"a = 01\n", "invalid value", 1},
+		{"a = \"x\" y\n", "expected end of line", 1},
+		{"a = { b = 1 }\n[a]\n", "duplicate table", 2},
+		{"a = [1, 2\n", "unterminated array", 1},
+		{"a = \"unterminated\n", "unterminated string", 1},
+	}
+	for _, tc := range cases {
+		_, err := convertString(t, tc.input, InputTOML)
+		var se *SyntaxError
+		if !errors.As(err, &se) {
+			t.Fatalf("%q: expected SyntaxError, got %v", tc.input, err)
+		}
+		if se.Msg != tc.msg || se.Line != tc.line {
+			t.Fatalf("%q: got %q on line %d, want %q on line %d", tc.input, se.Msg, se.Line, tc.msg, tc.line)
+		}
+	}
+}
+
+func TestConvertCSV(t *testing.T) {
+	input := "name,event\nweb,\"{\"\"level\"\":\"\"info\"\"}\"\ndb,plain\n"
+	got, err := convertString(t, input, InputCSV)
+	if err != nil {
+		t.Fatalf("convert failed: %v", err)
+	}
+	want := `{"name":"web","event":"{\"level\":\"info\"}"}
+{"name":"db","event":"plain"}
+`
+	if got != want {
+		t.Fatalf("unexpected output\nexpected:\n%s\nactual:\n%s", want, got)
+	}
+
+	var buf bytes.Buffer
+	opts := &Options{Unwrap: true}
+	if err := CompactTo(&buf, ConvertReader(strings.NewReader(input), InputCSV), opts); err != nil {
+		t.Fatalf("CompactTo failed: %v", err)
+	}
+	want = `{"name":"web","event":{"level":"info"}}
+{"name":"db","event":"plain"}
+`
+	if buf.String() != want {
+		t.Fatalf("unexpected unwrapped output\nexpected:\n%s\nactual:\n%s", want, buf.String())
+	}
+
+	_, err = convertString(t, "a,b\n1,2,3\n", InputCSV)
+	var se *SyntaxError
+	if !errors.As(err, &se) || se.Line != 2 {
+		t.Fatalf("expected SyntaxError on line 2, got %v", err)
+	}
+}
+
+func TestInputFormatForPath(t *testing.T) {
+	cases := map[string]InputFormat{
+		"deploy.yaml":       InputYAML,
+		"deploy.YML":        InputYAML,
+		"/etc/app.toml":     InputTOML,
+		"export.csv":        InputCSV,
+		"data.json":         InputJSON,
+		"noext":             InputJSON,
+		"/api/v1/pods.yaml": InputYAML,
+	}
+	for name, want := range cases {
+		if got := InputFormatForPath(name); got != want {
+			t.Fatalf("InputFormatForPath(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestAppendJSONNumber(t *testing.T) {
+	cases := map[string]string{
+		"0":      "0",
+		"+12":    "12",
+		"-007":   "-7",
+		".5":     "0.5",
+		"5.":     "5.0",
+		"1e+3":   "1e3",
+		"-0x1F":  "-31",
+		"0b1010": "10",
+		"0o777":  "511",
+	}
+	for in, want := range cases {
+		got, ok := appendJSONNumber(nil, in)
+		if !ok || string(got) != want {
+			t.Fatalf("appendJSONNumber(%q) = %q, %v; want %q", in, got, ok, want)
+		}
+	}
+	for _, in := range []string{"", "-", "0x", "1e", "."} {
+		if got, ok := appendJSONNumber(nil, in); ok {
+			t.Fatalf("appendJSONNumber(%q) = %q, want failure", in, got)
+		}
+	}
+}
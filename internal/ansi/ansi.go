@@ -33,6 +33,7 @@ type Palette struct {
 	Nil         string
 	Brackets    string
 	Punctuation string
+	Comment     string
 	Trace       string
 	Debug       string
 	Info        string
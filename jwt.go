@@ -0,0 +1,131 @@
+package prettyx
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"math"
+	"time"
+)
+
+// JWTMode selects how Unwrap treats JSON Web Tokens in compact JWS
+// serialization, such as "eyJhbGciOiJIUzI1NiJ9.eyJzdWIiOiIxIn0.c2ln".
+type JWTMode int
+
+const (
+	// JWTDecode renders each token as an object holding its decoded header
+	// and claims. It is the default.
+	JWTDecode JWTMode = iota
+	// JWTDecodeTimes decodes tokens like JWTDecode and adds the exp, iat
+	// and nbf claims as RFC 3339 times in UTC.
+	JWTDecodeTimes
+	// JWTKeep leaves tokens as strings.
+	JWTKeep
+)
+
+// JWTMarker is the value of the "$jwt" member that starts every decoded
+// token, so a decoded token cannot be mistaken for JSON from the input.
+const JWTMarker = "decoded, signature not verified"
+
+// jwtTimeClaims are the NumericDate claims JWTDecodeTimes renders, in the
+// order they are written.
+var jwtTimeClaims = [...]string{"iat", "nbf", "exp"}
+
+// decodeJWT appends the object a token decodes to:
+//
+//	{"$jwt":"decoded, signature not verified","scheme":"Bearer",
+//	 "header":{...},"claims":{...},"signature":"...","$times":{...}}
+//
+// scheme is present only for tokens written after "Bearer ", as in an
+// Authorization header, and $times only with times set and time claims
+// present. The header and claims must be base64url-encoded JSON objects; the
+// signature is kept as written.
+func decodeJWT(dst, src []byte, limit int, times bool) ([]byte, bool) {
+	var scheme []byte
+	if len(src) > 7 && bytes.EqualFold(src[:7], []byte("bearer ")) {
+		scheme, src = src[:6], trimSpaceBytes(src[7:])
+	}
+	// The header of a token always starts with {", which encodes to eyJ.
+	if !bytes.HasPrefix(src, []byte("eyJ")) || bytes.Count(src, []byte{'.'}) != 2 {
+		return dst, false
+	}
+	for _, c := range src {
+		if !isBase64URLByte(c) && c != '.' {
+			return dst, false
+		}
+	}
+	header, rest, _ := bytes.Cut(src, []byte{'.'})
+	claims, signature, _ := bytes.Cut(rest, []byte{'.'})
+	enc := base64.RawURLEncoding
+	if enc.DecodedLen(len(header))+enc.DecodedLen(len(claims))+len(signature)+len(JWTMarker)+128 > limit {
+		return dst, false
+	}
+
+	start := len(dst)
+	dst = append(dst, `{"$jwt":`...)
+	dst = appendJSONString(dst, JWTMarker)
+	if scheme != nil {
+		dst = append(dst, `,"scheme":`...)
+		dst = appendJSONString(dst, string(scheme))
+	}
+	var err error
+	dst = append(dst, `,"header":`...)
+	headerStart := len(dst)
+	if dst, err = enc.AppendDecode(dst, header); err != nil || !isJSONObjectText(dst[headerStart:]) {
+		return dst[:start], false
+	}
+	dst = append(dst, `,"claims":`...)
+	claimsStart := len(dst)
+	if dst, err = enc.AppendDecode(dst, claims); err != nil || !isJSONObjectText(dst[claimsStart:]) {
+		return dst[:start], false
+	}
+	claimsJSON := dst[claimsStart:]
+	dst = append(dst, `,"signature":`...)
+	dst = appendJSONString(dst, string(signature))
+	if times {
+		dst = appendJWTTimes(dst, claimsJSON)
+	}
+	return append(dst, '}'), true
+}
+
+// appendJWTTimes appends a "$times" member with the NumericDate claims of
+// claims as RFC 3339 times, if it has any.
+func appendJWTTimes(dst, claims []byte) []byte {
+	var values map[string]json.RawMessage
+	if json.Unmarshal(claims, &values) != nil {
+		return dst
+	}
+	n := 0
+	for _, name := range jwtTimeClaims {
+		var secs float64
+		if json.Unmarshal(values[name], &secs) != nil || math.IsInf(secs, 0) || math.Abs(secs) > 1e11 {
+			continue
+		}
+		if n == 0 {
+			dst = append(dst, `,"$times":{`...)
+		} else {
+			dst = append(dst, ',')
+		}
+		n++
+		whole, frac := math.Modf(secs)
+		t := time.Unix(int64(whole), int64(frac*1e9)).UTC()
+		dst = appendJSONString(dst, name)
+		dst = append(dst, ':')
+		dst = appendJSONString(dst, t.Format(time.RFC3339Nano))
+	}
+	if n > 0 {
+		dst = append(dst, '}')
+	}
+	return dst
+}
+
+func isBase64URLByte(c byte) bool {
+	return c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '-' || c == '_'
+}
+
+// isJSONObjectText reports whether b looks like a JSON object. The whole
+// decoded token is validated before it is unwrapped.
+func isJSONObjectText(b []byte) bool {
+	b = trimSpaceBytes(b)
+	return len(b) >= 2 && b[0] == '{' && b[len(b)-1] == '}'
+}
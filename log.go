@@ -0,0 +1,322 @@
+package prettyx
+
+// LogLayout selects how Log mode renders records.
+type LogLayout int
+
+const (
+	// LogOff formats log records like any other JSON. This is the default.
+	LogOff LogLayout = iota
+	// LogTree keeps the pretty tree layout but colours the level, time and
+	// message members with the palette's log styles.
+	LogTree
+	// LogConsole renders each record on one line as
+	// "TIME LEVEL message key=value ...".
+	LogConsole
+)
+
+// LogKeys lists the member names recognised as a record's level, time and
+// message. When a record has several candidates for one role, the name that
+// appears first in the list wins.
+type LogKeys struct {
+	Level   []string
+	Time    []string
+	Message []string
+}
+
+// DefaultLogKeys covers pslog, zerolog, zap, logrus and log/slog output.
+var DefaultLogKeys = &LogKeys{
+	Level:   []string{"level", "lvl", "severity"},
+	Time:    []string{"time", "ts", "timestamp", "@timestamp"},
+	Message: []string{"msg", "message"},
+}
+
+type logRole uint8
+
+const (
+	logRoleNone logRole = iota
+	logRoleTime
+	logRoleLevel
+	logRoleMessage
+)
+
+type logLevel int
+
+const (
+	levelUnknown logLevel = iota
+	levelTrace
+	levelDebug
+	levelInfo
+	levelWarn
+	levelError
+	levelFatal
+	levelPanic
+)
+
+var levelLabels = [...]string{"", "TRACE", "DEBUG", "INFO", "WARN", "ERROR", "FATAL", "PANIC"}
+
+const levelLabelWidth = 5
+
+// parseLogRecord captures a top-level object like parseSortedObject, assigns
+// log roles to its members and renders it with the configured layout.
+func (p *parser) parseLogRecord(depth int) error {
+	out := p.formatter
+	frame := p.pushSortFrame()
+	p.formatter = &frame.fmt
+	err := p.captureObjectMembers(frame)
+	p.formatter = out
+	if err == nil {
+		p.sortMembers(frame)
+		p.assignLogRole(frame, logRoleTime, p.logKeys.Time)
+		p.assignLogRole(frame, logRoleLevel, p.logKeys.Level)
+		p.assignLogRole(frame, logRoleMessage, p.logKeys.Message)
+		if p.log == LogConsole && len(frame.members) > 0 {
+			err = p.emitConsoleRecord(frame)
+		} else {
+			err = p.emitCapturedObject(frame, depth)
+		}
+	}
+	p.popSortFrame()
+	return err
+}
+
+func (p *parser) assignLogRole(frame *sortFrame, role logRole, names []string) {
+	best, rank := -1, len(names)
+	for i := range frame.members {
+		m := &frame.members[i]
+		if m.role != logRoleNone {
+			continue
+		}
+		key := frame.buf[m.keyStart:m.keyEnd]
+		for j, name := range names[:rank] {
+			if string(key) == name {
+				best, rank = i, j
+				break
+			}
+		}
+	}
+	if best >= 0 {
+		frame.members[best].role = role
+	}
+}
+
+// logStyle returns the palette style for a scalar member value with a log
+// role.
+func (p *parser) logStyle(role logRole, value []byte) string {
+	pal := &p.formatter.pal
+	switch role {
+	case logRoleTime:
+		return pal.Timestamp
+	case logRoleMessage:
+		return pal.Message
+	case logRoleLevel:
+		return levelStyle(pal, p.decodeLevel(value))
+	default:
+		return ""
+	}
+}
+
+func (p *parser) decodeLevel(value []byte) logLevel {
+	if value[0] != '"' {
+		return levelUnknown
+	}
+	p.scratch = appendUnquoted(p.scratch[:0], value[1:len(value)-1])
+	return parseLogLevel(p.scratch)
+}
+
+// parseLogLevel maps a level name to a logLevel. Matching is by
+// case-insensitive prefix so "WARNING", "DEBUG-4" and "err" are recognised.
+func parseLogLevel(s []byte) logLevel {
+	switch {
+	case hasPrefixFold(s, "trace"):
+		return levelTrace
+	case hasPrefixFold(s, "debug"):
+		return levelDebug
+	case hasPrefixFold(s, "info"):
+		return levelInfo
+	case hasPrefixFold(s, "warn"):
+		return levelWarn
+	case hasPrefixFold(s, "err"):
+		return levelError
+	case hasPrefixFold(s, "fatal"), hasPrefixFold(s, "crit"), hasPrefixFold(s, "alert"), hasPrefixFold(s, "emerg"):
+		return levelFatal
+	case hasPrefixFold(s, "panic"), hasPrefixFold(s, "dpanic"):
+		return levelPanic
+	default:
+		return levelUnknown
+	}
+}
+
+func levelStyle(pal *ColorPalette, level logLevel) string {
+	switch level {
+	case levelTrace:
+		return pal.Trace
+	case levelDebug:
+		return pal.Debug
+	case levelInfo:
+		return pal.Info
+	case levelWarn:
+		return pal.Warn
+	case levelError:
+		return pal.Error
+	case levelFatal:
+		return pal.Fatal
+	case levelPanic:
+		return pal.Panic
+	default:
+		return pal.NoLevel
+	}
+}
+
+func hasPrefixFold(s []byte, prefix string) bool {
+	if len(s) < len(prefix) {
+		return false
+	}
+	for i := 0; i < len(prefix); i++ {
+		c := s[i]
+		if c >= 'A' && c <= 'Z' {
+			c += 'a' - 'A'
+		}
+		if c != prefix[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// emitConsoleRecord writes the time, level and message members followed by
+// the remaining members as key=value pairs. Non-scalar values are written as
+// compact JSON.
+func (p *parser) emitConsoleRecord(frame *sortFrame) error {
+	f := p.formatter
+	compact := f.compact
+	f.compact = true
+	err := p.writeConsoleRecord(frame)
+	f.compact = compact
+	return err
+}
+
+func (p *parser) writeConsoleRecord(frame *sortFrame) error {
+	f := p.formatter
+	buf := frame.buf
+	first := true
+	space := func() error {
+		if first {
+			first = false
+			return nil
+		}
+		return f.writeByte(' ')
+	}
+	for _, role := range [...]logRole{logRoleTime, logRoleLevel, logRoleMessage} {
+		for _, m := range frame.members {
+			if m.role != role {
+				continue
+			}
+			if err := space(); err != nil {
+				return err
+			}
+			if err := p.writeConsoleRole(role, buf[m.valueStart:m.valueEnd]); err != nil {
+				return err
+			}
+			break
+		}
+	}
+	for _, m := range frame.members {
+		if m.role != logRoleNone {
+			continue
+		}
+		if err := space(); err != nil {
+			return err
+		}
+		key := buf[m.keyStart:m.keyEnd]
+		if !isBareWord(key) {
+			key = buf[m.rawStart:m.rawEnd]
+		}
+		if err := f.writeStyledBytes(f.pal.Key, key); err != nil {
+			return err
+		}
+		if err := f.writePunctuation("="); err != nil {
+			return err
+		}
+		if err := p.writeConsoleValue(buf[m.valueStart:m.valueEnd]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *parser) writeConsoleRole(role logRole, value []byte) error {
+	f := p.formatter
+	if value[0] != '"' {
+		if !isScalarToken(value) {
+			return p.replayValue(value, 0)
+		}
+		return f.writeStyledBytes(p.logStyle(role, value), value)
+	}
+	if role == logRoleLevel {
+		level := p.decodeLevel(value)
+		style := levelStyle(&f.pal, level)
+		if level == levelUnknown {
+			if !isBareWord(p.scratch) {
+				return f.writeStyledBytes(style, value)
+			}
+			return f.writeStyledBytes(style, p.scratch)
+		}
+		label := levelLabels[level]
+		if err := f.writeStyledString(style, label); err != nil {
+			return err
+		}
+		for i := len(label); i < levelLabelWidth; i++ {
+			if err := f.writeByte(' '); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	p.scratch = appendUnquoted(p.scratch[:0], value[1:len(value)-1])
+	text := p.scratch
+	if !isPrintable(text) {
+		text = value
+	}
+	return f.writeStyledBytes(p.logStyle(role, value), text)
+}
+
+func (p *parser) writeConsoleValue(value []byte) error {
+	if value[0] != '"' {
+		return p.replayValue(value, 0)
+	}
+	p.scratch = appendUnquoted(p.scratch[:0], value[1:len(value)-1])
+	text := p.scratch
+	if !isBareWord(text) {
+		text = value
+	}
+	return p.formatter.writeStyledBytes(p.formatter.pal.String, text)
+}
+
+// isScalarToken reports whether a captured compact value is not an object or
+// array.
+func isScalarToken(value []byte) bool {
+	return len(value) > 0 && value[0] != '{' && value[0] != '['
+}
+
+// isPrintable reports whether s can be written to a terminal as-is.
+func isPrintable(s []byte) bool {
+	for _, c := range s {
+		if c < 0x20 || c == 0x7f {
+			return false
+		}
+	}
+	return true
+}
+
+// isBareWord reports whether s can appear unquoted in a key=value pair.
+func isBareWord(s []byte) bool {
+	if len(s) == 0 {
+		return false
+	}
+	for _, c := range s {
+		if c <= ' ' || c == 0x7f || c == '"' || c == '=' {
+			return false
+		}
+	}
+	return true
+}
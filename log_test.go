@@ -0,0 +1,139 @@
+package prettyx
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"pkt.systems/prettyx/internal/ansi"
+)
+
+const logInput = `{"time":"2026-10-16T10:00:00Z","level":"WARNING","msg":"disk almost full","path":"/var/lib","free":0.05,"tags":["a"],"note":"two words","":1}
+{"ts":1760608800,"lvl":"debug","message":"line\nbreak","msg":"preferred"}
+{"severity":"notice"}
+{}
+[{"level":"info"}]
+`
+
+func TestLog_ConsoleLayout(t *testing.T) {
+	opts := *DefaultOptions
+	opts.Palette = "none"
+	opts.Log = LogConsole
+	var buf bytes.Buffer
+	if err := PrettyStream(&buf, strings.NewReader(logInput), &opts); err != nil {
+		t.Fatalf("PrettyStream failed: %v", err)
+	}
+	want := `2026-10-16T10:00:00Z WARN  disk almost full path=/var/lib free=0.05 tags=["a"] note="two words" ""=1
+1760608800 DEBUG preferred message="line\nbreak"
+notice
+{}
+[
+  {
+    "level": "info"
+  }
+]
+`
+	if buf.String() != want {
+		t.Fatalf("unexpected console output\nexpected:\n%s\nactual:\n%s", want, buf.String())
+	}
+}
+
+func TestLog_TreeLayoutColours(t *testing.T) {
+	opts := *DefaultOptions
+	opts.ForceColor = true
+	opts.Palette = "pslog"
+	opts.Log = LogTree
+	got, err := Pretty([]byte(`{"level":"error","msg":"boom","n":1}`), &opts)
+	if err != nil {
+		t.Fatalf("Pretty failed: %v", err)
+	}
+	for _, frag := range []string{
+		ansi.BrightRed + `"error"` + ansi.Reset,
+		ansi.Cyan + `"msg"` + ansi.Reset,
+		ansi.Bold + `"boom"` + ansi.Reset,
+	} {
+		if !bytes.Contains(got, []byte(frag)) {
+			t.Fatalf("expected %q in %q", frag, got)
+		}
+	}
+
+	opts.Log = LogOff
+	plain, err := Pretty([]byte(`{"level":"error","msg":"boom","n":1}`), &opts)
+	if err != nil {
+		t.Fatalf("Pretty failed: %v", err)
+	}
+	opts.Palette = "none"
+	opts.Log = LogTree
+	tree, err := Pretty([]byte(`{"level":"error","msg":"boom","n":1}`), &opts)
+	if err != nil {
+		t.Fatalf("Pretty failed: %v", err)
+	}
+	if bytes.Contains(plain, []byte(ansi.BrightRed)) || string(tree) != "{\n  \"level\": \"error\",\n  \"msg\": \"boom\",\n  \"n\": 1\n}\n" {
+		t.Fatalf("unexpected layout %q / %q", plain, tree)
+	}
+}
+
+func TestLog_CustomKeysSortAndUnwrap(t *testing.T) {
+	opts := *DefaultOptions
+	opts.Palette = "none"
+	opts.Log = LogConsole
+	opts.Unwrap = true
+	opts.SortKeys = KeyOrderBytes
+	opts.LogKeys = &LogKeys{Level: []string{"sev"}, Message: []string{"text"}}
+	var buf bytes.Buffer
+	input := `{"text":"hi","sev":"E","z":1,"a":"{\"y\":1,\"x\":2}","level":"info"}`
+	if err := PrettyStream(&buf, strings.NewReader(input), &opts); err != nil {
+		t.Fatalf("PrettyStream failed: %v", err)
+	}
+	if want := "E hi a={\"x\":2,\"y\":1} level=info z=1\n"; buf.String() != want {
+		t.Fatalf("unexpected output %q", buf.String())
+	}
+}
+
+func TestLog_Compact(t *testing.T) {
+	input := `{"level":"info","msg":"m","a":1}` + "\n" + `{"level":"warn","msg":"w"}`
+	want := map[LogLayout]string{
+		LogConsole: "INFO  m a=1\nWARN  w\n",
+		LogTree:    `{"level":"info","msg":"m","a":1}` + "\n" + `{"level":"warn","msg":"w"}` + "\n",
+	}
+	for layout, w := range want {
+		out, err := CompactToBuffer(strings.NewReader(input), &Options{Log: layout})
+		if err != nil {
+			t.Fatalf("CompactToBuffer failed: %v", err)
+		}
+		if string(out) != w {
+			t.Fatalf("layout %v: got %q, want %q", layout, out, w)
+		}
+	}
+}
+
+func TestLog_Errors(t *testing.T) {
+	opts := *DefaultOptions
+	opts.Log = LogConsole
+	if err := PrettyStream(io.Discard, strings.NewReader(`{"msg":1,}`), &opts); err == nil {
+		t.Fatalf("expected syntax error")
+	}
+	if err := PrettyStream(errWriter{}, strings.NewReader(`{"msg":"x"}`), &opts); err == nil {
+		t.Fatalf("expected writer error")
+	}
+}
+
+func TestParseLogLevel(t *testing.T) {
+	cases := map[string]logLevel{
+		"TRACE":   levelTrace,
+		"DEBUG-4": levelDebug,
+		"info":    levelInfo,
+		"Warning": levelWarn,
+		"err":     levelError,
+		"CRIT":    levelFatal,
+		"dpanic":  levelPanic,
+		"notice":  levelUnknown,
+		"":        levelUnknown,
+	}
+	for in, want := range cases {
+		if got := parseLogLevel([]byte(in)); got != want {
+			t.Fatalf("parseLogLevel(%q) = %d, want %d", in, got, want)
+		}
+	}
+}
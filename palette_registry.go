@@ -79,6 +79,17 @@ func colorPaletteFromAnsi(ap ansi.Palette) ColorPalette {
 		punct = brackets
 	}
 
+	comment := ap.Comment
+	if comment == "" {
+		comment = ap.Nil
+	}
+	// Palettes without log colours (such as jq's) borrow pslog's so Log mode
+	// still distinguishes levels.
+	logPal := ap
+	if ap.Info == "" {
+		logPal = ansi.PaletteDefault
+	}
+
 	return ColorPalette{
 		Key:         ap.Key,
 		String:      ap.String,
@@ -88,6 +99,18 @@ func colorPaletteFromAnsi(ap ansi.Palette) ColorPalette {
 		Null:        ap.Nil,
 		Brackets:    brackets,
 		Punctuation: punct,
+		Comment:     comment,
+		Trace:       logPal.Trace,
+		Debug:       logPal.Debug,
+		Info:        logPal.Info,
+		Warn:        logPal.Warn,
+		Error:       logPal.Error,
+		Fatal:       logPal.Fatal,
+		Panic:       logPal.Panic,
+		NoLevel:     logPal.NoLevel,
+		Timestamp:   logPal.Timestamp,
+		MessageKey:  logPal.MessageKey,
+		Message:     logPal.Message,
 	}
 }
 
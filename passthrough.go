@@ -0,0 +1,157 @@
+package prettyx
+
+import (
+	"cmp"
+	"io"
+	"slices"
+)
+
+// streamLines is stream for Passthrough mode. Every input line produces
+// output; only the JSON objects and arrays found inside it are reformatted.
+func (p *parser) streamLines() error {
+	for {
+		line, err := p.scanner.readLine(p.lineBuf[:0])
+		p.lineBuf = line
+		if err != nil && err != io.EOF {
+			return err
+		}
+		if err == io.EOF && len(line) == 0 {
+			return nil
+		}
+		if werr := p.writeMixedLine(line); werr != nil {
+			return werr
+		}
+		if err == io.EOF {
+			return nil
+		}
+	}
+}
+
+// writeMixedLine formats each JSON value that starts at a '{' or '[' in line
+// and parses completely, and copies the text in between as it is. Only the
+// spans jsonSpans pairs up are parsed, and at most a few times the length of
+// the line is parsed in all, so hostile lines take linear time.
+func (p *parser) writeMixedLine(line []byte) error {
+	start := 0
+	budget := 4*len(line) + 256
+	for _, span := range p.jsonSpans(line) {
+		n := span.end - span.start
+		if span.start < start {
+			continue
+		}
+		if budget -= n; budget < 0 {
+			break
+		}
+		if p.validValueLen(line[span.start:span.end]) != n {
+			continue
+		}
+		if err := p.writeMixedText(line[start:span.start]); err != nil {
+			return err
+		}
+		p.doc++
+		if err := p.formatEmbedded(line[span.start:span.end]); err != nil {
+			return err
+		}
+		start = span.end
+	}
+	if err := p.writeMixedText(line[start:]); err != nil {
+		return err
+	}
+	if err := p.formatter.writeByte('\n'); err != nil {
+		return err
+	}
+	p.formatter.lineLen = 0
+	return nil
+}
+
+// mixedSpan is line[start:end], from a '{' or '[' to its matching bracket.
+type mixedSpan struct {
+	start, end int
+}
+
+// jsonSpans pairs every '{' and '[' in line with its matching bracket in one
+// pass, skipping brackets inside strings, and returns the spans ordered by
+// start. Quotes only open strings inside brackets, so the text around the
+// values cannot hide one; a mismatched bracket drops every open span.
+func (p *parser) jsonSpans(line []byte) []mixedSpan {
+	spans, stack := p.spans[:0], p.spanStack[:0]
+	inString := false
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		if inString {
+			switch c {
+			case '\\':
+				i++
+			case '"':
+				inString = false
+			}
+			continue
+		}
+		switch c {
+		case '{', '[':
+			stack = append(stack, i)
+		case '}', ']':
+			if len(stack) == 0 {
+				continue
+			}
+			// '[' and '{' are two below their closing brackets.
+			open := stack[len(stack)-1]
+			if line[open]+2 != c {
+				stack = stack[:0]
+				continue
+			}
+			stack = stack[:len(stack)-1]
+			spans = append(spans, mixedSpan{open, i + 1})
+		case '"':
+			inString = len(stack) > 0
+		}
+	}
+	// Spans are found in order of their end; inner ones come first.
+	slices.SortFunc(spans, func(a, b mixedSpan) int { return cmp.Compare(a.start, b.start) })
+	p.spans, p.spanStack = spans, stack
+	return spans
+}
+
+func (p *parser) writeMixedText(text []byte) error {
+	if len(text) == 0 {
+		return nil
+	}
+	if err := p.formatter.ensureLineStart(0); err != nil {
+		return err
+	}
+	return p.formatter.writeStyledBytes(p.formatter.pal.Punctuation, text)
+}
+
+// validValueLen returns the length of the JSON value at the start of src, or
+// 0 when src does not start with one.
+func (p *parser) validValueLen(src []byte) int {
+	v := acquireParser()
+	defer releaseParser(v)
+	v.sliceReader.Reset(src)
+	v.scanner.Reset(&v.sliceReader)
+	v.formatter = &v.fmt
+	v.fmt.reset(io.Discard, ColorPalette{}, nil, true)
+	v.silentErr = true
+	if err := v.parseValue(0); err != nil {
+		return 0
+	}
+	return int(v.scanner.base) + v.scanner.pos
+}
+
+// formatEmbedded formats a validated value from a mixed line with p's
+// options, as if it were a document of its own.
+func (p *parser) formatEmbedded(src []byte) error {
+	v := acquireParser()
+	defer releaseParser(v)
+	v.sliceReader.Reset(src)
+	v.scanner.Reset(&v.sliceReader)
+	v.formatter = p.formatter
+	v.unwrapDepth = p.unwrapDepth
+	v.sortKeys = p.sortKeys
+	v.canonical = p.canonical
+	v.log = p.log
+	v.logKeys = p.logKeys
+	v.doc = p.doc
+	v.inheritPath(p)
+	return v.parseValue(0)
+}
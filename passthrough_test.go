@@ -0,0 +1,107 @@
+package prettyx
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPassthrough_MixedLines(t *testing.T) {
+	input := "2026-10-16T10:00:00Z stdout F {\"level\":\"info\",\"n\":[1]}\n" +
+		"plain {not json} [1,2\n" +
+		"\n" +
+		"[] mid {\"b\":1} end"
+	opts := *DefaultOptions
+	opts.Palette = "none"
+	opts.Passthrough = true
+	opts.Prefix = "> "
+	var buf bytes.Buffer
+	if err := PrettyStream(&buf, strings.NewReader(input), &opts); err != nil {
+		t.Fatalf("PrettyStream failed: %v", err)
+	}
+	want := "> 2026-10-16T10:00:00Z stdout F {\n>   \"level\": \"info\",\n>   \"n\": [\n>     1\n>   ]\n> }\n" +
+		"> plain {not json} [1,2\n" +
+		"\n" +
+		"> [] mid {\n>   \"b\": 1\n> } end\n"
+	if buf.String() != want {
+		t.Fatalf("unexpected output\nexpected:\n%s\nactual:\n%s", want, buf.String())
+	}
+
+	opts.Prefix = ""
+	out, err := CompactToBuffer(strings.NewReader(input), &opts)
+	if err != nil {
+		t.Fatalf("CompactToBuffer failed: %v", err)
+	}
+	want = "2026-10-16T10:00:00Z stdout F {\"level\":\"info\",\"n\":[1]}\nplain {not json} [1,2\n\n[] mid {\"b\":1} end\n"
+	if string(out) != want {
+		t.Fatalf("unexpected compact output\nexpected:\n%s\nactual:\n%s", want, out)
+	}
+}
+
+func TestPassthrough_HostileLines(t *testing.T) {
+	opts := *DefaultOptions
+	opts.Palette = "none"
+	opts.Passthrough = true
+	opts.Indent = ""
+	const deep = 50000
+	cases := []struct{ input, want string }{
+		{strings.Repeat("[", deep) + ` {"a":1}`, strings.Repeat("[", deep) + " {\n\"a\": 1\n}"},
+		{strings.Repeat("[", deep) + "x" + strings.Repeat("]", deep), strings.Repeat("[", deep) + "x" + strings.Repeat("]", deep)},
+		{`[x} {"c":"]"}`, "[x} {\n\"c\": \"]\"\n}"},
+	}
+	for _, tc := range cases {
+		start := time.Now()
+		var buf bytes.Buffer
+		if err := PrettyStream(&buf, strings.NewReader(tc.input), &opts); err != nil {
+			t.Fatalf("PrettyStream failed: %v", err)
+		}
+		if buf.String() != tc.want+"\n" {
+			t.Fatalf("unexpected output for %.40q\nexpected: %.80q\nactual:   %.80q", tc.input, tc.want, buf.String())
+		}
+		if d := time.Since(start); d > 5*time.Second {
+			t.Fatalf("line of %d bytes took %v", len(tc.input), d)
+		}
+	}
+}
+
+func TestPassthrough_StylesTextAndLongLines(t *testing.T) {
+	opts := *DefaultOptions
+	opts.ForceColor = true
+	opts.Passthrough = true
+	pal, err := resolvePalette(&opts, true)
+	if err != nil {
+		t.Fatalf("resolvePalette failed: %v", err)
+	}
+	got, err := Pretty([]byte("ts {}\n"), &opts)
+	if err != nil {
+		t.Fatalf("Pretty failed: %v", err)
+	}
+	if !bytes.HasPrefix(got, []byte(pal.Punctuation+"ts ")) {
+		t.Fatalf("expected styled text, got %q", got)
+	}
+
+	long := "x " + strings.Repeat("y", 10000) + ` {"a":"` + strings.Repeat("z", 10000) + `"}`
+	opts.Palette = "none"
+	opts.Log = LogConsole
+	out, err := CompactToBuffer(strings.NewReader(long), &opts)
+	if err != nil {
+		t.Fatalf("CompactToBuffer failed: %v", err)
+	}
+	if string(out) != "x "+strings.Repeat("y", 10000)+" a="+strings.Repeat("z", 10000)+"\n" {
+		t.Fatalf("long line not preserved (%d bytes)", len(out))
+	}
+}
+
+func TestPassthrough_Errors(t *testing.T) {
+	opts := *DefaultOptions
+	opts.Passthrough = true
+	if err := PrettyStream(errWriter{}, strings.NewReader("text {\"a\":1}\n"), &opts); err == nil {
+		t.Fatalf("expected writer error")
+	}
+	r := &errAfterReader{data: []byte("text\n")}
+	if err := PrettyStream(io.Discard, r, &opts); err == nil || err.Error() != "read err" {
+		t.Fatalf("expected reader error, got %v", err)
+	}
+}
@@ -0,0 +1,584 @@
+package prettyx
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// PatchError reports a JSON Patch operation that could not be applied.
+type PatchError struct {
+	// Index is the position of the operation in the patch, counting from 0.
+	Index int
+	// Op and Path are the operation's "op" and "path" members.
+	Op   string
+	Path string
+	Msg  string
+}
+
+func (e *PatchError) Error() string {
+	return fmt.Sprintf("patch operation %d (%s %q): %s", e.Index, e.Op, e.Path, e.Msg)
+}
+
+// JSONPatch reads one document from each of a and b, as Diff does, and
+// returns an RFC 6902 JSON Patch that turns a into b, as compact JSON on one
+// line. Arrays are aligned as Diff aligns them, so an inserted element is a
+// single "add" operation; members whose only change is their order produce
+// no operations because a patch cannot express key order.
+func JSONPatch(a, b io.Reader, opts *Options) ([]byte, error) {
+	oldDoc, newDoc, err := readPatchDocuments(a, b, opts)
+	if err != nil {
+		return nil, err
+	}
+	pb := &patchBuilder{d: &differ{opts: &DiffOptions{IgnoreKeyOrder: true}}}
+	pb.out = append(pb.out, '[')
+	pb.compare("", oldDoc, newDoc)
+	return append(pb.out, ']', '\n'), nil
+}
+
+// JSONPatchTo writes the patch JSONPatch returns to w, formatted like
+// PrettyStream formats it with opts.
+func JSONPatchTo(w io.Writer, a, b io.Reader, opts *Options) error {
+	patch, err := JSONPatch(a, b, opts)
+	if err != nil {
+		return err
+	}
+	return writePatchResult(w, patch, opts)
+}
+
+// MergePatch reads one document from each of a and b, as Diff does, and
+// returns an RFC 7396 JSON Merge Patch that turns a into b, as compact JSON
+// on one line. A merge patch uses null to delete members, so it cannot set
+// a member to null; that is reported as an error naming the member's JSON
+// Pointer.
+func MergePatch(a, b io.Reader, opts *Options) ([]byte, error) {
+	oldDoc, newDoc, err := readPatchDocuments(a, b, opts)
+	if err != nil {
+		return nil, err
+	}
+	d := &differ{opts: &DiffOptions{IgnoreKeyOrder: true}}
+	patch, err := mergeDiff(d, "", oldDoc, newDoc)
+	if err != nil {
+		return nil, err
+	}
+	if patch == nil {
+		// {} leaves an object alone but replaces anything else with {}, so
+		// an unchanged array or scalar is patched with itself.
+		patch = &inputNode{kind: inputObject}
+		if oldDoc.kind != inputObject {
+			patch = newDoc
+		}
+	}
+	return append(appendInputJSON(nil, patch), '\n'), nil
+}
+
+// MergePatchTo writes the merge patch MergePatch returns to w, formatted
+// like PrettyStream formats it with opts.
+func MergePatchTo(w io.Writer, a, b io.Reader, opts *Options) error {
+	patch, err := MergePatch(a, b, opts)
+	if err != nil {
+		return err
+	}
+	return writePatchResult(w, patch, opts)
+}
+
+// ApplyJSONPatch applies the RFC 6902 JSON Patch read from patch to every
+// document read from doc and returns the results as compact JSON, one
+// document per line. The documents are parsed with opts, so with Unwrap the
+// patch can address values inside strings that hold JSON; the patch itself
+// is read with opts.Dialect only. An operation that fails is reported as a
+// *PatchError.
+func ApplyJSONPatch(doc, patch io.Reader, opts *Options) ([]byte, error) {
+	if opts == nil {
+		opts = DefaultOptions
+	}
+	ops, err := readOneDocument(patch, &Options{Dialect: opts.Dialect}, "patch: patch input")
+	if err != nil {
+		return nil, err
+	}
+	if ops.kind != inputArray {
+		return nil, errors.New("patch: a JSON Patch must be an array of operations")
+	}
+	d := &differ{opts: &DiffOptions{IgnoreKeyOrder: true}}
+	var out []byte
+	err = compactDocuments(doc, opts, func(line []byte) error {
+		node, err := decodeJSONLine(line)
+		if err != nil {
+			return err
+		}
+		if node, err = applyJSONPatch(d, node, ops); err != nil {
+			return err
+		}
+		out = append(appendInputJSON(out, node), '\n')
+		return nil
+	})
+	return out, err
+}
+
+// ApplyJSONPatchTo writes the documents ApplyJSONPatch returns to w,
+// formatted like PrettyStream formats them with opts.
+func ApplyJSONPatchTo(w io.Writer, doc, patch io.Reader, opts *Options) error {
+	out, err := ApplyJSONPatch(doc, patch, opts)
+	if err != nil {
+		return err
+	}
+	return writePatchResult(w, out, opts)
+}
+
+// ApplyMergePatch applies the RFC 7396 JSON Merge Patch read from patch to
+// every document read from doc, which is parsed as for ApplyJSONPatch, and
+// returns the results as compact JSON, one document per line.
+func ApplyMergePatch(doc, patch io.Reader, opts *Options) ([]byte, error) {
+	if opts == nil {
+		opts = DefaultOptions
+	}
+	mp, err := readOneDocument(patch, &Options{Dialect: opts.Dialect}, "patch: merge patch input")
+	if err != nil {
+		return nil, err
+	}
+	var out []byte
+	err = compactDocuments(doc, opts, func(line []byte) error {
+		node, err := decodeJSONLine(line)
+		if err != nil {
+			return err
+		}
+		out = append(appendInputJSON(out, applyMergePatch(node, mp)), '\n')
+		return nil
+	})
+	return out, err
+}
+
+// ApplyMergePatchTo writes the documents ApplyMergePatch returns to w,
+// formatted like PrettyStream formats them with opts.
+func ApplyMergePatchTo(w io.Writer, doc, patch io.Reader, opts *Options) error {
+	out, err := ApplyMergePatch(doc, patch, opts)
+	if err != nil {
+		return err
+	}
+	return writePatchResult(w, out, opts)
+}
+
+func readPatchDocuments(a, b io.Reader, opts *Options) (*inputNode, *inputNode, error) {
+	if opts == nil {
+		opts = DefaultOptions
+	}
+	oldDoc, err := readOneDocument(a, opts, "patch: old input")
+	if err != nil {
+		return nil, nil, err
+	}
+	newDoc, err := readOneDocument(b, opts, "patch: new input")
+	return oldDoc, newDoc, err
+}
+
+// writePatchResult formats compact JSON with opts. The options that decide
+// how input is read have been applied already and are cleared.
+func writePatchResult(w io.Writer, data []byte, opts *Options) error {
+	if opts == nil {
+		opts = DefaultOptions
+	}
+	o := *opts
+	o.Unwrap = false
+	o.Path = nil
+	o.Recover = nil
+	o.Repair = false
+	o.OnRepair = nil
+	o.Dialect = DialectJSON
+	o.Passthrough = false
+	return PrettyStream(w, bytes.NewReader(data), &o)
+}
+
+// pointerMember returns path followed by key as an RFC 6901 token.
+func pointerMember(path, key string) string {
+	key = strings.ReplaceAll(key, "~", "~0")
+	return path + "/" + strings.ReplaceAll(key, "/", "~1")
+}
+
+// patchBuilder collects the operations of a JSON Patch in out.
+type patchBuilder struct {
+	d   *differ
+	out []byte
+	n   int
+}
+
+func (pb *patchBuilder) op(name, path string, value *inputNode) {
+	if pb.n > 0 {
+		pb.out = append(pb.out, ',')
+	}
+	pb.n++
+	pb.out = append(pb.out, `{"op":"`...)
+	pb.out = append(pb.out, name...)
+	pb.out = append(pb.out, `","path":`...)
+	pb.out = appendJSONString(pb.out, path)
+	if value != nil {
+		pb.out = append(pb.out, `,"value":`...)
+		pb.out = appendInputJSON(pb.out, value)
+	}
+	pb.out = append(pb.out, '}')
+}
+
+func (pb *patchBuilder) compare(path string, a, b *inputNode) {
+	switch {
+	case a.kind != b.kind:
+		pb.op("replace", path, b)
+	case a.kind == inputObject:
+		oldIndex, newIndex := memberIndex(a), memberIndex(b)
+		for i, key := range a.keys {
+			if oldIndex[key] != i {
+				continue
+			}
+			if j, ok := newIndex[key]; ok {
+				pb.compare(pointerMember(path, key), a.items[i], b.items[j])
+			} else {
+				pb.op("remove", pointerMember(path, key), nil)
+			}
+		}
+		for j, key := range b.keys {
+			if _, ok := oldIndex[key]; !ok && newIndex[key] == j {
+				pb.op("add", pointerMember(path, key), b.items[j])
+			}
+		}
+	case a.kind == inputArray:
+		pb.compareArrays(path, a, b)
+	case !pb.d.equal(a, b):
+		pb.op("replace", path, b)
+	}
+}
+
+// compareArrays walks the alignment of a and b keeping pos, the index in
+// the array as the operations so far have left it: the new elements before
+// pos followed by the old elements not yet visited.
+func (pb *patchBuilder) compareArrays(path string, a, b *inputNode) {
+	oldKeys := make([]string, len(a.items))
+	for i, item := range a.items {
+		oldKeys[i] = pb.d.key(item)
+	}
+	newKeys := make([]string, len(b.items))
+	for i, item := range b.items {
+		newKeys[i] = pb.d.key(item)
+	}
+	pairs := append(alignSequences(oldKeys, newKeys), [2]int{len(oldKeys), len(newKeys)})
+	pos, x, y := 0, 0, 0
+	for _, p := range pairs {
+		olds, news := p[0]-x, p[1]-y
+		n := min(olds, news)
+		for i := 0; i < n; i++ {
+			pb.compare(path+"/"+strconv.Itoa(pos), a.items[x+i], b.items[y+i])
+			pos++
+		}
+		for i := n; i < olds; i++ {
+			pb.op("remove", path+"/"+strconv.Itoa(pos), nil)
+		}
+		for j := n; j < news; j++ {
+			pb.op("add", path+"/"+strconv.Itoa(pos), b.items[y+j])
+			pos++
+		}
+		pos++
+		x, y = p[0]+1, p[1]+1
+	}
+}
+
+// mergeDiff returns the merge patch that turns a into b, or nil when they
+// are equal.
+func mergeDiff(d *differ, path string, a, b *inputNode) (*inputNode, error) {
+	if a.kind != inputObject || b.kind != inputObject {
+		if d.equal(a, b) {
+			return nil, nil
+		}
+		if path != "" && b.kind == inputNull {
+			return nil, fmt.Errorf("merge patch: cannot set %q to null", path)
+		}
+		return b, checkMergeNulls(path, b)
+	}
+	patch := &inputNode{kind: inputObject}
+	oldIndex, newIndex := memberIndex(a), memberIndex(b)
+	for i, key := range a.keys {
+		if _, ok := newIndex[key]; !ok && oldIndex[key] == i {
+			patch.set(key, &inputNode{})
+		}
+	}
+	for j, key := range b.keys {
+		if newIndex[key] != j {
+			continue
+		}
+		member := pointerMember(path, key)
+		var sub *inputNode
+		var err error
+		switch i, ok := oldIndex[key]; {
+		case ok:
+			sub, err = mergeDiff(d, member, a.items[i], b.items[j])
+		case b.items[j].kind == inputNull:
+			err = fmt.Errorf("merge patch: cannot set %q to null", member)
+		default:
+			sub, err = b.items[j], checkMergeNulls(member, b.items[j])
+		}
+		if err != nil {
+			return nil, err
+		}
+		if sub != nil {
+			patch.set(key, sub)
+		}
+	}
+	if len(patch.keys) == 0 {
+		return nil, nil
+	}
+	return patch, nil
+}
+
+// checkMergeNulls rejects null members inside an object that a merge patch
+// would set, because applying the patch would drop them.
+func checkMergeNulls(path string, n *inputNode) error {
+	if n.kind != inputObject {
+		return nil
+	}
+	for i, key := range n.keys {
+		member := pointerMember(path, key)
+		if n.items[i].kind == inputNull {
+			return fmt.Errorf("merge patch: cannot set %q to null", member)
+		}
+		if err := checkMergeNulls(member, n.items[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func applyMergePatch(target, patch *inputNode) *inputNode {
+	if patch.kind != inputObject {
+		return cloneNode(patch)
+	}
+	if target == nil || target.kind != inputObject {
+		target = &inputNode{kind: inputObject}
+	}
+	for i, key := range patch.keys {
+		j := slices.Index(target.keys, key)
+		if patch.items[i].kind == inputNull {
+			if j >= 0 {
+				target.keys = slices.Delete(target.keys, j, j+1)
+				target.items = slices.Delete(target.items, j, j+1)
+			}
+			continue
+		}
+		if j >= 0 {
+			target.items[j] = applyMergePatch(target.items[j], patch.items[i])
+		} else {
+			target.set(key, applyMergePatch(nil, patch.items[i]))
+		}
+	}
+	return target
+}
+
+func cloneNode(n *inputNode) *inputNode {
+	c := &inputNode{kind: n.kind, text: n.text, keys: slices.Clone(n.keys)}
+	if n.items != nil {
+		c.items = make([]*inputNode, len(n.items))
+		for i, item := range n.items {
+			c.items[i] = cloneNode(item)
+		}
+	}
+	return c
+}
+
+func applyJSONPatch(d *differ, doc, ops *inputNode) (*inputNode, error) {
+	for i, op := range ops.items {
+		pe := &PatchError{Index: i}
+		if op.kind != inputObject {
+			pe.Msg = "operation is not an object"
+			return nil, pe
+		}
+		var err error
+		if pe.Op, err = stringMember(op, "op"); err != nil {
+			pe.Msg = err.Error()
+			return nil, pe
+		}
+		if pe.Path, err = stringMember(op, "path"); err != nil {
+			pe.Msg = err.Error()
+			return nil, pe
+		}
+		if doc, err = applyPatchOp(d, doc, op, pe); err != nil {
+			pe.Msg = err.Error()
+			return nil, pe
+		}
+	}
+	return doc, nil
+}
+
+func stringMember(op *inputNode, name string) (string, error) {
+	v := op.member(name)
+	if v == nil {
+		return "", errors.New(`missing "` + name + `"`)
+	}
+	if v.kind != inputString {
+		return "", errors.New(`"` + name + `" is not a string`)
+	}
+	return v.text, nil
+}
+
+func applyPatchOp(d *differ, doc, op *inputNode, pe *PatchError) (*inputNode, error) {
+	segs, err := parsePatchPointer(pe.Path)
+	if err != nil {
+		return nil, err
+	}
+	value := op.member("value")
+	needsValue := pe.Op == "add" || pe.Op == "replace" || pe.Op == "test"
+	if needsValue && value == nil {
+		return nil, errors.New(`missing "value"`)
+	}
+	var fromSegs []pathSegment
+	if pe.Op == "move" || pe.Op == "copy" {
+		from, err := stringMember(op, "from")
+		if err != nil {
+			return nil, err
+		}
+		if fromSegs, err = parsePatchPointer(from); err != nil {
+			return nil, err
+		}
+		if pe.Op == "move" && strings.HasPrefix(pe.Path, from+"/") {
+			return nil, errors.New("cannot move a value into itself")
+		}
+	}
+	switch pe.Op {
+	case "add":
+		return patchAdd(doc, segs, cloneNode(value))
+	case "remove":
+		doc, _, err := patchRemove(doc, segs)
+		return doc, err
+	case "replace":
+		if len(segs) == 0 {
+			return cloneNode(value), nil
+		}
+		parent, err := patchGet(doc, segs[:len(segs)-1])
+		if err != nil {
+			return nil, err
+		}
+		last := segs[len(segs)-1]
+		switch {
+		case parent.kind == inputObject && slices.Contains(parent.keys, last.key):
+			parent.items[slices.Index(parent.keys, last.key)] = cloneNode(value)
+		case parent.kind == inputArray && last.index >= 0 && last.index < len(parent.items):
+			parent.items[last.index] = cloneNode(value)
+		default:
+			return nil, errPatchNotFound
+		}
+		return doc, nil
+	case "move":
+		doc, moved, err := patchRemove(doc, fromSegs)
+		if err != nil {
+			return nil, fmt.Errorf("from: %w", err)
+		}
+		return patchAdd(doc, segs, moved)
+	case "copy":
+		v, err := patchGet(doc, fromSegs)
+		if err != nil {
+			return nil, fmt.Errorf("from: %w", err)
+		}
+		return patchAdd(doc, segs, cloneNode(v))
+	case "test":
+		v, err := patchGet(doc, segs)
+		if err != nil {
+			return nil, err
+		}
+		if !d.equal(v, value) {
+			return nil, errors.New("test failed")
+		}
+		return doc, nil
+	default:
+		return nil, errors.New("unknown operation")
+	}
+}
+
+func parsePatchPointer(path string) ([]pathSegment, error) {
+	if path != "" && path[0] != '/' {
+		return nil, errors.New("path is not a JSON Pointer")
+	}
+	return parsePointer(path)
+}
+
+var errPatchNotFound = errors.New("path not found")
+
+// patchGet returns the value segs point at.
+func patchGet(doc *inputNode, segs []pathSegment) (*inputNode, error) {
+	for _, seg := range segs {
+		switch doc.kind {
+		case inputObject:
+			doc = doc.member(seg.key)
+		case inputArray:
+			if seg.index < 0 || seg.index >= len(doc.items) {
+				return nil, errPatchNotFound
+			}
+			doc = doc.items[seg.index]
+		default:
+			doc = nil
+		}
+		if doc == nil {
+			return nil, errPatchNotFound
+		}
+	}
+	return doc, nil
+}
+
+func patchAdd(doc *inputNode, segs []pathSegment, v *inputNode) (*inputNode, error) {
+	if len(segs) == 0 {
+		return v, nil
+	}
+	parent, err := patchGet(doc, segs[:len(segs)-1])
+	if err != nil {
+		return nil, err
+	}
+	last := segs[len(segs)-1]
+	switch parent.kind {
+	case inputObject:
+		if j := slices.Index(parent.keys, last.key); j >= 0 {
+			parent.items[j] = v
+		} else {
+			parent.set(last.key, v)
+		}
+	case inputArray:
+		i := last.index
+		if last.key == "-" {
+			i = len(parent.items)
+		}
+		if i < 0 || i > len(parent.items) {
+			return nil, errors.New("array index out of range")
+		}
+		parent.items = slices.Insert(parent.items, i, v)
+	default:
+		return nil, errPatchNotFound
+	}
+	return doc, nil
+}
+
+// patchRemove removes the value segs point at and returns it.
+func patchRemove(doc *inputNode, segs []pathSegment) (*inputNode, *inputNode, error) {
+	if len(segs) == 0 {
+		return nil, nil, errors.New("cannot remove the whole document")
+	}
+	parent, err := patchGet(doc, segs[:len(segs)-1])
+	if err != nil {
+		return nil, nil, err
+	}
+	last := segs[len(segs)-1]
+	var removed *inputNode
+	switch parent.kind {
+	case inputObject:
+		j := slices.Index(parent.keys, last.key)
+		if j < 0 {
+			return nil, nil, errPatchNotFound
+		}
+		removed = parent.items[j]
+		parent.keys = slices.Delete(parent.keys, j, j+1)
+		parent.items = slices.Delete(parent.items, j, j+1)
+	case inputArray:
+		if last.index < 0 || last.index >= len(parent.items) {
+			return nil, nil, errPatchNotFound
+		}
+		removed = parent.items[last.index]
+		parent.items = slices.Delete(parent.items, last.index, last.index+1)
+	default:
+		return nil, nil, errPatchNotFound
+	}
+	return doc, removed, nil
+}
@@ -0,0 +1,208 @@
+package prettyx
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestJSONPatchGenerate(t *testing.T) {
+	a := `{"name":"web","tags":["a","b","c"],"gone":1,"m/x":{"~k":1}}`
+	b := `{"name":"api","tags":["x","a","c","d"],"m/x":{"~k":2},"new":null}`
+	got, err := JSONPatch(strings.NewReader(a), strings.NewReader(b), nil)
+	if err != nil {
+		t.Fatalf("JSONPatch failed: %v", err)
+	}
+	want := `[{"op":"replace","path":"/name","value":"api"},` +
+		`{"op":"add","path":"/tags/0","value":"x"},` +
+		`{"op":"remove","path":"/tags/2"},` +
+		`{"op":"add","path":"/tags/3","value":"d"},` +
+		`{"op":"remove","path":"/gone"},` +
+		`{"op":"replace","path":"/m~1x/~0k","value":2},` +
+		`{"op":"add","path":"/new","value":null}]` + "\n"
+	if string(got) != want {
+		t.Fatalf("unexpected patch\nexpected: %s\nactual:   %s", want, got)
+	}
+}
+
+func TestJSONPatchRoundTrip(t *testing.T) {
+	cases := [][2]string{
+		{`{"a":[1,2,3,4,5]}`, `{"a":[5,4,3,2,1]}`},
+		{`[1,2,3]`, `[]`},
+		{`[]`, `[{"a":1},[2]]`},
+		{`{"a":{"b":[{"c":1},{"c":2}]}}`, `{"a":{"b":[{"c":2},{"c":1,"d":[]}]}}`},
+		{`{"a":1}`, `"scalar"`},
+		{`[1,[2,3],4]`, `[0,1,[3],4,5]`},
+	}
+	for _, tc := range cases {
+		patch, err := JSONPatch(strings.NewReader(tc[0]), strings.NewReader(tc[1]), nil)
+		if err != nil {
+			t.Fatalf("JSONPatch(%s, %s) failed: %v", tc[0], tc[1], err)
+		}
+		got, err := ApplyJSONPatch(strings.NewReader(tc[0]), bytes.NewReader(patch), nil)
+		if err != nil {
+			t.Fatalf("ApplyJSONPatch(%s, %s) failed: %v", tc[0], patch, err)
+		}
+		if string(got) != tc[1]+"\n" {
+			t.Fatalf("patch %s turned %s into %s, want %s", patch, tc[0], got, tc[1])
+		}
+	}
+}
+
+func TestApplyJSONPatch(t *testing.T) {
+	doc := `{"foo":["bar","baz"],"q":{"x":1}}` + "\n" + `{"foo":[],"q":{}}`
+	patch := `[
+		{"op":"add","path":"/foo/-","value":"qux"},
+		{"op":"move","from":"/q","path":"/moved"},
+		{"op":"copy","from":"/foo","path":"/copy"},
+		{"op":"test","path":"/copy/0","value":"nope"},
+		{"op":"remove","path":"/foo/0"},
+		{"op":"replace","path":"/copy","value":1.0}
+	]`
+	_, err := ApplyJSONPatch(strings.NewReader(doc), strings.NewReader(patch), nil)
+	var pe *PatchError
+	if !errors.As(err, &pe) || pe.Index != 3 || pe.Op != "test" || pe.Path != "/copy/0" {
+		t.Fatalf("expected test failure on the first document, got %v", err)
+	}
+	patch = strings.Replace(patch, `"nope"`, `"bar"`, 1)
+	got, err := ApplyJSONPatch(strings.NewReader(doc[:strings.IndexByte(doc, '\n')]), strings.NewReader(patch), nil)
+	if err != nil {
+		t.Fatalf("ApplyJSONPatch failed: %v", err)
+	}
+	if want := `{"foo":["baz","qux"],"moved":{"x":1},"copy":1.0}` + "\n"; string(got) != want {
+		t.Fatalf("unexpected result\nexpected: %s\nactual:   %s", want, got)
+	}
+}
+
+func TestApplyJSONPatchErrors(t *testing.T) {
+	doc := `{"a":[1],"b":{"c":2}}`
+	cases := []struct {
+		patch string
+		index int
+		msg   string
+	}{
+		{`[{"op":"test","path":"/a/0","value":1},{"op":"remove","path":"/x"}]`, 1, `patch operation 1 (remove "/x"): path not found`},
+		{`[{"op":"add","path":"/a/5","value":1}]`, 0, "array index out of range"},
+		{`[{"op":"replace","path":"/a/01","value":1}]`, 0, "path not found"},
+		{`[{"op":"move","from":"/b","path":"/b/c/d"}]`, 0, "cannot move a value into itself"},
+		{`[{"op":"copy","from":"/nope","path":"/z"}]`, 0, "from: path not found"},
+		{`[{"op":"add","path":"a","value":1}]`, 0, "path is not a JSON Pointer"},
+		{`[{"op":"add","path":"/a"}]`, 0, `missing "value"`},
+		{`[{"op":"merge","path":"/a"}]`, 0, "unknown operation"},
+		{`[{"path":"/a"}]`, 0, `missing "op"`},
+		{`[1]`, 0, "operation is not an object"},
+		{`[{"op":"remove","path":""}]`, 0, "cannot remove the whole document"},
+	}
+	for _, tc := range cases {
+		_, err := ApplyJSONPatch(strings.NewReader(doc), strings.NewReader(tc.patch), nil)
+		var pe *PatchError
+		if !errors.As(err, &pe) || pe.Index != tc.index || !strings.Contains(err.Error(), tc.msg) {
+			t.Fatalf("patch %s: expected error at %d containing %q, got %v", tc.patch, tc.index, tc.msg, err)
+		}
+	}
+	if _, err := ApplyJSONPatch(strings.NewReader(doc), strings.NewReader(`{}`), nil); err == nil {
+		t.Fatalf("expected an error for a patch that is not an array")
+	}
+}
+
+func TestMergePatch(t *testing.T) {
+	a := `{"title":"Goodbye!","author":{"givenName":"John","familyName":"Doe"},"tags":["example","sample"],"content":"This will be unchanged"}`
+	b := `{"title":"Hello!","author":{"givenName":"John"},"tags":["example"],"content":"This will be unchanged","phoneNumber":"+01-123-456-7890"}`
+	got, err := MergePatch(strings.NewReader(a), strings.NewReader(b), nil)
+	if err != nil {
+		t.Fatalf("MergePatch failed: %v", err)
+	}
+	want := `{"title":"Hello!","author":{"familyName":null},"tags":["example"],"phoneNumber":"+01-123-456-7890"}` + "\n"
+	if string(got) != want {
+		t.Fatalf("unexpected merge patch\nexpected: %s\nactual:   %s", want, got)
+	}
+	applied, err := ApplyMergePatch(strings.NewReader(a), bytes.NewReader(got), nil)
+	if err != nil {
+		t.Fatalf("ApplyMergePatch failed: %v", err)
+	}
+	if string(applied) != b+"\n" {
+		t.Fatalf("merge patch turned the document into %s", applied)
+	}
+
+	if got, err := MergePatch(strings.NewReader(a), strings.NewReader(a), nil); err != nil || string(got) != "{}\n" {
+		t.Fatalf("expected an empty merge patch, got %s, %v", got, err)
+	}
+	for _, doc := range []string{`[1]`, `2`, `"a"`, `null`} {
+		got, err := MergePatch(strings.NewReader(doc), strings.NewReader(doc), nil)
+		if err != nil || string(got) != doc+"\n" {
+			t.Fatalf("MergePatch %s to itself: got %s, %v", doc, got, err)
+		}
+		applied, err := ApplyMergePatch(strings.NewReader(doc), bytes.NewReader(got), nil)
+		if err != nil || string(applied) != doc+"\n" {
+			t.Fatalf("merge patch turned %s into %s, %v", doc, applied, err)
+		}
+	}
+	for _, b := range []string{`{"title":null}`, `{"x":{"y":null}}`, `{"author":{"givenName":null}}`} {
+		_, err := MergePatch(strings.NewReader(a), strings.NewReader(b), nil)
+		if err == nil || !strings.Contains(err.Error(), "to null") {
+			t.Fatalf("MergePatch to %s: expected a null error, got %v", b, err)
+		}
+	}
+}
+
+func TestApplyMergePatchRFC7396(t *testing.T) {
+	cases := []struct{ target, patch, want string }{
+		{`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{`{"a":"b"}`, `{"a":null}`, `{}`},
+		{`{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+		{`{"a":["b"]}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"c"}`, `{"a":["b"]}`, `{"a":["b"]}`},
+		{`{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+		{`{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+		{`["a","b"]`, `["c","d"]`, `["c","d"]`},
+		{`{"a":"b"}`, `["c"]`, `["c"]`},
+		{`{"a":"foo"}`, `null`, `null`},
+		{`{"a":"foo"}`, `"bar"`, `"bar"`},
+		{`{"e":null}`, `{"a":1}`, `{"e":null,"a":1}`},
+		{`[1,2]`, `{"a":"b","c":null}`, `{"a":"b"}`},
+		{`{}`, `{"a":{"bb":{"ccc":null}}}`, `{"a":{"bb":{}}}`},
+	}
+	for _, tc := range cases {
+		got, err := ApplyMergePatch(strings.NewReader(tc.target), strings.NewReader(tc.patch), nil)
+		if err != nil {
+			t.Fatalf("ApplyMergePatch(%s, %s) failed: %v", tc.target, tc.patch, err)
+		}
+		if string(got) != tc.want+"\n" {
+			t.Fatalf("ApplyMergePatch(%s, %s) = %s, want %s", tc.target, tc.patch, got, tc.want)
+		}
+	}
+}
+
+func TestPatchToFormatsWithOptions(t *testing.T) {
+	opts := *DefaultOptions
+	opts.Palette = "none"
+	opts.Unwrap = true
+	var buf bytes.Buffer
+	err := JSONPatchTo(&buf, strings.NewReader(`{"cfg":"{\"n\":1}"}`), strings.NewReader(`{"cfg":"{\"n\":2}"}`), &opts)
+	if err != nil {
+		t.Fatalf("JSONPatchTo failed: %v", err)
+	}
+	want := `[
+  {
+    "op": "replace",
+    "path": "/cfg/n",
+    "value": 2
+  }
+]
+`
+	if buf.String() != want {
+		t.Fatalf("unexpected output\nexpected:\n%s\nactual:\n%s", want, buf.String())
+	}
+
+	buf.Reset()
+	opts.Output = OutputYAML
+	err = ApplyMergePatchTo(&buf, strings.NewReader(`{"cfg":"{\"n\":1}"}`), strings.NewReader(`{"cfg":{"n":null,"m":"{\"x\":1}"}}`), &opts)
+	if err != nil {
+		t.Fatalf("ApplyMergePatchTo failed: %v", err)
+	}
+	if want := "cfg:\n  m: \"{\\\"x\\\":1}\"\n"; buf.String() != want {
+		t.Fatalf("unexpected output\nexpected:\n%q\nactual:\n%q", want, buf.String())
+	}
+}
@@ -0,0 +1,414 @@
+package prettyx
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Path selects values inside each document. Create one with ParsePath and
+// share it between calls; it is not modified after parsing.
+type Path struct {
+	src  string
+	segs []pathSegment
+}
+
+// pathSegment matches object members named key when hasKey is set, array
+// elements at index when index >= 0, and every member and element when
+// wildcard is set. A JSON Pointer token such as "0" sets both key and index
+// because the pointer does not say which kind of container it expects.
+type pathSegment struct {
+	key      string
+	hasKey   bool
+	index    int
+	wildcard bool
+}
+
+// ParsePath compiles an RFC 6901 JSON Pointer ("/items/3/metadata") or a
+// jq-style path (".items[3].metadata"). The jq-style syntax accepts .name,
+// ."quoted name", ["quoted name"], [N] for an array index and [] for every
+// element of an array or every member value of an object; the leading dot may
+// be omitted. The empty pointer and "." select the whole document.
+func ParsePath(s string) (*Path, error) {
+	var (
+		segs []pathSegment
+		err  error
+	)
+	if s == "" || s[0] == '/' {
+		segs, err = parsePointer(s)
+	} else {
+		segs, err = parseDotted(s)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid path %q: %w", s, err)
+	}
+	return &Path{src: s, segs: segs}, nil
+}
+
+// String returns the path as it was given to ParsePath.
+func (p *Path) String() string {
+	if p == nil {
+		return ""
+	}
+	return p.src
+}
+
+func parsePointer(s string) ([]pathSegment, error) {
+	var segs []pathSegment
+	for s != "" {
+		s = s[1:]
+		end := strings.IndexByte(s, '/')
+		if end < 0 {
+			end = len(s)
+		}
+		tok := s[:end]
+		s = s[end:]
+		if strings.Contains(tok, "~") {
+			var b strings.Builder
+			for i := 0; i < len(tok); i++ {
+				if tok[i] != '~' {
+					b.WriteByte(tok[i])
+					continue
+				}
+				if i+1 == len(tok) || (tok[i+1] != '0' && tok[i+1] != '1') {
+					return nil, fmt.Errorf("invalid escape in %q", tok)
+				}
+				if tok[i+1] == '0' {
+					b.WriteByte('~')
+				} else {
+					b.WriteByte('/')
+				}
+				i++
+			}
+			tok = b.String()
+		}
+		segs = append(segs, pathSegment{key: tok, hasKey: true, index: pointerIndex(tok)})
+	}
+	return segs, nil
+}
+
+// pointerIndex returns the array index a pointer token names, or -1. RFC 6901
+// forbids leading zeros.
+func pointerIndex(tok string) int {
+	if tok == "" || (len(tok) > 1 && tok[0] == '0') {
+		return -1
+	}
+	for i := 0; i < len(tok); i++ {
+		if tok[i] < '0' || tok[i] > '9' {
+			return -1
+		}
+	}
+	n, err := strconv.Atoi(tok)
+	if err != nil {
+		return -1
+	}
+	return n
+}
+
+func parseDotted(s string) ([]pathSegment, error) {
+	var segs []pathSegment
+	if s == "." {
+		return nil, nil
+	}
+	if s[0] != '.' && s[0] != '[' {
+		s = "." + s
+	}
+	for s != "" {
+		switch s[0] {
+		case '.':
+			s = s[1:]
+			switch {
+			case s == "" || s[0] == '.':
+				return nil, fmt.Errorf("empty name")
+			case s[0] == '[':
+				continue
+			case s[0] == '"':
+				key, rest, err := readPathString(s)
+				if err != nil {
+					return nil, err
+				}
+				segs = append(segs, pathSegment{key: key, hasKey: true, index: -1})
+				s = rest
+			default:
+				end := strings.IndexAny(s, ".[")
+				if end < 0 {
+					end = len(s)
+				}
+				segs = append(segs, pathSegment{key: s[:end], hasKey: true, index: -1})
+				s = s[end:]
+			}
+		case '[':
+			end := strings.IndexByte(s, ']')
+			if len(s) > 1 && s[1] == '"' {
+				key, rest, err := readPathString(s[1:])
+				if err != nil {
+					return nil, err
+				}
+				if rest == "" || rest[0] != ']' {
+					return nil, fmt.Errorf("missing ']'")
+				}
+				segs = append(segs, pathSegment{key: key, hasKey: true, index: -1})
+				s = rest[1:]
+				continue
+			}
+			if end < 0 {
+				return nil, fmt.Errorf("missing ']'")
+			}
+			inner := strings.TrimSpace(s[1:end])
+			s = s[end+1:]
+			if inner == "" {
+				segs = append(segs, pathSegment{index: -1, wildcard: true})
+				continue
+			}
+			n, err := strconv.Atoi(inner)
+			if err != nil || n < 0 {
+				return nil, fmt.Errorf("invalid index %q", inner)
+			}
+			segs = append(segs, pathSegment{index: n})
+		default:
+			return nil, fmt.Errorf("unexpected %q", s[0])
+		}
+	}
+	return segs, nil
+}
+
+// readPathString reads the JSON string at the start of s and returns its
+// value and the rest of s.
+func readPathString(s string) (string, string, error) {
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			key, err := strconv.Unquote(s[:i+1])
+			if err != nil {
+				return "", "", fmt.Errorf("invalid string %s", s[:i+1])
+			}
+			return key, s[i+1:], nil
+		}
+	}
+	return "", "", fmt.Errorf("unterminated string")
+}
+
+// selectDocument writes each value of the current document matched by the
+// path as a document of its own. Everything else is checked for syntax and
+// skipped without being formatted.
+func (p *parser) selectDocument() error {
+	b, err := p.readNonSpace()
+	if err != nil {
+		return err
+	}
+	return p.selectValue(b, p.path)
+}
+
+func (p *parser) selectValue(first byte, segs []pathSegment) error {
+	if len(segs) == 0 {
+		return p.writeDocument(first)
+	}
+	switch first {
+	case '{':
+		return p.selectMembers(segs)
+	case '[':
+		return p.selectElements(segs)
+	case '"':
+		if p.unwrapDepth > 0 {
+			return p.selectUnwrapped(segs)
+		}
+	}
+	return p.skipValue(first)
+}
+
+func (p *parser) selectMembers(segs []pathSegment) error {
+	seg := &segs[0]
+	b, err := p.readNonSpace()
+	if err != nil {
+		if p.repairEOF(err, RepairUnclosedObject) {
+			return nil
+		}
+		return err
+	}
+	if b == '}' {
+		return nil
+	}
+	for {
+		key, err := p.readMemberKey(b)
+		if err != nil {
+			return err
+		}
+		match := seg.wildcard || (seg.hasKey && string(key) == seg.key)
+		if p.trackPath {
+			p.enterMember(key)
+		}
+		if err := p.expectColon(); err != nil && !(p.repair && err == io.EOF) {
+			return err
+		}
+		if b, err = p.readNonSpace(); err != nil {
+			if p.repairEOF(err, RepairMissingValue) {
+				return nil
+			}
+			return err
+		}
+		if match {
+			err = p.selectValue(b, segs[1:])
+		} else {
+			err = p.skipValue(b)
+		}
+		if err != nil {
+			return err
+		}
+		if p.trackPath {
+			p.leave()
+		}
+		var closed bool
+		if b, closed, err = p.nextMember('}'); err != nil || closed {
+			return err
+		}
+	}
+}
+
+func (p *parser) selectElements(segs []pathSegment) error {
+	seg := &segs[0]
+	b, err := p.readNonSpace()
+	if err != nil {
+		if p.repairEOF(err, RepairUnclosedArray) {
+			return nil
+		}
+		return err
+	}
+	if b == ']' {
+		return nil
+	}
+	for i := 0; ; i++ {
+		if p.trackPath {
+			p.enterElement(i)
+		}
+		if seg.wildcard || seg.index == i {
+			err = p.selectValue(b, segs[1:])
+		} else {
+			err = p.skipValue(b)
+		}
+		if err != nil {
+			return err
+		}
+		if p.trackPath {
+			p.leave()
+		}
+		var closed bool
+		if b, closed, err = p.nextMember(']'); err != nil || closed {
+			return err
+		}
+	}
+}
+
+// readMemberKey reads the object key starting with first and returns it
+// decoded.
+func (p *parser) readMemberKey(first byte) ([]byte, error) {
+	if p.extendedKeys() {
+		return p.readRepairKey(first)
+	}
+	if first != '"' {
+		return nil, p.errorf("expected object key")
+	}
+	return p.readQuoted('"')
+}
+
+// nextMember reads the separator after a member or element that is being
+// selected or skipped. It reports whether the container closed, and
+// otherwise returns the first byte of the next member or element.
+func (p *parser) nextMember(closing byte) (byte, bool, error) {
+	kind := RepairUnclosedArray
+	if closing == '}' {
+		kind = RepairUnclosedObject
+	}
+	b, err := p.readNonSpace()
+	if err != nil {
+		if p.repairEOF(err, kind) {
+			return 0, true, nil
+		}
+		return 0, false, err
+	}
+	switch b {
+	case ',':
+		b, err = p.readAfterComma(closing)
+		if err == errRepairClose {
+			return 0, true, nil
+		}
+		return b, false, err
+	case closing:
+		return 0, true, nil
+	default:
+		return 0, false, p.errorf("expected ',' or '%c'", closing)
+	}
+}
+
+// selectUnwrapped continues the selection inside a string that holds JSON,
+// as Unwrap would decode it. Strings that do not hold JSON match nothing.
+func (p *parser) selectUnwrapped(segs []pathSegment) error {
+	val, err := p.readStringValue()
+	if err != nil {
+		return err
+	}
+	trimmed, ok := p.embeddedJSON(trimSpaceBytes(val))
+	if !ok {
+		return nil
+	}
+	v := acquireParser()
+	defer releaseParser(v)
+	v.sliceReader.Reset(trimmed)
+	v.scanner.Reset(&v.sliceReader)
+	v.formatter = &v.fmt
+	v.fmt.reset(io.Discard, ColorPalette{}, nil, true)
+	v.silentErr = true
+	if err := v.parseValue(0); err != nil {
+		return nil
+	}
+	if err := v.scanner.skipSpace(); err != io.EOF {
+		return nil
+	}
+
+	v.sliceReader.Reset(trimmed)
+	v.scanner.Reset(&v.sliceReader)
+	v.formatter = p.formatter
+	v.silentErr = false
+	v.unwrapDepth = p.unwrapDepth - 1
+	v.sortKeys = p.sortKeys
+	v.log = p.log
+	v.logKeys = p.logKeys
+	v.doc = p.doc
+	v.inheritPath(p)
+	b, err := v.readNonSpace()
+	if err != nil {
+		return err
+	}
+	return v.selectValue(b, segs)
+}
+
+// skipValue consumes the value starting with first, checking its syntax.
+// Containers and strings are skipped without touching the formatter; other
+// scalars go through the normal parse into a discarding one.
+func (p *parser) skipValue(first byte) error {
+	switch first {
+	case '{':
+		return p.selectMembers(skipAll)
+	case '[':
+		return p.selectElements(skipAll)
+	case '"':
+		_, err := p.readQuoted('"')
+		return err
+	}
+	if p.skipFmt.w == nil {
+		// Pooled parsers used for unwrapping and passthrough are never
+		// reset, so their discarding formatter is set up on first use.
+		p.skipFmt.reset(io.Discard, ColorPalette{}, nil, true)
+	}
+	out := p.formatter
+	p.formatter = &p.skipFmt
+	err := p.parseValueWithFirst(0, first)
+	p.formatter = out
+	return err
+}
+
+// skipAll is a segment that matches nothing, so selecting with it skips
+// every member and element.
+var skipAll = []pathSegment{{index: -1}}
@@ -0,0 +1,187 @@
+package prettyx
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+const pathInput = `{"items":[{"id":1,"metadata":{"name":"a"}},{"id":2,"metadata":{"name":"b"},"tags":[true,null,-1.5e3,"x"]},{"id":3,"metadata":"{\"name\":\"c\"}"}],"a/b":{"~k":7}}
+[10,20,30]
+{"items":[]}`
+
+func TestParsePath(t *testing.T) {
+	cases := []struct {
+		in   string
+		want []pathSegment
+	}{
+		{"", nil},
+		{".", nil},
+		{"/items/3/metadata", []pathSegment{{key: "items", hasKey: true, index: -1}, {key: "3", hasKey: true, index: 3}, {key: "metadata", hasKey: true, index: -1}}},
+		{"/a~1b/~0k/01/", []pathSegment{{key: "a/b", hasKey: true, index: -1}, {key: "~k", hasKey: true, index: -1}, {key: "01", hasKey: true, index: -1}, {key: "", hasKey: true, index: -1}}},
+		{".items[3].metadata", []pathSegment{{key: "items", hasKey: true, index: -1}, {index: 3}, {key: "metadata", hasKey: true, index: -1}}},
+		{`items[]."a.b"["c]"]`, []pathSegment{{key: "items", hasKey: true, index: -1}, {index: -1, wildcard: true}, {key: "a.b", hasKey: true, index: -1}, {key: "c]", hasKey: true, index: -1}}},
+		{".[][0]", []pathSegment{{index: -1, wildcard: true}, {index: 0}}},
+	}
+	for _, tc := range cases {
+		path, err := ParsePath(tc.in)
+		if err != nil {
+			t.Fatalf("ParsePath(%q) failed: %v", tc.in, err)
+		}
+		if path.String() != tc.in {
+			t.Fatalf("ParsePath(%q).String() = %q", tc.in, path.String())
+		}
+		if len(path.segs) != len(tc.want) {
+			t.Fatalf("ParsePath(%q) = %+v, want %+v", tc.in, path.segs, tc.want)
+		}
+		for i := range tc.want {
+			if path.segs[i] != tc.want[i] {
+				t.Fatalf("ParsePath(%q) = %+v, want %+v", tc.in, path.segs, tc.want)
+			}
+		}
+	}
+
+	for _, in := range []string{"/a~2", "/a~", "..a", "a.", "[x]", "[-1]", `["a`, `["a"`, "a[1", `."a\q"`} {
+		if _, err := ParsePath(in); err == nil {
+			t.Fatalf("ParsePath(%q): expected error", in)
+		}
+	}
+}
+
+func TestPath_Select(t *testing.T) {
+	cases := []struct {
+		path   string
+		unwrap bool
+		want   string
+	}{
+		{".items[1].metadata", false, `{"name":"b"}`},
+		{"/items/1/metadata", false, `{"name":"b"}`},
+		{"/items/1/tags/3", false, `"x"`},
+		{"items[].id", false, "1\n2\n3"},
+		{".items[].metadata.name", false, `"a"` + "\n" + `"b"`},
+		{".items[].metadata.name", true, `"a"` + "\n" + `"b"` + "\n" + `"c"`},
+		{".items[2].metadata", true, `{"name":"c"}`},
+		{"/a~1b/~0k", false, "7"},
+		{"/1", false, "20"},
+		{"[]", false, `[{"id":1,"metadata":{"name":"a"}},{"id":2,"metadata":{"name":"b"},"tags":[true,null,-1.5e3,"x"]},{"id":3,"metadata":"{\"name\":\"c\"}"}]` + "\n" + `{"~k":7}` + "\n10\n20\n30\n[]"},
+		{".missing", false, ""},
+	}
+	for _, tc := range cases {
+		path, err := ParsePath(tc.path)
+		if err != nil {
+			t.Fatalf("ParsePath(%q) failed: %v", tc.path, err)
+		}
+		opts := *DefaultOptions
+		opts.Path = path
+		opts.Unwrap = tc.unwrap
+		out, err := CompactToBuffer(strings.NewReader(pathInput), &opts)
+		if err != nil {
+			t.Fatalf("%s: CompactToBuffer failed: %v", tc.path, err)
+		}
+		want := tc.want
+		if want != "" {
+			want += "\n"
+		}
+		if string(out) != want {
+			t.Fatalf("%s (unwrap %v): expected %q, got %q", tc.path, tc.unwrap, want, out)
+		}
+	}
+
+	// Skipping scalars inside an unwrapped string uses a pooled parser.
+	opts := *DefaultOptions
+	opts.Unwrap = true
+	opts.Path, _ = ParsePath(".s.k[3]")
+	out, err := CompactToBuffer(strings.NewReader(`{"n":0,"s":"{\"x\":1.5,\"k\":[1,true,null,2]}"}`), &opts)
+	if err != nil || string(out) != "2\n" {
+		t.Fatalf("unwrapped select: got %q, %v", out, err)
+	}
+}
+
+func TestPath_PrettyAndSorted(t *testing.T) {
+	path, err := ParsePath(".items[1]")
+	if err != nil {
+		t.Fatalf("ParsePath failed: %v", err)
+	}
+	opts := *DefaultOptions
+	opts.Palette = "none"
+	opts.Path = path
+	opts.SortKeys = KeyOrderBytes
+	var buf bytes.Buffer
+	if err := PrettyStream(&buf, strings.NewReader(pathInput), &opts); err != nil {
+		t.Fatalf("PrettyStream failed: %v", err)
+	}
+	want := `{
+  "id": 2,
+  "metadata": {
+    "name": "b"
+  },
+  "tags": [
+    true,
+    null,
+    -1.5e3,
+    "x"
+  ]
+}
+`
+	if buf.String() != want {
+		t.Fatalf("unexpected output\nexpected:\n%s\nactual:\n%s", want, buf.String())
+	}
+}
+
+func TestPath_SkippedSyntaxErrors(t *testing.T) {
+	path, err := ParsePath(".a")
+	if err != nil {
+		t.Fatalf("ParsePath failed: %v", err)
+	}
+	for input, msg := range map[string]string{
+		`{"b":[1,tru],"a":1}`:     "invalid literal",
+		`{"b":{"c" 1},"a":1}`:     "expected ':' after object key",
+		`{"b":"x` + "\x01" + `"}`: "invalid control character in string",
+		`{"b":[1 2]}`:             "expected ',' or ']'",
+		`{"a":1,}`:                "expected object key",
+	} {
+		opts := *DefaultOptions
+		opts.Path = path
+		var se *SyntaxError
+		_, err := CompactToBuffer(strings.NewReader(input), &opts)
+		if !errors.As(err, &se) || se.Msg != msg {
+			t.Fatalf("%q: expected %q, got %v", input, msg, err)
+		}
+	}
+
+	opts := *DefaultOptions
+	opts.Path = path
+	opts.Dialect = DialectJSONC
+	out, err := CompactToBuffer(strings.NewReader("{\"b\": [1, /* c */ 2,], // d\n \"a\": 1,}"), &opts)
+	if err != nil || string(out) != "1\n" {
+		t.Fatalf("unexpected JSONC output %q, %v", out, err)
+	}
+}
+
+func TestPrettyStream_NoAlloc_Path(t *testing.T) {
+	warmPools()
+
+	input := []byte(`{"a":1,"b":[1,2,{"c":"d"}],"c":{"d":"e"}}`)
+	path, err := ParsePath(".b[2]")
+	if err != nil {
+		t.Fatalf("ParsePath failed: %v", err)
+	}
+	opts := *DefaultOptions
+	opts.Palette = "none"
+	opts.Path = path
+
+	writer := discardStringByteWriter{}
+	reader := bytes.NewReader(input)
+
+	allocs := testing.AllocsPerRun(100, func() {
+		reader.Reset(input)
+		if err := PrettyStream(writer, reader, &opts); err != nil {
+			t.Fatalf("PrettyStream failed: %v", err)
+		}
+	})
+
+	if allocs != 0 {
+		t.Fatalf("expected zero allocations, got %.2f", allocs)
+	}
+}
@@ -29,15 +29,61 @@ func releaseParser(p *parser) {
 	}
 	p.scanner.Reset(nil)
 	p.fmt.clear()
+	p.skipFmt.clear()
+	p.path = nil
+	p.flatten = false
+	p.output = OutputJSON
+	p.written = 0
 	p.formatter = nil
 	p.unwrapDepth = 0
 	p.silentErr = false
+	p.doc = 0
+	p.sortKeys = KeyOrderInput
+	p.canonical = false
+	p.log = LogOff
+	p.logKeys = nil
+	p.passthrough = false
+	p.repair = false
+	p.onRepair = nil
+	p.dialect = DialectJSON
+	p.keepComment = false
+	p.commentBuf = p.commentBuf[:0]
+	p.commentEnds = p.commentEnds[:0]
+	p.recover = nil
+	p.out = nil
+	p.rec.clear()
+	p.docOut.clear()
+	p.sortDepth = 0
 	p.sliceReader.Reset(nil)
+	p.redact = nil
+	p.unwrap = unwrapPolicy{}
+	p.trackPath = false
+	p.pathKeys = p.pathKeys[:0]
+	p.pathSteps = p.pathSteps[:0]
+	p.redactAt = -1
+	for i := range p.unwrapBufs {
+		if cap(p.unwrapBufs[i]) > maxScratchCap {
+			p.unwrapBufs[i] = nil
+		}
+	}
 	if cap(p.scratch) > maxScratchCap {
 		p.scratch = nil
 	} else {
 		p.scratch = p.scratch[:0]
 	}
+	if cap(p.flatPath) > maxScratchCap {
+		p.flatPath = nil
+	} else {
+		p.flatPath = p.flatPath[:0]
+	}
+	if cap(p.spans) > maxScratchCap {
+		p.spans, p.spanStack = nil, nil
+	}
+	if cap(p.lineBuf) > maxScratchCap {
+		p.lineBuf = nil
+	} else {
+		p.lineBuf = p.lineBuf[:0]
+	}
 	if cap(p.decodedBuf) > maxScratchCap {
 		p.decodedBuf = nil
 	} else {
@@ -59,5 +105,8 @@ func releaseValueReader(v *valueReader) {
 	}
 	v.scanner.Reset(nil)
 	v.Reset()
+	if cap(v.rec) > maxScratchCap {
+		v.rec = nil
+	}
 	valueReaderPool.Put(v)
 }
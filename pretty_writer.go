@@ -0,0 +1,106 @@
+package prettyx
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"sync"
+)
+
+var errWriterClosed = errors.New("prettyx: write to closed PrettyWriter")
+
+// prettyWriter is the io.WriteCloser returned by PrettyWriter. Bytes are
+// collected until a newline, then the line is formatted as in Passthrough
+// mode by a pooled parser and written to w with a single Write.
+type prettyWriter struct {
+	mu      sync.Mutex
+	w       io.Writer
+	p       *parser
+	pending []byte
+	out     bytes.Buffer
+	err     error
+}
+
+// PrettyWriter returns a writer that pretty-prints JSON written to it, for
+// wrapping the destination of NDJSON logs such as os.Stderr. Input is
+// handled line by line like Passthrough mode: each JSON object or array in a
+// line is formatted, other text is copied unchanged, and a line is written to
+// w as soon as its newline arrives, however the bytes were split across
+// Write calls. JSON values must not span lines. Only JSON output is
+// supported; Recover, Flatten and Output are ignored. Close writes any
+// unterminated last line and releases the writer. The writer is safe for
+// concurrent use, though lines written concurrently without a newline at the
+// end of each Write may interleave.
+func PrettyWriter(w io.Writer, opts *Options) io.WriteCloser {
+	if opts == nil {
+		opts = DefaultOptions
+	}
+	pw := &prettyWriter{w: w}
+	pal, err := resolvePalette(opts, shouldColor(w, opts))
+	if err != nil {
+		pw.err = err
+		return pw
+	}
+	o := *opts
+	o.Passthrough = true
+	o.Recover = nil
+	o.Flatten = false
+	o.Output = OutputJSON
+	pw.p = acquireParser()
+	pw.p.reset(nil, &pw.out, &o, pal, false)
+	return pw
+}
+
+// Write formats every complete line in b, together with any partial line
+// left over from earlier writes, and keeps the rest for the next call.
+func (pw *prettyWriter) Write(b []byte) (int, error) {
+	pw.mu.Lock()
+	defer pw.mu.Unlock()
+	if pw.err != nil {
+		return 0, pw.err
+	}
+	pw.pending = append(pw.pending, b...)
+	start := 0
+	for {
+		i := bytes.IndexByte(pw.pending[start:], '\n')
+		if i < 0 {
+			break
+		}
+		if err := pw.writeLine(pw.pending[start : start+i]); err != nil {
+			pw.err = err
+			return 0, err
+		}
+		start += i + 1
+	}
+	pw.pending = append(pw.pending[:0], pw.pending[start:]...)
+	return len(b), nil
+}
+
+// Close writes the unterminated last line, if any, and releases the parser.
+func (pw *prettyWriter) Close() error {
+	pw.mu.Lock()
+	defer pw.mu.Unlock()
+	if pw.p == nil {
+		return nil
+	}
+	var err error
+	if len(pw.pending) > 0 && pw.err == nil {
+		err = pw.writeLine(pw.pending)
+	}
+	releaseParser(pw.p)
+	pw.p = nil
+	pw.pending = nil
+	if pw.err == nil {
+		pw.err = errWriterClosed
+	}
+	return err
+}
+
+func (pw *prettyWriter) writeLine(line []byte) error {
+	pw.out.Reset()
+	if err := pw.p.writeMixedLine(line); err != nil {
+		return err
+	}
+	_, err := pw.w.Write(pw.out.Bytes())
+	return err
+}
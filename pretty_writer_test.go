@@ -0,0 +1,63 @@
+package prettyx
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestPrettyWriter(t *testing.T) {
+	opts := *DefaultOptions
+	opts.Palette = "none"
+	var buf bytes.Buffer
+	w := PrettyWriter(&buf, &opts)
+	for _, chunk := range []string{`{"a":`, `[1,2]}`, "\nplain text\n", `2026 INFO {"b":true} done`, "\n{\"c\":"} {
+		if _, err := w.Write([]byte(chunk)); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+	}
+	want := "{\n  \"a\": [\n    1,\n    2\n  ]\n}\nplain text\n2026 INFO {\n  \"b\": true\n} done\n"
+	if buf.String() != want {
+		t.Fatalf("unexpected output before Close\nexpected:\n%s\nactual:\n%s", want, buf.String())
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if got := strings.TrimPrefix(buf.String(), want); got != "{\"c\":\n" {
+		t.Fatalf("unexpected flushed tail %q", got)
+	}
+	if _, err := w.Write([]byte("x\n")); err == nil {
+		t.Fatal("expected error writing after Close")
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("second Close failed: %v", err)
+	}
+}
+
+func TestPrettyWriterOptions(t *testing.T) {
+	var buf bytes.Buffer
+	w := PrettyWriter(&buf, &Options{Palette: "none", Log: LogConsole, Recover: func(*SyntaxError) {}})
+	if _, err := w.Write([]byte(`{"level":"info","msg":"hi","n":1}` + "\n")); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if buf.String() != "INFO  hi n=1\n" {
+		t.Fatalf("unexpected output %q", buf.String())
+	}
+
+	w = PrettyWriter(&buf, &Options{Palette: "no-such-palette"})
+	if _, err := w.Write([]byte("{}\n")); err == nil {
+		t.Fatal("expected palette error")
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	w = PrettyWriter(errWriter{}, &Options{Palette: "none"})
+	if _, err := w.Write([]byte("{}\n")); err == nil {
+		t.Fatal("expected write error")
+	}
+	w.Close()
+}
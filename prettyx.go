@@ -10,7 +10,10 @@ import (
 )
 
 // MaxNestedJSONDepth controls how deep we recursively parse JSON that appears
-// inside string values when Unwrap is enabled. Set to 10 by default. Special case:
+// inside string values when Unwrap is enabled and Options.UnwrapDepth is
+// zero. Set to 10 by default. It is read on every call, so changing it races
+// with concurrent callers; set Options.UnwrapDepth instead to use different
+// depths concurrently. Special case:
 //   - If MaxNestedJSONDepth == 0, we still unwrap one level (i.e., parse the
 //     string as JSON once, but do not recurse further).
 //
@@ -36,11 +39,119 @@ type Options struct {
 	// Unwrap enables recursive decoding of JSON strings. This mirrors the CLI's
 	// -u/--unwrap flag. When false, prettyx leaves any JSON-looking strings as-is.
 	Unwrap bool
+	// UnwrapDepth limits how many levels of strings inside strings Unwrap
+	// decodes; 1 decodes only the outermost strings. When <= 0,
+	// MaxNestedJSONDepth is used.
+	UnwrapDepth int
+	// UnwrapMinLength leaves strings shorter than this many bytes, after
+	// trimming surrounding whitespace, undecoded, so short values such as
+	// "[]" or "{}" stay strings.
+	UnwrapMinLength int
+	// UnwrapKeys, when non-empty, restricts Unwrap to strings inside a member
+	// whose name matches one of these globs, at any depth: with "payload",
+	// the strings in {"payload":"..."} and {"payload":{"body":"..."}} are
+	// decoded. Globs use '*' and '?' and ignore ASCII case, as
+	// RedactRules.Keys do. A string that is the whole document never matches.
+	UnwrapKeys []string
+	// UnwrapSkipKeys lists globs of member names whose strings, at any depth
+	// below the member, are never decoded. It takes precedence over
+	// UnwrapKeys.
+	UnwrapSkipKeys []string
+	// UnwrapDecoders lets Unwrap also decode strings that hold encoded JSON,
+	// such as base64 event payloads. A string that is not JSON as written is
+	// passed to each decoder in turn, and the first result is treated the
+	// same way, up to four times, until it is JSON; so DecodeBase64 and
+	// DecodeGzip together handle compressed base64. Strings that do not end
+	// up as valid JSON are kept as written. Nil decodes only plain JSON;
+	// DefaultUnwrapDecoders holds the built-in decoders.
+	UnwrapDecoders []UnwrapDecoder
+	// UnwrapMaxDecoded limits how many bytes a decoder may produce from one
+	// string, so compressed payloads cannot expand without bound. When <= 0,
+	// DefaultUnwrapMaxDecoded is used.
+	UnwrapMaxDecoded int
+	// UnwrapJWT selects how Unwrap treats JSON Web Tokens, alone or after
+	// "Bearer ". By default each is rendered as an object whose "$jwt"
+	// member holds JWTMarker, followed by the decoded "header" and "claims"
+	// and the "signature" as written; signatures are never verified.
+	// Tokens that a value pattern of Redact matches are masked instead.
+	UnwrapJWT JWTMode
 	// ForceColor emits ANSI color even when the destination is not a TTY.
 	ForceColor bool
 	// Palette selects the named colour palette. Empty chooses the default.
 	// Use "none" to disable colour.
 	Palette string
+	// SortKeys orders object keys recursively, including inside unwrapped
+	// strings. Each object is buffered until its closing brace; arrays and
+	// documents still stream. The zero value keeps input order.
+	SortKeys KeyOrder
+	// Log enables log-aware rendering for PrettyStream and the functions built
+	// on it: top-level objects are treated as log records whose level, time
+	// and message members are coloured with the palette's log slots, and
+	// LogConsole renders each record on a single line. The zero value
+	// formats records like any other JSON.
+	Log LogLayout
+	// LogKeys names the members recognised in Log mode. Nil uses
+	// DefaultLogKeys.
+	LogKeys *LogKeys
+	// Passthrough reads the input line by line for mixed content such as
+	// container logs: each JSON object or array found within a line is
+	// formatted, the surrounding text is copied verbatim (styled with the
+	// palette's Punctuation colour), and lines without valid JSON are written
+	// unchanged instead of failing the stream. JSON values must not span
+	// lines.
+	Passthrough bool
+	// Recover, when set, makes the streaming functions skip malformed
+	// documents instead of failing: the error is passed to Recover and
+	// parsing resumes at the next line that starts with '{' or '['. Each
+	// document is then buffered until it has parsed completely so no partial
+	// output is written. Read and write errors still end the stream.
+	Recover func(*SyntaxError)
+	// Repair accepts truncated and sloppy input and emits valid JSON:
+	// unterminated strings, arrays and objects are closed at the end of
+	// input, and single-quoted strings, Python's True/False/None, trailing
+	// commas and unquoted keys are converted. A raw line break inside a
+	// string is taken as the end of a truncated line: the document is closed
+	// there and the next line starts a new one. Repaired strings are
+	// re-escaped. Canonical output never repairs.
+	Repair bool
+	// OnRepair, when set, is called for each fix Repair applies.
+	OnRepair func(Repair)
+	// Dialect selects the accepted input syntax: strict JSON (the default),
+	// JSONC or JSON5. Output is strict JSON either way.
+	Dialect Dialect
+	// KeepComments writes JSONC and JSON5 comments to pretty output, each on
+	// a line of its own before the token that followed it, styled with the
+	// palette's Comment colour. Comments are always dropped from compact
+	// output and when SortKeys or Log is set.
+	KeepComments bool
+	// Path, when set, writes only the values it selects from each document,
+	// each as a document of its own. Skipped subtrees are checked for syntax
+	// but not formatted, and with Unwrap the path descends into strings that
+	// hold JSON. Documents without a match produce no output. Path is
+	// ignored in Passthrough mode.
+	Path *Path
+	// Flatten writes every value as a gron-style assignment on a line of its
+	// own, such as json.items[0].name = "x";, so documents can be searched
+	// with line-based tools. Unflatten turns such lines back into JSON.
+	// Log and KeepComments are ignored.
+	Flatten bool
+	// Output selects the output format. The zero value writes JSON.
+	// OutputYAML ignores the compact layouts, Log and KeepComments, and
+	// Flatten takes precedence over it. OutputCBOR and OutputMsgPack make
+	// PrettyStream and CompactTo behave like CBORTo and MsgPackTo. The table
+	// formats ignore Log, Passthrough and Flatten; only OutputTable is
+	// coloured, and only by PrettyStream.
+	Output OutputFormat
+	// TableFlatten splits nested objects in table output into columns named
+	// by their dotted path, such as metadata.name. Keys that contain a dot
+	// are JSON-quoted in the path, as in labels."app.kubernetes.io/name", so
+	// they cannot collide with nested members. Arrays stay JSON cells.
+	TableFlatten bool
+	// Redact, when set, replaces secrets with a mask or hash in every output
+	// format, including inside strings decoded by Unwrap. Values are
+	// redacted as they stream; YAML and Flatten output buffer each document
+	// while redacting. Create one with NewRedactor.
+	Redact *Redactor
 }
 
 // DefaultOptions holds the fallback pretty-print configuration.
@@ -113,10 +224,16 @@ func PrettyStream(w io.Writer, r io.Reader, opts *Options) error {
 	if opts == nil {
 		opts = DefaultOptions
 	}
+	if opts.Output == OutputCBOR || opts.Output == OutputMsgPack {
+		return encodeBinary(w, r, opts, opts.Output)
+	}
 	pal, err := resolvePalette(opts, shouldColor(w, opts))
 	if err != nil {
 		return err
 	}
+	if isTableOutput(opts.Output) {
+		return writeTable(w, r, opts, pal)
+	}
 	return streamPretty(w, r, opts, pal, false)
 }
 
@@ -141,4 +258,18 @@ type ColorPalette struct {
 	Null        string
 	Brackets    string
 	Punctuation string
+	// Comment styles comments kept from JSONC and JSON5 input.
+	Comment string
+	// The remaining styles are only used in Log mode.
+	Trace      string
+	Debug      string
+	Info       string
+	Warn       string
+	Error      string
+	Fatal      string
+	Panic      string
+	NoLevel    string
+	Timestamp  string
+	MessageKey string
+	Message    string
 }
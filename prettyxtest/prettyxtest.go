@@ -0,0 +1,232 @@
+// Package prettyxtest provides test helpers that compare JSON by value and
+// report differences as a coloured structural diff.
+//
+//	func TestHandler(t *testing.T) {
+//		got := callHandler(t)
+//		prettyxtest.AssertJSONEqual(t, `{"ok":true,"items":[1,2]}`, got,
+//			prettyxtest.IgnorePaths(".requestId", ".items[].createdAt"))
+//		prettyxtest.AssertGolden(t, "testdata/handler.json", got)
+//	}
+//
+// AssertGolden rewrites golden files from the actual values when the test
+// binary's own -update flag is set, as in the usual
+// var update = flag.Bool("update", ...) pattern; prettyxtest does not define
+// that flag itself. Without it, run "go test -args -prettyxtest.update" or
+// set PRETTYXTEST_UPDATE=1.
+package prettyxtest
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"pkt.systems/prettyx"
+)
+
+// updateEnv names the environment variable that enables updating golden
+// files across packages that do not define -update.
+const updateEnv = "PRETTYXTEST_UPDATE"
+
+var updateFlag = flag.Bool("prettyxtest.update", false, "rewrite prettyxtest golden files with the actual values")
+
+// updating reports whether golden files are to be rewritten: by a -update
+// flag defined by the test binary, by -prettyxtest.update or by updateEnv.
+func updating() bool {
+	if f := flag.Lookup("update"); f != nil {
+		if g, ok := f.Value.(flag.Getter); ok {
+			if b, _ := g.Get().(bool); b {
+				return true
+			}
+		} else if f.Value.String() == "true" {
+			return true
+		}
+	}
+	if *updateFlag {
+		return true
+	}
+	v := os.Getenv(updateEnv)
+	return v != "" && v != "0" && v != "false"
+}
+
+// Option adjusts a comparison.
+type Option func(*config)
+
+type config struct {
+	ignore  []string
+	palette string
+	unwrap  bool
+}
+
+// IgnorePaths leaves the values at the given JSON Pointers or jq-style paths
+// out of the comparison, for timestamps, generated IDs and the like. "[]"
+// matches every element or member, as in ".items[].id".
+func IgnorePaths(paths ...string) Option {
+	return func(c *config) {
+		c.ignore = append(c.ignore, paths...)
+	}
+}
+
+// Palette selects the prettyx palette of failure diffs. The default is
+// "default"; diffs are never coloured when NO_COLOR is set.
+func Palette(name string) Option {
+	return func(c *config) {
+		c.palette = name
+	}
+}
+
+// Unwrap compares strings that hold JSON by the JSON they hold.
+func Unwrap() Option {
+	return func(c *config) {
+		c.unwrap = true
+	}
+}
+
+// Diff returns the changes between want and got, ignoring formatting and
+// key order. See AssertJSONEqual for the values accepted.
+func Diff(want, got any, opts ...Option) ([]prettyx.Change, error) {
+	cfg := newConfig(opts)
+	diffOpts, err := cfg.diffOptions()
+	if err != nil {
+		return nil, err
+	}
+	return diff(want, got, diffOpts)
+}
+
+// AssertJSONEqual reports a test error with a structural diff unless want
+// and got hold the same JSON value. Formatting, key order and the spelling
+// of numbers (1.0 and 1) do not matter. Both may be []byte, string or
+// json.RawMessage holding JSON text, an io.Reader, or any other value, which
+// is marshalled with encoding/json. It reports whether the values matched.
+func AssertJSONEqual(t testing.TB, want, got any, opts ...Option) bool {
+	t.Helper()
+	cfg := newConfig(opts)
+	diffOpts, err := cfg.diffOptions()
+	if err != nil {
+		t.Errorf("prettyxtest: %v", err)
+		return false
+	}
+	changes, err := diff(want, got, diffOpts)
+	if err != nil {
+		t.Errorf("prettyxtest: %v", err)
+		return false
+	}
+	if len(changes) == 0 {
+		return true
+	}
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "JSON values differ (- want, + got):\n")
+	if err := prettyx.WriteDiff(&b, changes, diffOpts); err != nil {
+		t.Errorf("prettyxtest: %v", err)
+		return false
+	}
+	t.Error(b.String())
+	return false
+}
+
+// AssertGolden compares got with the JSON in the golden file at path, as
+// AssertJSONEqual does. With -update, -prettyxtest.update or
+// PRETTYXTEST_UPDATE set, the file is rewritten with got pretty-printed
+// instead, creating its directory when needed.
+func AssertGolden(t testing.TB, path string, got any, opts ...Option) bool {
+	t.Helper()
+	if updating() {
+		if err := writeGolden(path, got); err != nil {
+			t.Errorf("prettyxtest: updating %s: %v", path, err)
+			return false
+		}
+		return true
+	}
+	want, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("prettyxtest: golden file %s does not exist (run the test with -update, -prettyxtest.update or "+updateEnv+"=1 to create it)", path)
+		return false
+	}
+	if err != nil {
+		t.Errorf("prettyxtest: %v", err)
+		return false
+	}
+	return AssertJSONEqual(t, want, got, opts...)
+}
+
+func newConfig(opts []Option) *config {
+	cfg := &config{palette: "default"}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	return cfg
+}
+
+func (c *config) diffOptions() (*prettyx.DiffOptions, error) {
+	format := *prettyx.DefaultOptions
+	format.Palette = c.palette
+	format.ForceColor = os.Getenv("NO_COLOR") == ""
+	if !format.ForceColor {
+		format.Palette = "none"
+	}
+	format.Unwrap = c.unwrap
+	opts := &prettyx.DiffOptions{Options: &format, IgnoreKeyOrder: true}
+	for _, s := range c.ignore {
+		path, err := prettyx.ParsePath(s)
+		if err != nil {
+			return nil, err
+		}
+		opts.Ignore = append(opts.Ignore, path)
+	}
+	return opts, nil
+}
+
+func diff(want, got any, opts *prettyx.DiffOptions) ([]prettyx.Change, error) {
+	wantJSON, err := jsonReader(want)
+	if err != nil {
+		return nil, fmt.Errorf("want: %w", err)
+	}
+	gotJSON, err := jsonReader(got)
+	if err != nil {
+		return nil, fmt.Errorf("got: %w", err)
+	}
+	return prettyx.Diff(wantJSON, gotJSON, opts)
+}
+
+// jsonReader returns a reader of the JSON text v holds or marshals to.
+func jsonReader(v any) (io.Reader, error) {
+	switch v := v.(type) {
+	case []byte:
+		return bytes.NewReader(v), nil
+	case json.RawMessage:
+		return bytes.NewReader(v), nil
+	case string:
+		return bytes.NewReader([]byte(v)), nil
+	case io.Reader:
+		return v, nil
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewReader(b), nil
+}
+
+// writeGolden writes v pretty-printed without colour.
+func writeGolden(path string, v any) error {
+	r, err := jsonReader(v)
+	if err != nil {
+		return err
+	}
+	var b bytes.Buffer
+	opts := *prettyx.DefaultOptions
+	opts.Palette = "none"
+	if err := prettyx.PrettyStream(&b, r, &opts); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, b.Bytes(), 0o644)
+}
@@ -0,0 +1,203 @@
+package prettyxtest
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// recorder is a testing.TB that records errors instead of failing.
+type recorder struct {
+	testing.TB
+	errors []string
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Error(args ...any) {
+	r.errors = append(r.errors, fmt.Sprint(args...))
+}
+
+func (r *recorder) Errorf(format string, args ...any) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func TestAssertJSONEqual(t *testing.T) {
+	t.Setenv("NO_COLOR", "1")
+	type item struct {
+		ID   int    `json:"id"`
+		Name string `json:"name"`
+	}
+
+	tests := []struct {
+		name      string
+		want, got any
+		opts      []Option
+		diff      []string
+	}{
+		{
+			name: "formatting and key order",
+			want: `{"a":1,"b":[true,null]}`,
+			got:  []byte("{\n  \"b\": [ true, null ],\n  \"a\": 1.0\n}"),
+		},
+		{
+			name: "struct against text",
+			want: item{ID: 7, Name: "x"},
+			got:  strings.NewReader(`{"name":"x","id":7}`),
+		},
+		{
+			name: "changed value",
+			want: `{"a":1,"b":2}`,
+			got:  `{"b":3,"a":1}`,
+			diff: []string{"(- want, + got)", "~ .b", "- 2", "+ 3"},
+		},
+		{
+			name: "ignored paths",
+			want: `{"id":"a1","items":[{"at":"2024-01-01","v":1}]}`,
+			got:  `{"id":"b2","items":[{"at":"2025-06-30","v":1}]}`,
+			opts: []Option{IgnorePaths(".id", ".items[].at")},
+		},
+		{
+			name: "ignored paths keep other changes",
+			want: `{"id":"a1","v":1}`,
+			got:  `{"id":"b2","v":2}`,
+			opts: []Option{IgnorePaths("/id")},
+			diff: []string{"~ .v"},
+		},
+		{
+			name: "unwrap",
+			want: `{"body":{"ok":true}}`,
+			got:  `{"body":"{\"ok\":true}"}`,
+			opts: []Option{Unwrap()},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &recorder{TB: t}
+			ok := AssertJSONEqual(r, tt.want, tt.got, tt.opts...)
+			if ok != (len(tt.diff) == 0) || len(r.errors) != len(tt.diff[:min(len(tt.diff), 1)]) {
+				t.Fatalf("ok = %v, errors = %q", ok, r.errors)
+			}
+			for _, s := range tt.diff {
+				if !strings.Contains(r.errors[0], s) {
+					t.Errorf("diff lacks %q:\n%s", s, r.errors[0])
+				}
+			}
+			if len(r.errors) > 0 && strings.Contains(r.errors[0], "\x1b[") {
+				t.Errorf("diff is coloured with NO_COLOR set:\n%s", r.errors[0])
+			}
+		})
+	}
+}
+
+func TestAssertJSONEqualColour(t *testing.T) {
+	t.Setenv("NO_COLOR", "")
+	r := &recorder{TB: t}
+	if AssertJSONEqual(r, `[1]`, `[2]`) {
+		t.Fatal("AssertJSONEqual reported a match")
+	}
+	if len(r.errors) != 1 || !strings.Contains(r.errors[0], "\x1b[") {
+		t.Fatalf("errors = %q, want one coloured diff", r.errors)
+	}
+}
+
+func TestAssertJSONEqualErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		want, got any
+		opts      []Option
+		err       string
+	}{
+		{name: "invalid want", want: `{"a":`, got: `{}`, err: "unexpected end of input"},
+		{name: "invalid got", want: `{}`, got: `nope`, err: "invalid literal"},
+		{name: "unmarshallable", want: `{}`, got: func() {}, err: "got:"},
+		{name: "invalid path", want: `{}`, got: `{}`, opts: []Option{IgnorePaths(".a[")}, err: "prettyxtest:"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &recorder{TB: t}
+			if AssertJSONEqual(r, tt.want, tt.got, tt.opts...) {
+				t.Fatal("AssertJSONEqual reported a match")
+			}
+			if len(r.errors) != 1 || !strings.Contains(r.errors[0], tt.err) {
+				t.Fatalf("errors = %q, want one containing %q", r.errors, tt.err)
+			}
+		})
+	}
+}
+
+func TestDiff(t *testing.T) {
+	changes, err := Diff(`{"a":[1,2],"t":1}`, `{"t":2,"a":[1,2,3]}`, IgnorePaths(".t"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 1 || changes[0].NewPath != ".a[2]" {
+		t.Fatalf("changes = %+v", changes)
+	}
+}
+
+// update is defined the way golden-file tests usually define it; prettyxtest
+// must neither collide with it nor ignore it.
+var update = flag.Bool("update", false, "rewrite golden files")
+
+func TestAssertGolden(t *testing.T) {
+	t.Setenv("NO_COLOR", "1")
+	t.Setenv(updateEnv, "")
+	*update, *updateFlag = false, false
+	path := filepath.Join(t.TempDir(), "testdata", "golden.json")
+	got := map[string]any{"id": 1, "tags": []string{"a"}}
+
+	r := &recorder{TB: t}
+	if AssertGolden(r, path, got) || len(r.errors) != 1 || !strings.Contains(r.errors[0], "-update") {
+		t.Fatalf("missing golden file: errors = %q", r.errors)
+	}
+
+	*update = true
+	t.Cleanup(func() { *update = false })
+	r = &recorder{TB: t}
+	if !AssertGolden(r, path, got) || len(r.errors) != 0 {
+		t.Fatalf("update: errors = %q", r.errors)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const want = "{\n  \"id\": 1,\n  \"tags\": [\n    \"a\"\n  ]\n}\n"
+	if string(data) != want {
+		t.Fatalf("golden file = %q, want %q", data, want)
+	}
+
+	*update = false
+	r = &recorder{TB: t}
+	if !AssertGolden(r, path, `{"tags":["a"],"id":1}`) || len(r.errors) != 0 {
+		t.Fatalf("match: errors = %q", r.errors)
+	}
+	r = &recorder{TB: t}
+	if AssertGolden(r, path, `{"tags":["b"],"id":1}`) || len(r.errors) != 1 || !strings.Contains(r.errors[0], ".tags[0]") {
+		t.Fatalf("mismatch: errors = %q", r.errors)
+	}
+}
+
+func TestUpdateSources(t *testing.T) {
+	t.Setenv(updateEnv, "")
+	*update, *updateFlag = false, false
+	if updating() {
+		t.Fatal("updating without any source set")
+	}
+	*updateFlag = true
+	if !updating() {
+		t.Fatal("-prettyxtest.update ignored")
+	}
+	*updateFlag = false
+	t.Setenv(updateEnv, "1")
+	if !updating() {
+		t.Fatal(updateEnv + " ignored")
+	}
+	t.Setenv(updateEnv, "0")
+	if updating() {
+		t.Fatal(updateEnv + "=0 enabled updating")
+	}
+}
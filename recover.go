@@ -0,0 +1,173 @@
+package prettyx
+
+import (
+	"errors"
+	"io"
+)
+
+// recoverReader records everything read from r since the start of the
+// current document so the scanner can be rewound to the line after a
+// malformed one.
+type recoverReader struct {
+	r    io.Reader
+	rec  []byte
+	base int64 // stream offset of rec[0]
+	off  int   // next index in rec served by Read
+}
+
+func (rr *recoverReader) reset(r io.Reader) {
+	rr.r = r
+	rr.rec = rr.rec[:0]
+	rr.base = 0
+	rr.off = 0
+}
+
+func (rr *recoverReader) clear() {
+	rr.r = nil
+	if cap(rr.rec) > maxScratchCap {
+		rr.rec = nil
+	} else {
+		rr.rec = rr.rec[:0]
+	}
+	rr.base = 0
+	rr.off = 0
+}
+
+func (rr *recoverReader) Read(p []byte) (int, error) {
+	if rr.off < len(rr.rec) {
+		n := copy(p, rr.rec[rr.off:])
+		rr.off += n
+		return n, nil
+	}
+	n, err := rr.r.Read(p)
+	rr.rec = append(rr.rec, p[:n]...)
+	rr.off += n
+	return n, err
+}
+
+// discard forgets the bytes before stream offset off. The buffer is only
+// compacted once enough has been dropped to make the copy worthwhile.
+func (rr *recoverReader) discard(off int64) {
+	i := int(off - rr.base)
+	if i < maxScratchCap && i < len(rr.rec) {
+		return
+	}
+	rr.rec = rr.rec[:copy(rr.rec, rr.rec[i:])]
+	rr.off -= i
+	rr.base = off
+}
+
+// rewind makes Read serve the stream again from offset off, which must still
+// be recorded.
+func (rr *recoverReader) rewind(off int64) {
+	rr.off = int(off - rr.base)
+}
+
+// end returns the stream offset just past the last byte read from r.
+func (rr *recoverReader) end() int64 {
+	return rr.base + int64(len(rr.rec))
+}
+
+// streamRecover is stream for Options.Recover. Each document is formatted
+// into memory and only written once it parsed completely; a malformed one is
+// reported and skipped, and parsing resumes at the next line that starts
+// with '{' or '['.
+func (p *parser) streamRecover() error {
+	for {
+		err := p.skipSpace()
+		if err == io.EOF {
+			if len(p.commentEnds) == 0 {
+				return nil
+			}
+			p.docOut.buf = p.docOut.buf[:0]
+			if err := p.writeComments(0, false); err != nil {
+				return err
+			}
+			_, err := p.out.Write(p.docOut.buf)
+			return err
+		}
+		if err != nil {
+			return err
+		}
+		start, line, col := p.scanner.position(p.scanner.pos)
+		p.rec.discard(start)
+		p.doc++
+		p.docOut.buf = p.docOut.buf[:0]
+		p.formatter.lineLen = 0
+		err = p.parseDocument()
+		p.scanner.unfence()
+		if err == io.EOF {
+			err = p.errorfNext("unexpected end of input")
+		}
+		if err == nil {
+			if _, err := p.out.Write(p.docOut.buf); err != nil {
+				return err
+			}
+			continue
+		}
+		var se *SyntaxError
+		if !errors.As(err, &se) {
+			return err
+		}
+		p.dropComments()
+		p.recover(se)
+		if err := p.resync(start, line, start-int64(col-1)); err != nil {
+			return err
+		}
+	}
+}
+
+// resync moves the scanner to the first line after the one starting at
+// docStart whose first non-blank byte opens an object or array.
+func (p *parser) resync(docStart int64, line int, lineStart int64) error {
+	p.rec.rewind(docStart)
+	rest := p.rec.rec[p.rec.off:]
+	for i, c := range rest {
+		if c == '\n' {
+			off := docStart + int64(i) + 1
+			p.rec.rewind(off)
+			p.scanner.resume(&p.rec, off, line, off)
+			return p.skipToRecord()
+		}
+	}
+	end := p.rec.end()
+	p.rec.rewind(end)
+	p.scanner.resume(&p.rec, end, line-1, lineStart)
+	if err := p.skipLine(); err != nil {
+		return err
+	}
+	return p.skipToRecord()
+}
+
+func (p *parser) skipToRecord() error {
+	for {
+		if err := p.scanner.skipSpace(); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		b, _ := p.scanner.peekByte()
+		if b == '{' || b == '[' {
+			return nil
+		}
+		if err := p.skipLine(); err != nil {
+			return err
+		}
+	}
+}
+
+func (p *parser) skipLine() error {
+	for {
+		b, err := p.scanner.readByte()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if b == '\n' {
+			return nil
+		}
+	}
+}
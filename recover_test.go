@@ -0,0 +1,109 @@
+package prettyx
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+)
+
+const recoverInput = "{\"a\":1}\n{\"b\":2,\n{\"c\":3}\ngarbage\n  \"x\": 1\n[1,2] ]\n{\"d\":\"unterminated\n"
+
+func TestRecover_SkipsAndResynchronises(t *testing.T) {
+	var skipped []*SyntaxError
+	opts := *DefaultOptions
+	opts.Palette = "none"
+	opts.Recover = func(se *SyntaxError) { skipped = append(skipped, se) }
+
+	out, err := CompactToBuffer(strings.NewReader(recoverInput), &opts)
+	if err != nil {
+		t.Fatalf("CompactToBuffer failed: %v", err)
+	}
+	if want := "{\"a\":1}\n{\"c\":3}\n[1,2]\n"; string(out) != want {
+		t.Fatalf("unexpected output\nexpected:\n%s\nactual:\n%s", want, out)
+	}
+	want := []struct {
+		doc, line, col int
+	}{
+		{2, 3, 1},
+		{4, 4, 1},
+		{6, 6, 7},
+		{7, 7, 19},
+	}
+	if len(skipped) != len(want) {
+		t.Fatalf("expected %d skipped documents, got %d: %v", len(want), len(skipped), skipped)
+	}
+	for i, w := range want {
+		se := skipped[i]
+		if se.Document != w.doc || se.Line != w.line || se.Column != w.col {
+			t.Fatalf("skip %d: unexpected position %+v", i, se)
+		}
+	}
+
+	skipped = skipped[:0]
+	var buf bytes.Buffer
+	if err := PrettyStream(&buf, strings.NewReader(recoverInput), &opts); err != nil {
+		t.Fatalf("PrettyStream failed: %v", err)
+	}
+	if want := "{\n  \"a\": 1\n}\n{\n  \"c\": 3\n}\n[\n  1,\n  2\n]\n"; buf.String() != want {
+		t.Fatalf("unexpected pretty output %q", buf.String())
+	}
+	if len(skipped) != len(want) {
+		t.Fatalf("expected %d skipped documents, got %d", len(want), len(skipped))
+	}
+
+	skipped = skipped[:0]
+	out, err = CanonicalToBuffer(strings.NewReader("{\"b\":1,\"a\":1e400}\n{\"b\":1,\"a\":2}\n"), &opts)
+	if err != nil || string(out) != "{\"a\":2,\"b\":1}\n" || len(skipped) != 1 {
+		t.Fatalf("unexpected canonical recovery %q, %v, %d", out, err, len(skipped))
+	}
+}
+
+func TestRecover_LongStreams(t *testing.T) {
+	var b strings.Builder
+	for i := 0; i < 20000; i++ {
+		if i%1000 == 999 {
+			fmt.Fprintf(&b, "{\"n\":%d,\"s\":\"%s\n", i, strings.Repeat("x", 100))
+			continue
+		}
+		fmt.Fprintf(&b, "{\"n\":%d}\n", i)
+	}
+	b.WriteString(`{"tail":"` + strings.Repeat("y", 10000))
+	var skipped []*SyntaxError
+	opts := *DefaultOptions
+	opts.Recover = func(se *SyntaxError) { skipped = append(skipped, se) }
+
+	out, err := CompactToBuffer(strings.NewReader(b.String()), &opts)
+	if err != nil {
+		t.Fatalf("CompactToBuffer failed: %v", err)
+	}
+	if n := bytes.Count(out, []byte("\n")); n != 19980 {
+		t.Fatalf("expected 19980 documents, got %d", n)
+	}
+	if len(skipped) != 21 {
+		t.Fatalf("expected 21 skipped documents, got %d", len(skipped))
+	}
+	for i, se := range skipped[:20] {
+		if se.Line != 1000*(i+1) || se.Document != 1000*(i+1) {
+			t.Fatalf("skip %d: unexpected position %+v", i, se)
+		}
+	}
+	if last := skipped[20]; last.Line != 20001 || last.Msg != "unexpected end of input" {
+		t.Fatalf("unexpected final error %+v", last)
+	}
+}
+
+func TestRecover_ReadAndWriteErrorsEndStream(t *testing.T) {
+	opts := *DefaultOptions
+	opts.Recover = func(*SyntaxError) { t.Fatalf("unexpected recovery") }
+	r := &errAfterReader{data: []byte("{\"a\":1}\n{\"b\":")}
+	var se *SyntaxError
+	if err := PrettyStream(io.Discard, r, &opts); err == nil || errors.As(err, &se) {
+		t.Fatalf("expected plain reader error, got %v", err)
+	}
+	if err := PrettyStream(errWriter{}, strings.NewReader("{\"a\":1}\n"), &opts); err == nil {
+		t.Fatalf("expected writer error")
+	}
+}
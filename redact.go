@@ -0,0 +1,392 @@
+package prettyx
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"regexp"
+)
+
+// Value patterns for common secret shapes, for use in RedactRules.Values.
+const (
+	// RedactJWT matches JSON Web Tokens in compact serialization.
+	RedactJWT = `eyJ[A-Za-z0-9_-]*\.eyJ[A-Za-z0-9_-]*\.[A-Za-z0-9_-]*`
+	// RedactAWSAccessKey matches AWS access key IDs.
+	RedactAWSAccessKey = `\b(?:AKIA|ASIA|AGPA|AIDA|AROA|ANPA|ANVA|AIPA)[0-9A-Z]{16}\b`
+	// RedactBearer matches the credentials of a Bearer or Basic
+	// Authorization header embedded in a string.
+	RedactBearer = `(?i)\b(?:bearer|basic) [A-Za-z0-9._~+/=-]{8,}`
+)
+
+// DefaultRedactMask replaces redacted values when RedactRules.Mask is empty.
+const DefaultRedactMask = "[REDACTED]"
+
+// RedactRules describe what to redact. Compile them with NewRedactor.
+type RedactRules struct {
+	// Keys are glob patterns matched against member names, ignoring ASCII
+	// case. '*' matches any run of bytes and '?' any single byte, so
+	// "*password*" matches "db_password" and "PasswordHash".
+	Keys []string
+	// Paths are JSON Pointers or jq-style paths, as accepted by ParsePath,
+	// of values to redact. [] matches every member or element at its level.
+	Paths []string
+	// Values are regular expressions matched against string values. Only
+	// the matching parts of a string are replaced.
+	Values []string
+	// Mask replaces redacted values. Empty uses DefaultRedactMask.
+	Mask string
+	// Hash replaces redacted values with a short SHA-256 digest such as
+	// "[sha256:9f86d081884c7d65]" instead of Mask, so equal secrets can
+	// still be recognised as equal. Strings are hashed decoded and other
+	// values in their compact form.
+	Hash bool
+	// HashKey, when set with Hash, keys the digest with HMAC-SHA256 so
+	// guessable secrets cannot be recovered by hashing candidates. Without
+	// it the digest is a plain SHA-256 prefix, which anyone can reproduce.
+	HashKey []byte
+}
+
+// DefaultRedactRules cover common credential member names, JSON Web Tokens,
+// AWS access key IDs and Bearer credentials.
+var DefaultRedactRules = &RedactRules{
+	Keys: []string{
+		"*password*", "*passwd*", "*secret*", "*token*", "*api_key*", "*apikey*",
+		"*api-key*", "*private_key*", "*credential*", "authorization", "cookie",
+		"set-cookie", "x-api-key",
+	},
+	Values: []string{RedactJWT, RedactAWSAccessKey, RedactBearer},
+}
+
+// Redactor is a compiled set of RedactRules. Create one with NewRedactor and
+// share it between calls; it is not modified after compilation.
+type Redactor struct {
+	keys    []string
+	paths   [][]pathSegment
+	values  []*regexp.Regexp
+	mask    string
+	hash    bool
+	hashKey []byte
+}
+
+// NewRedactor compiles rules. Nil rules compile to DefaultRedactRules.
+func NewRedactor(rules *RedactRules) (*Redactor, error) {
+	if rules == nil {
+		rules = DefaultRedactRules
+	}
+	r := &Redactor{
+		mask:    rules.Mask,
+		hash:    rules.Hash,
+		hashKey: rules.HashKey,
+	}
+	if r.mask == "" {
+		r.mask = DefaultRedactMask
+	}
+	r.keys = append(r.keys, rules.Keys...)
+	for _, s := range rules.Paths {
+		path, err := ParsePath(s)
+		if err != nil {
+			return nil, fmt.Errorf("redact: %w", err)
+		}
+		r.paths = append(r.paths, path.segs)
+	}
+	for _, s := range rules.Values {
+		re, err := regexp.Compile(s)
+		if err != nil {
+			return nil, fmt.Errorf("redact: invalid value pattern %q: %w", s, err)
+		}
+		r.values = append(r.values, re)
+	}
+	return r, nil
+}
+
+// tracksPath reports whether the rules depend on where a value is, so the
+// parser has to keep the path to the current value.
+func (r *Redactor) tracksPath() bool {
+	return r != nil && (len(r.keys) > 0 || len(r.paths) > 0)
+}
+
+func (r *Redactor) matchKey(key []byte) bool {
+	return matchAnyGlobFold(r.keys, key)
+}
+
+// matchPath reports whether a path rule selects the value at steps.
+func (r *Redactor) matchPath(keys []byte, steps []pathStep) bool {
+	for _, segs := range r.paths {
+		if len(segs) != len(steps) {
+			continue
+		}
+		match := true
+		for i := range segs {
+			if !steps[i].matches(keys, &segs[i]) {
+				match = false
+				break
+			}
+		}
+		if match {
+			return true
+		}
+	}
+	return false
+}
+
+// matchValue reports whether a value pattern matches val.
+func (r *Redactor) matchValue(val []byte) bool {
+	for _, re := range r.values {
+		if re.Match(val) {
+			return true
+		}
+	}
+	return false
+}
+
+// replace returns the value with the parts matched by the value patterns
+// replaced, and whether anything matched.
+func (r *Redactor) replace(val []byte) ([]byte, bool) {
+	matched := false
+	for _, re := range r.values {
+		if !re.Match(val) {
+			continue
+		}
+		matched = true
+		val = re.ReplaceAllFunc(val, func(secret []byte) []byte {
+			return r.appendMask(nil, secret)
+		})
+	}
+	return val, matched
+}
+
+// appendMask appends the replacement for the secret value.
+func (r *Redactor) appendMask(dst []byte, secret []byte) []byte {
+	if !r.hash {
+		return append(dst, r.mask...)
+	}
+	var h hash.Hash
+	if r.hashKey != nil {
+		h = hmac.New(sha256.New, r.hashKey)
+		dst = append(dst, "[hmac-sha256:"...)
+	} else {
+		h = sha256.New()
+		dst = append(dst, "[sha256:"...)
+	}
+	h.Write(secret)
+	var sum [sha256.Size]byte
+	dst = hex.AppendEncode(dst, h.Sum(sum[:0])[:8])
+	return append(dst, ']')
+}
+
+// matchAnyGlobFold reports whether name matches any of the patterns.
+func matchAnyGlobFold(patterns []string, name []byte) bool {
+	for _, pattern := range patterns {
+		if matchGlobFold(pattern, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchGlobFold matches name against a glob of '*' and '?' wildcards,
+// ignoring ASCII case.
+func matchGlobFold(pattern string, name []byte) bool {
+	star, resume := -1, 0
+	i, j := 0, 0
+	for j < len(name) {
+		switch {
+		case i < len(pattern) && pattern[i] == '*':
+			star, resume = i, j
+			i++
+		case i < len(pattern) && (pattern[i] == '?' || lowerASCII(pattern[i]) == lowerASCII(name[j])):
+			i++
+			j++
+		case star >= 0:
+			resume++
+			i, j = star+1, resume
+		default:
+			return false
+		}
+	}
+	for i < len(pattern) && pattern[i] == '*' {
+		i++
+	}
+	return i == len(pattern)
+}
+
+func lowerASCII(b byte) byte {
+	if b >= 'A' && b <= 'Z' {
+		return b + 'a' - 'A'
+	}
+	return b
+}
+
+// pathStep is one level of the path to the value being parsed: a member
+// whose decoded name is keys[start:end], or the array element at index. The
+// path is kept while redacting by key or path and for the unwrap key rules.
+type pathStep struct {
+	start, end int
+	index      int
+}
+
+func (s *pathStep) matches(keys []byte, seg *pathSegment) bool {
+	if seg.wildcard {
+		return true
+	}
+	if s.index < 0 {
+		return seg.hasKey && string(keys[s.start:s.end]) == seg.key
+	}
+	return seg.index == s.index
+}
+
+// enterMember records that the next value is the member named key and
+// decides whether it is redacted.
+func (p *parser) enterMember(key []byte) {
+	if !p.trackPath {
+		return
+	}
+	start := len(p.pathKeys)
+	p.pathKeys = append(p.pathKeys, key...)
+	p.pathSteps = append(p.pathSteps, pathStep{start: start, end: len(p.pathKeys), index: -1})
+	p.redactAt = -1
+	if p.redact.tracksPath() && (p.redact.matchKey(key) || p.redact.matchPath(p.pathKeys, p.pathSteps)) {
+		p.redactAt = len(p.pathSteps)
+	}
+}
+
+// enterElement records that the next value is the array element at index.
+func (p *parser) enterElement(index int) {
+	if !p.trackPath {
+		return
+	}
+	p.pathSteps = append(p.pathSteps, pathStep{start: len(p.pathKeys), end: len(p.pathKeys), index: index})
+	p.redactAt = -1
+	if p.redact.tracksPath() && p.redact.matchPath(p.pathKeys, p.pathSteps) {
+		p.redactAt = len(p.pathSteps)
+	}
+}
+
+// leave drops the last step entered.
+func (p *parser) leave() {
+	if !p.trackPath {
+		return
+	}
+	last := p.pathSteps[len(p.pathSteps)-1]
+	p.pathKeys = p.pathKeys[:last.start]
+	p.pathSteps = p.pathSteps[:len(p.pathSteps)-1]
+	p.redactAt = -1
+}
+
+// redactPending reports whether the value about to be parsed is redacted by
+// a key or path rule.
+func (p *parser) redactPending() bool {
+	return p.redact != nil && p.redactAt >= 0 && p.redactAt == len(p.pathSteps)
+}
+
+// writeRedactedValue consumes the value starting with first and writes its
+// replacement as a string. Only hashing needs the value itself, which is
+// captured compactly; otherwise it is skipped like an unselected value.
+func (p *parser) writeRedactedValue(first byte) error {
+	p.redactAt = -1
+	r := p.redact
+	p.redact = nil
+	var err error
+	if !r.hash {
+		err = p.skipValue(first)
+		p.scratch = r.appendMask(p.scratch[:0], nil)
+	} else {
+		out, unwrap := p.formatter, p.unwrapDepth
+		frame := p.pushSortFrame()
+		p.formatter, p.unwrapDepth = &frame.fmt, 0
+		err = p.parseValueWithFirst(0, first)
+		p.formatter, p.unwrapDepth = out, unwrap
+		secret := frame.buf
+		if len(secret) > 0 && secret[0] == '"' {
+			p.decodedBuf = appendUnquoted(p.decodedBuf[:0], secret[1:len(secret)-1])
+			secret = p.decodedBuf
+		}
+		p.scratch = r.appendMask(p.scratch[:0], secret)
+		p.popSortFrame()
+	}
+	p.redact = r
+	if err != nil {
+		return err
+	}
+	p.decodedBuf = append(p.decodedBuf[:0], p.scratch...)
+	return p.writeQuotedBytes(p.decodedBuf, p.formatter.pal.String)
+}
+
+// redactString returns val with the parts matched by the value patterns
+// replaced.
+func (p *parser) redactString(val []byte) []byte {
+	if p.redact != nil {
+		val, _ = p.redact.replace(val)
+	}
+	return val
+}
+
+// writeMemberKey writes an object key while the path is tracked, keeping the
+// token as written in the input like copyStringToken does, and enters the
+// member.
+func (p *parser) writeMemberKey(first byte) error {
+	if p.extendedKeys() {
+		key, err := p.readRepairKey(first)
+		if err != nil {
+			return err
+		}
+		if err := p.writeQuotedBytes(key, p.formatter.pal.Key); err != nil {
+			return err
+		}
+		p.enterMember(key)
+		return nil
+	}
+	if first != '"' {
+		return p.errorf("expected object key")
+	}
+	raw, err := p.captureStringToken()
+	if err == nil {
+		err = p.formatter.writeStyledBytes(p.formatter.pal.Key, raw)
+	}
+	if err == nil {
+		p.decodedBuf = appendUnquoted(p.decodedBuf[:0], raw[1:len(raw)-1])
+		p.enterMember(p.decodedBuf)
+	}
+	p.popSortFrame()
+	return err
+}
+
+// captureStringToken reads a string token into a sort frame and returns it
+// as written in the input. The caller pops the frame once done with it.
+func (p *parser) captureStringToken() ([]byte, error) {
+	out := p.formatter
+	frame := p.pushSortFrame()
+	p.formatter = &frame.fmt
+	err := p.copyStringToken("")
+	p.formatter = out
+	return frame.buf, err
+}
+
+// writeRedactedString writes a string token, replacing the parts matched by
+// the value patterns. Strings without a match keep their input escaping.
+func (p *parser) writeRedactedString() error {
+	raw, err := p.captureStringToken()
+	if err == nil {
+		p.decodedBuf = appendUnquoted(p.decodedBuf[:0], raw[1:len(raw)-1])
+		if val, ok := p.redact.replace(p.decodedBuf); ok {
+			err = p.writeQuotedBytes(val, p.formatter.pal.String)
+		} else {
+			err = p.formatter.writeStyledBytes(p.formatter.pal.String, raw)
+		}
+	}
+	p.popSortFrame()
+	return err
+}
+
+// inheritPath gives the parser v for a nested document, such as a string
+// being unwrapped, p's redaction and unwrap rules and the path to the value.
+func (v *parser) inheritPath(p *parser) {
+	v.redact = p.redact
+	v.unwrap = p.unwrap
+	v.trackPath = p.trackPath
+	v.pathKeys = append(v.pathKeys[:0], p.pathKeys...)
+	v.pathSteps = append(v.pathSteps[:0], p.pathSteps...)
+	v.redactAt = -1
+}
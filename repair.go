@@ -0,0 +1,231 @@
+package prettyx
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+// RepairKind identifies a fix applied by Options.Repair.
+type RepairKind int
+
+const (
+	// RepairUnterminatedString closed a string cut off by the end of input.
+	RepairUnterminatedString RepairKind = iota
+	// RepairUnclosedObject closed an object cut off by the end of input.
+	RepairUnclosedObject
+	// RepairUnclosedArray closed an array cut off by the end of input.
+	RepairUnclosedArray
+	// RepairMissingValue wrote null for a member or element whose value was
+	// cut off by the end of input.
+	RepairMissingValue
+	// RepairTruncatedLiteral completed true, false or null.
+	RepairTruncatedLiteral
+	// RepairIncompleteNumber appended a 0 to a number such as "1." or "2e".
+	RepairIncompleteNumber
+	// RepairSingleQuotes converted a single-quoted string.
+	RepairSingleQuotes
+	// RepairPythonLiteral converted True, False or None.
+	RepairPythonLiteral
+	// RepairTrailingComma dropped a comma before a closing bracket.
+	RepairTrailingComma
+	// RepairUnquotedKey quoted a bare object key.
+	RepairUnquotedKey
+	// RepairControlCharacter escaped a raw control character in a string.
+	RepairControlCharacter
+)
+
+var repairKindNames = [...]string{
+	RepairUnterminatedString: "unterminated string",
+	RepairUnclosedObject:     "unclosed object",
+	RepairUnclosedArray:      "unclosed array",
+	RepairMissingValue:       "missing value",
+	RepairTruncatedLiteral:   "truncated literal",
+	RepairIncompleteNumber:   "incomplete number",
+	RepairSingleQuotes:       "single-quoted string",
+	RepairPythonLiteral:      "Python literal",
+	RepairTrailingComma:      "trailing comma",
+	RepairUnquotedKey:        "unquoted key",
+	RepairControlCharacter:   "control character in string",
+}
+
+func (k RepairKind) String() string {
+	if k >= 0 && int(k) < len(repairKindNames) {
+		return repairKindNames[k]
+	}
+	return fmt.Sprintf("RepairKind(%d)", int(k))
+}
+
+// Repair describes one fix applied by Options.Repair. The position is that of
+// the offending input byte, or of the end of input for truncation fixes.
+type Repair struct {
+	Kind     RepairKind
+	Offset   int64
+	Line     int
+	Column   int
+	Document int
+}
+
+func (r Repair) String() string {
+	if r.Document > 1 {
+		return fmt.Sprintf("repaired %s at line %d, column %d (offset %d, document %d)", r.Kind, r.Line, r.Column, r.Offset, r.Document)
+	}
+	return fmt.Sprintf("repaired %s at line %d, column %d (offset %d)", r.Kind, r.Line, r.Column, r.Offset)
+}
+
+// errRepairClose tells a container loop that a trailing comma has been
+// consumed and it should close.
+var errRepairClose = errors.New("json: repair close")
+
+// reportRepair passes a fix at buf[idx] to the OnRepair callback. Forms the
+// input dialect allows are not repairs and are not reported.
+func (p *parser) reportRepair(kind RepairKind, idx int) {
+	if p.onRepair == nil || p.dialectAllows(kind) {
+		return
+	}
+	off, line, col := p.scanner.position(idx)
+	p.onRepair(Repair{Kind: kind, Offset: off, Line: line, Column: col, Document: p.doc})
+}
+
+// repairEOF reports whether err is an end of input that repair mode fixes
+// with kind, reporting the fix if so.
+func (p *parser) repairEOF(err error, kind RepairKind) bool {
+	if !p.repair || err != io.EOF {
+		return false
+	}
+	p.reportRepair(kind, p.scanner.pos)
+	return true
+}
+
+// truncatedAt reports whether b, just read inside a string, is a raw line
+// break. A string cannot span lines, so repair mode takes it as the point
+// where a line was cut off: the scanner is fenced before the break so the
+// string and every open container close there, and the next line is read as
+// the next document.
+func (p *parser) truncatedAt(b byte) bool {
+	switch b {
+	case '\n':
+		p.scanner.pos--
+	case '\r':
+		// The '\r' is dropped; peeking may refill the buffer, so it cannot
+		// be unread.
+		if next, err := p.scanner.peekByte(); err != nil || next != '\n' {
+			return false
+		}
+	default:
+		return false
+	}
+	p.scanner.fence()
+	return true
+}
+
+// readAfterComma reads the first byte after a ',' in an object or array. In
+// repair mode and in the JSONC and JSON5 dialects a comma followed by the
+// closing bracket is dropped and errRepairClose returned; repair mode also
+// closes the container when the input ends after the comma.
+func (p *parser) readAfterComma(closing byte) (byte, error) {
+	if !p.repair && p.dialect == DialectJSON {
+		return p.readNonSpace()
+	}
+	report := p.onRepair != nil && !p.dialectAllows(RepairTrailingComma)
+	var comma Repair
+	if report {
+		off, line, col := p.scanner.position(p.scanner.pos - 1)
+		comma = Repair{Kind: RepairTrailingComma, Offset: off, Line: line, Column: col, Document: p.doc}
+	}
+	b, err := p.readNonSpace()
+	if !(err == nil && b == closing) && !(err == io.EOF && p.repair) {
+		return b, err
+	}
+	if report {
+		p.onRepair(comma)
+	}
+	if err == io.EOF {
+		kind := RepairUnclosedArray
+		if closing == '}' {
+			kind = RepairUnclosedObject
+		}
+		p.reportRepair(kind, p.scanner.pos)
+	}
+	return 0, errRepairClose
+}
+
+// writeRepairKey writes an object key in repair mode or JSON5, where it may
+// be single quoted or bare.
+func (p *parser) writeRepairKey(first byte) error {
+	key, err := p.readRepairKey(first)
+	if err != nil {
+		return err
+	}
+	return p.writeQuotedBytes(key, p.formatter.pal.Key)
+}
+
+func (p *parser) readRepairKey(first byte) ([]byte, error) {
+	switch {
+	case first == '"':
+		return p.readQuoted('"')
+	case first == '\'':
+		p.reportRepair(RepairSingleQuotes, p.scanner.pos-1)
+		return p.readQuoted('\'')
+	case isKeyByte(first):
+		p.reportRepair(RepairUnquotedKey, p.scanner.pos-1)
+		p.decodedBuf = append(p.decodedBuf[:0], first)
+		for {
+			b, err := p.scanner.peekByte()
+			if err == io.EOF || (err == nil && !isKeyByte(b)) {
+				return p.decodedBuf, nil
+			}
+			if err != nil {
+				return nil, err
+			}
+			_, _ = p.scanner.readByte()
+			p.decodedBuf = append(p.decodedBuf, b)
+		}
+	default:
+		return nil, p.errorf("expected object key")
+	}
+}
+
+// isKeyByte reports whether b may appear in a bare key: ASCII letters and
+// digits, '_', '$', '-', '.', and any byte of a multi-byte UTF-8 sequence.
+func isKeyByte(b byte) bool {
+	return (b >= 'a' && b <= 'z') || (b >= 'A' && b <= 'Z') || (b >= '0' && b <= '9') ||
+		b == '_' || b == '$' || b == '-' || b == '.' || b >= 0x80
+}
+
+// parseExtendedValue handles the value forms only repair mode or JSON5
+// accept.
+func (p *parser) parseExtendedValue(first byte) error {
+	json5 := p.dialect == DialectJSON5
+	var lit, out, style string
+	switch first {
+	case '\'':
+		p.reportRepair(RepairSingleQuotes, p.scanner.pos-1)
+		val, err := p.readQuoted('\'')
+		if err != nil {
+			return err
+		}
+		return p.writeQuotedBytes(val, p.formatter.pal.String)
+	case '+', '.', 'I':
+		if json5 {
+			return p.parseJSON5Number(first)
+		}
+	case 'N':
+		if next, _ := p.scanner.peekByte(); json5 && (next == 'a' || !p.repair) {
+			return p.parseJSON5Number(first)
+		}
+		lit, out, style = "None", "null", p.formatter.pal.Null
+	case 'T':
+		lit, out, style = "True", "true", p.formatter.pal.True
+	case 'F':
+		lit, out, style = "False", "false", p.formatter.pal.False
+	}
+	if lit == "" || !p.repair {
+		return p.errorf("unexpected character %q", first)
+	}
+	p.reportRepair(RepairPythonLiteral, p.scanner.pos-1)
+	if err := p.matchLiteral(lit); err != nil {
+		return err
+	}
+	return p.formatter.writeLiteral(out, style)
+}
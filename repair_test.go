@@ -0,0 +1,115 @@
+package prettyx
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestRepair_FixesSloppyInput(t *testing.T) {
+	cases := []struct {
+		input string
+		want  string
+		kinds []RepairKind
+	}{
+		{`{"a": [1, 2, {"b": "trunc`, `{"a":[1,2,{"b":"trunc"}]}`,
+			[]RepairKind{RepairUnterminatedString, RepairUnclosedObject, RepairUnclosedArray, RepairUnclosedObject}},
+		{`{'name': 'O\'Brien "x"', ok: True, none: None, f: False}`, `{"name":"O'Brien \"x\"","ok":true,"none":null,"f":false}`,
+			[]RepairKind{RepairSingleQuotes, RepairSingleQuotes, RepairUnquotedKey, RepairPythonLiteral, RepairUnquotedKey, RepairPythonLiteral, RepairUnquotedKey, RepairPythonLiteral}},
+		{`[1,2,]`, `[1,2]`, []RepairKind{RepairTrailingComma}},
+		{`{"a":1,}`, `{"a":1}`, []RepairKind{RepairTrailingComma}},
+		{`[1,`, `[1]`, []RepairKind{RepairTrailingComma, RepairUnclosedArray}},
+		{`{"a":`, `{"a":null}`, []RepairKind{RepairMissingValue, RepairUnclosedObject}},
+		{`{"a"`, `{"a":null}`, []RepairKind{RepairMissingValue, RepairUnclosedObject}},
+		{`[1., -, 2e+, tr`, `[1.0,-0,2e+0,true]`,
+			[]RepairKind{RepairIncompleteNumber, RepairIncompleteNumber, RepairIncompleteNumber, RepairTruncatedLiteral, RepairUnclosedArray}},
+		{"[\"a\tb\", \"\\u00", `["a\tb",""]`, []RepairKind{RepairControlCharacter, RepairUnterminatedString, RepairUnclosedArray}},
+		{"{\"d\":\"cut\n", `{"d":"cut"}`, []RepairKind{RepairUnterminatedString, RepairUnclosedObject}},
+		{"{\"a\":\"x\n{\"b\":1}\n", "{\"a\":\"x\"}\n{\"b\":1}", []RepairKind{RepairUnterminatedString, RepairUnclosedObject}},
+		{"[{\"a\":\"x\r\n[\"y\r\n", "[{\"a\":\"x\"}]\n[\"y\"]",
+			[]RepairKind{RepairUnterminatedString, RepairUnclosedObject, RepairUnclosedArray, RepairUnterminatedString, RepairUnclosedArray}},
+		{`[]`, `[]`, nil},
+	}
+	for _, tc := range cases {
+		var kinds []RepairKind
+		opts := *DefaultOptions
+		opts.Repair = true
+		opts.OnRepair = func(r Repair) { kinds = append(kinds, r.Kind) }
+		out, err := CompactToBuffer(strings.NewReader(tc.input), &opts)
+		if err != nil {
+			t.Fatalf("%q: CompactToBuffer failed: %v", tc.input, err)
+		}
+		if got := strings.TrimSuffix(string(out), "\n"); got != tc.want {
+			t.Fatalf("%q: expected %s, got %s", tc.input, tc.want, got)
+		}
+		for _, line := range strings.Split(strings.TrimSuffix(string(out), "\n"), "\n") {
+			if !json.Valid([]byte(line)) {
+				t.Fatalf("%q: output is not valid JSON: %s", tc.input, out)
+			}
+		}
+		if len(kinds) != len(tc.kinds) {
+			t.Fatalf("%q: expected repairs %v, got %v", tc.input, tc.kinds, kinds)
+		}
+		for i := range kinds {
+			if kinds[i] != tc.kinds[i] {
+				t.Fatalf("%q: expected repairs %v, got %v", tc.input, tc.kinds, kinds)
+			}
+		}
+	}
+}
+
+func TestRepair_PrettyAndSortedLayouts(t *testing.T) {
+	opts := *DefaultOptions
+	opts.Palette = "none"
+	opts.Repair = true
+	input := "{b:'x', a:[1,2,],"
+	var buf bytes.Buffer
+	if err := PrettyStream(&buf, strings.NewReader(input), &opts); err != nil {
+		t.Fatalf("PrettyStream failed: %v", err)
+	}
+	if want := "{\n  \"b\": \"x\",\n  \"a\": [\n    1,\n    2\n  ]\n}\n"; buf.String() != want {
+		t.Fatalf("unexpected output %q", buf.String())
+	}
+
+	buf.Reset()
+	opts.SortKeys = KeyOrderBytes
+	if err := PrettyStream(&buf, strings.NewReader(input), &opts); err != nil {
+		t.Fatalf("PrettyStream failed: %v", err)
+	}
+	if want := "{\n  \"a\": [\n    1,\n    2\n  ],\n  \"b\": \"x\"\n}\n"; buf.String() != want {
+		t.Fatalf("unexpected sorted output %q", buf.String())
+	}
+}
+
+func TestRepair_PositionsAndStrictness(t *testing.T) {
+	var got []Repair
+	opts := *DefaultOptions
+	opts.Repair = true
+	opts.OnRepair = func(r Repair) { got = append(got, r) }
+	if err := PrettyStream(io.Discard, strings.NewReader("{}\n[1,\n 2,]"), &opts); err != nil {
+		t.Fatalf("PrettyStream failed: %v", err)
+	}
+	if len(got) != 1 || got[0].Offset != 9 || got[0].Line != 3 || got[0].Column != 3 || got[0].Document != 2 {
+		t.Fatalf("unexpected repair %+v", got)
+	}
+	if s := got[0].String(); s != "repaired trailing comma at line 3, column 3 (offset 9, document 2)" {
+		t.Fatalf("unexpected description %q", s)
+	}
+
+	for _, input := range []string{`{"a" 1}`, `[1 2]`, `{@:1}`, `Nope`, `'a\q'`} {
+		var se *SyntaxError
+		if err := PrettyStream(io.Discard, strings.NewReader(input), &opts); !errors.As(err, &se) {
+			t.Fatalf("expected syntax error for %q, got %v", input, err)
+		}
+	}
+	opts.Repair = false
+	for _, input := range []string{`{'a':1}`, `[True]`, `[1,]`, `{a:1}`} {
+		var se *SyntaxError
+		if err := PrettyStream(io.Discard, strings.NewReader(input), &opts); !errors.As(err, &se) {
+			t.Fatalf("expected strict syntax error for %q, got %v", input, err)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package prettyx
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"sync"
+)
+
+// SlogHandlerOptions configure a SlogHandler.
+type SlogHandlerOptions struct {
+	// HandlerOptions are passed to the slog.JSONHandler that renders each
+	// record before it is formatted: Level, AddSource and ReplaceAttr work
+	// as they do there.
+	slog.HandlerOptions
+	// Options format the records. Nil uses DefaultOptions. When Log is
+	// LogOff, records are rendered with LogTree so the level, time and
+	// message are coloured as in the CLI's --log mode.
+	Options *Options
+	// Compact writes each record on one line instead of as a tree. It has no
+	// effect with LogConsole, which is always one line per record.
+	Compact bool
+}
+
+// SlogHandler is a slog.Handler that writes each record as a prettyx
+// document. Records are rendered by slog.JSONHandler, so attributes, groups
+// and ReplaceAttr behave exactly as with JSON logging, then formatted and
+// coloured. It is safe for concurrent use.
+type SlogHandler struct {
+	json slog.Handler
+	out  *slogOutput
+}
+
+// slogOutput is shared by a handler and those derived from it with
+// WithAttrs and WithGroup, so records are written one at a time.
+type slogOutput struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+	enc *Encoder
+}
+
+// NewSlogHandler returns a handler that writes records to w. Nil opts uses
+// the defaults.
+func NewSlogHandler(w io.Writer, opts *SlogHandlerOptions) *SlogHandler {
+	if opts == nil {
+		opts = &SlogHandlerOptions{}
+	}
+	format := DefaultOptions
+	if opts.Options != nil {
+		format = opts.Options
+	}
+	o := *format
+	if o.Log == LogOff {
+		o.Log = LogTree
+	}
+	out := &slogOutput{enc: NewEncoder(w, &o)}
+	out.enc.SetCompact(opts.Compact)
+	handlerOpts := opts.HandlerOptions
+	return &SlogHandler{
+		json: slog.NewJSONHandler(&out.buf, &handlerOpts),
+		out:  out,
+	}
+}
+
+// Enabled reports whether the handler handles records at level.
+func (h *SlogHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.json.Enabled(ctx, level)
+}
+
+// Handle formats the record and writes it.
+func (h *SlogHandler) Handle(ctx context.Context, r slog.Record) error {
+	h.out.mu.Lock()
+	defer h.out.mu.Unlock()
+	h.out.buf.Reset()
+	if err := h.json.Handle(ctx, r); err != nil {
+		return err
+	}
+	return h.out.enc.write(&h.out.buf)
+}
+
+// WithAttrs returns a handler whose records include attrs.
+func (h *SlogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &SlogHandler{json: h.json.WithAttrs(attrs), out: h.out}
+}
+
+// WithGroup returns a handler that qualifies later attributes with name.
+func (h *SlogHandler) WithGroup(name string) slog.Handler {
+	return &SlogHandler{json: h.json.WithGroup(name), out: h.out}
+}
@@ -0,0 +1,76 @@
+package prettyx
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"pkt.systems/prettyx/internal/ansi"
+)
+
+// dropTime removes the time attribute so records are deterministic.
+func dropTime(groups []string, a slog.Attr) slog.Attr {
+	if a.Key == slog.TimeKey && len(groups) == 0 {
+		return slog.Attr{}
+	}
+	return a
+}
+
+func TestSlogHandler(t *testing.T) {
+	var buf bytes.Buffer
+	opts := *DefaultOptions
+	opts.Palette = "none"
+	h := NewSlogHandler(&buf, &SlogHandlerOptions{
+		HandlerOptions: slog.HandlerOptions{ReplaceAttr: dropTime},
+		Options:        &opts,
+	})
+	logger := slog.New(h).With("svc", "api").WithGroup("req")
+	logger.Info("served", "status", 200, "path", "/x")
+	logger.Debug("hidden")
+	want := `{
+  "level": "INFO",
+  "msg": "served",
+  "svc": "api",
+  "req": {
+    "status": 200,
+    "path": "/x"
+  }
+}
+`
+	if buf.String() != want {
+		t.Fatalf("unexpected output\nexpected:\n%s\nactual:\n%s", want, buf.String())
+	}
+}
+
+func TestSlogHandlerLayouts(t *testing.T) {
+	var buf bytes.Buffer
+	h := NewSlogHandler(&buf, &SlogHandlerOptions{
+		HandlerOptions: slog.HandlerOptions{Level: slog.LevelDebug, ReplaceAttr: dropTime},
+		Options:        &Options{Palette: "none"},
+		Compact:        true,
+	})
+	slog.New(h).Debug("tick", "n", 1)
+	if got := buf.String(); got != `{"level":"DEBUG","msg":"tick","n":1}`+"\n" {
+		t.Fatalf("unexpected compact record %q", got)
+	}
+
+	buf.Reset()
+	h = NewSlogHandler(&buf, &SlogHandlerOptions{
+		HandlerOptions: slog.HandlerOptions{ReplaceAttr: dropTime},
+		Options:        &Options{Palette: "none", Log: LogConsole},
+	})
+	slog.New(h).Warn("disk", "free", "5%")
+	if got := buf.String(); got != "WARN  disk free=5%\n" {
+		t.Fatalf("unexpected console record %q", got)
+	}
+
+	buf.Reset()
+	h = NewSlogHandler(&buf, &SlogHandlerOptions{
+		Options: &Options{ForceColor: true, Palette: "default", Indent: "  "},
+	})
+	slog.New(h).Error("boom")
+	if !strings.Contains(buf.String(), ansi.Reset) || !strings.Contains(buf.String(), `"boom"`) {
+		t.Fatalf("expected coloured record, got %q", buf.String())
+	}
+}
@@ -0,0 +1,388 @@
+package prettyx
+
+import (
+	"bytes"
+	"io"
+	"slices"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"pkt.systems/prettyx/internal/ansi"
+)
+
+// KeyOrder selects how object keys are ordered in the output.
+type KeyOrder int
+
+const (
+	// KeyOrderInput keeps keys in input order. This is the default.
+	KeyOrderInput KeyOrder = iota
+	// KeyOrderBytes sorts keys by the bytes of their decoded UTF-8 form.
+	KeyOrderBytes
+	// KeyOrderUTF16 sorts keys by their decoded UTF-16 code units, as
+	// required by RFC 8785.
+	KeyOrderUTF16
+)
+
+// sortFrame buffers the members of one object while its keys are sorted.
+// Keys and values are captured compactly into buf; the formatter for the
+// real output replays them once the closing brace has been seen, so memory
+// is bounded by the largest object rather than the whole document.
+type sortFrame struct {
+	byteSink
+	members []sortMember
+	fmt     formatter
+}
+
+type sortMember struct {
+	rawStart, rawEnd     int
+	keyStart, keyEnd     int
+	valueStart, valueEnd int
+	role                 logRole
+}
+
+func (f *sortFrame) reset() {
+	f.buf = f.buf[:0]
+	f.members = f.members[:0]
+	f.fmt.reset(f, ColorPalette{}, nil, true)
+}
+
+func (f *sortFrame) clear() {
+	f.byteSink.clear()
+	f.members = f.members[:0]
+	f.fmt.clear()
+}
+
+func (p *parser) pushSortFrame() *sortFrame {
+	if p.sortDepth == len(p.sortFrames) {
+		p.sortFrames = append(p.sortFrames, &sortFrame{})
+	}
+	f := p.sortFrames[p.sortDepth]
+	p.sortDepth++
+	f.reset()
+	return f
+}
+
+func (p *parser) popSortFrame() {
+	p.sortDepth--
+	p.sortFrames[p.sortDepth].clear()
+}
+
+// parseSortedObject is parseObject for SortKeys: members are captured into a
+// sortFrame, sorted, then replayed into the real formatter.
+func (p *parser) parseSortedObject(depth int) error {
+	out := p.formatter
+	frame := p.pushSortFrame()
+	p.formatter = &frame.fmt
+	err := p.captureObjectMembers(frame)
+	p.formatter = out
+	if err == nil {
+		err = p.emitSortedObject(frame, depth)
+	}
+	p.popSortFrame()
+	return err
+}
+
+func (p *parser) captureObjectMembers(frame *sortFrame) error {
+	b, err := p.readNonSpace()
+	if err != nil {
+		if p.repairEOF(err, RepairUnclosedObject) {
+			return nil
+		}
+		return err
+	}
+	if b == '}' {
+		return nil
+	}
+	for {
+		if b != '"' && !p.extendedKeys() {
+			return p.errorf("expected object key")
+		}
+		var m sortMember
+		if err := p.captureKey(frame, &m, b); err != nil {
+			return err
+		}
+		if p.trackPath {
+			p.enterMember(frame.buf[m.keyStart:m.keyEnd])
+		}
+		if err := p.expectColon(); err != nil && !(p.repair && err == io.EOF) {
+			return err
+		}
+		m.valueStart = len(frame.buf)
+		if err := p.parseValue(0); err != nil {
+			return err
+		}
+		if p.trackPath {
+			p.leave()
+		}
+		m.valueEnd = len(frame.buf)
+		frame.members = append(frame.members, m)
+
+		b, err = p.readNonSpace()
+		if err != nil {
+			if p.repairEOF(err, RepairUnclosedObject) {
+				return nil
+			}
+			return err
+		}
+		switch b {
+		case ',':
+			b, err = p.readAfterComma('}')
+			if err == errRepairClose {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+		case '}':
+			return nil
+		default:
+			return p.errorf("expected ',' or '}'")
+		}
+	}
+}
+
+// captureKey appends the key token as it will be written followed by its
+// decoded form. Canonical, repaired and JSON5 output re-escape keys minimally;
+// otherwise the token is kept as written in the input.
+func (p *parser) captureKey(frame *sortFrame, m *sortMember, first byte) error {
+	m.rawStart = len(frame.buf)
+	if p.canonical || p.extendedKeys() {
+		var key []byte
+		var err error
+		if p.extendedKeys() {
+			key, err = p.readRepairKey(first)
+		} else {
+			key, err = p.readStringValue()
+		}
+		if err != nil {
+			return err
+		}
+		if p.canonical && !utf8.Valid(key) {
+			return p.errorf("invalid UTF-8 in string")
+		}
+		p.scratch = appendQuotedBytes(p.scratch[:0], key)
+		frame.buf = append(frame.buf, p.scratch...)
+		m.rawEnd = len(frame.buf)
+		m.keyStart = m.rawEnd
+		frame.buf = append(frame.buf, key...)
+		m.keyEnd = len(frame.buf)
+		return nil
+	}
+	if err := p.copyStringToken(""); err != nil {
+		return err
+	}
+	m.rawEnd = len(frame.buf)
+	m.keyStart = m.rawEnd
+	frame.buf = appendUnquoted(frame.buf, frame.buf[m.rawStart+1:m.rawEnd-1])
+	m.keyEnd = len(frame.buf)
+	return nil
+}
+
+func (p *parser) emitSortedObject(frame *sortFrame, depth int) error {
+	p.sortMembers(frame)
+	if p.canonical {
+		// RFC 8785 requires I-JSON, which forbids duplicate member names;
+		// sorting has made any duplicates neighbours.
+		buf := frame.buf
+		for i := 1; i < len(frame.members); i++ {
+			a, b := frame.members[i-1], frame.members[i]
+			if bytes.Equal(buf[a.keyStart:a.keyEnd], buf[b.keyStart:b.keyEnd]) {
+				return p.errorf("duplicate key %q", buf[b.keyStart:b.keyEnd])
+			}
+		}
+	}
+	return p.emitCapturedObject(frame, depth)
+}
+
+// sortMembers orders the captured members by p.sortKeys. Input order leaves
+// them untouched.
+func (p *parser) sortMembers(frame *sortFrame) {
+	buf := frame.buf
+	switch p.sortKeys {
+	case KeyOrderUTF16:
+		slices.SortStableFunc(frame.members, func(a, b sortMember) int {
+			return compareUTF16(buf[a.keyStart:a.keyEnd], buf[b.keyStart:b.keyEnd])
+		})
+	case KeyOrderBytes:
+		slices.SortStableFunc(frame.members, func(a, b sortMember) int {
+			return bytes.Compare(buf[a.keyStart:a.keyEnd], buf[b.keyStart:b.keyEnd])
+		})
+	}
+}
+
+// emitCapturedObject writes the captured members in their current order with
+// parseObject's layout.
+func (p *parser) emitCapturedObject(frame *sortFrame, depth int) error {
+	buf := frame.buf
+	f := p.formatter
+	if err := f.writeBracket('{'); err != nil {
+		return err
+	}
+	if len(frame.members) == 0 {
+		return f.writeBracket('}')
+	}
+	innerDepth := depth + 1
+	multiline := false
+	if !f.compact {
+		if err := f.newline(innerDepth); err != nil {
+			return err
+		}
+		multiline = true
+	}
+	for i, m := range frame.members {
+		if i > 0 {
+			if f.compact {
+				if err := f.writePunctuation(","); err != nil {
+					return err
+				}
+			} else {
+				broke, err := p.writeSeparator(innerDepth)
+				if err != nil {
+					return err
+				}
+				if broke {
+					multiline = true
+				}
+			}
+		}
+		keyStyle := f.pal.Key
+		if m.role == logRoleMessage && f.pal.MessageKey != "" {
+			keyStyle = f.pal.MessageKey
+		}
+		if err := f.writeANSI(keyStyle); err != nil {
+			return err
+		}
+		if err := f.writeBytes(buf[m.rawStart:m.rawEnd]); err != nil {
+			return err
+		}
+		if keyStyle != "" {
+			if err := f.writeANSI(ansi.Reset); err != nil {
+				return err
+			}
+		}
+		sep := ": "
+		if f.compact {
+			sep = ":"
+		}
+		if err := f.writePunctuation(sep); err != nil {
+			return err
+		}
+		value := buf[m.valueStart:m.valueEnd]
+		if m.role != logRoleNone && isScalarToken(value) {
+			if err := f.writeStyledBytes(p.logStyle(m.role, value), value); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := p.replayValue(value, innerDepth); err != nil {
+			return err
+		}
+	}
+	if !f.compact && multiline {
+		if err := f.newline(depth); err != nil {
+			return err
+		}
+	}
+	return f.writeBracket('}')
+}
+
+// replayValue formats a captured value into the current formatter. Captured
+// values are already unwrapped and sorted, so neither is repeated here.
+func (p *parser) replayValue(src []byte, depth int) error {
+	v := acquireParser()
+	v.sliceReader.Reset(src)
+	v.scanner.Reset(&v.sliceReader)
+	v.formatter = p.formatter
+	v.unwrapDepth = 0
+	v.sortKeys = KeyOrderInput
+	err := v.parseValue(depth)
+	releaseParser(v)
+	return err
+}
+
+// appendUnquoted appends the decoded form of the body of a string token that
+// has already been validated.
+func appendUnquoted(dst []byte, src []byte) []byte {
+	for i := 0; i < len(src); i++ {
+		c := src[i]
+		if c != '\\' {
+			dst = append(dst, c)
+			continue
+		}
+		i++
+		switch src[i] {
+		case 'b':
+			dst = append(dst, '\b')
+		case 'f':
+			dst = append(dst, '\f')
+		case 'n':
+			dst = append(dst, '\n')
+		case 'r':
+			dst = append(dst, '\r')
+		case 't':
+			dst = append(dst, '\t')
+		case 'u':
+			r := decodeHex4(src[i+1:])
+			i += 4
+			if utf16.IsSurrogate(r) && i+6 < len(src) && src[i+1] == '\\' && src[i+2] == 'u' {
+				if r2 := decodeHex4(src[i+3:]); r2 >= 0xDC00 && r2 <= 0xDFFF {
+					r = utf16.DecodeRune(r, r2)
+					i += 6
+				}
+			}
+			dst = utf8.AppendRune(dst, r)
+		default:
+			dst = append(dst, src[i])
+		}
+	}
+	return dst
+}
+
+func decodeHex4(b []byte) rune {
+	var r rune
+	for i := 0; i < 4; i++ {
+		r = r<<4 | rune(fromHex(b[i]))
+	}
+	return r
+}
+
+// compareUTF16 orders two UTF-8 strings by their UTF-16 code units. Code
+// points only order differently from UTF-16 when a supplementary character
+// (encoded as a surrogate pair) meets one in U+E000..U+FFFF.
+func compareUTF16(a, b []byte) int {
+	for len(a) > 0 && len(b) > 0 {
+		ra, na := utf8.DecodeRune(a)
+		rb, nb := utf8.DecodeRune(b)
+		a, b = a[na:], b[nb:]
+		if ra == rb {
+			continue
+		}
+		ua, la := utf16Units(ra)
+		ub, lb := utf16Units(rb)
+		if ua != ub {
+			if ua < ub {
+				return -1
+			}
+			return 1
+		}
+		if la < lb {
+			return -1
+		}
+		return 1
+	}
+	switch {
+	case len(a) == len(b):
+		return 0
+	case len(a) == 0:
+		return -1
+	default:
+		return 1
+	}
+}
+
+func utf16Units(r rune) (rune, rune) {
+	if r < 0x10000 {
+		return r, 0
+	}
+	return utf16.EncodeRune(r)
+}
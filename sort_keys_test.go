@@ -0,0 +1,135 @@
+package prettyx
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+const (
+	sortKeysInput  = `{"b":1,"a":{"z":[{"y":1,"x":2}],"é":1,"e":"{\"q\":1,\"p\":2}"},"😀":1,"ｚ":2,"A":[]}`
+	sortKeysBytes  = `{"A":[],"a":{"e":{"p":2,"q":1},"z":[{"x":2,"y":1}],"é":1},"b":1,"ｚ":2,"😀":1}`
+	sortKeysUTF16  = `{"A":[],"a":{"e":{"p":2,"q":1},"z":[{"x":2,"y":1}],"é":1},"b":1,"😀":1,"ｚ":2}`
+	sortKeysNoWrap = `{"A":[],"a":{"e":"{\"q\":1,\"p\":2}","z":[{"x":2,"y":1}],"é":1},"b":1,"ｚ":2,"😀":1}`
+)
+
+func TestSortKeys_MatchesPresortedInput(t *testing.T) {
+	cases := []struct {
+		order    KeyOrder
+		unwrap   bool
+		expected string
+	}{
+		{KeyOrderBytes, true, sortKeysBytes},
+		{KeyOrderUTF16, true, sortKeysUTF16},
+		{KeyOrderBytes, false, sortKeysNoWrap},
+	}
+	for _, tc := range cases {
+		for _, semi := range []bool{false, true} {
+			opts := *DefaultOptions
+			opts.Palette = "none"
+			opts.SemiCompact = semi
+			opts.Width = 20
+			opts.Unwrap = tc.unwrap
+
+			want, err := Pretty([]byte(tc.expected), &opts)
+			if err != nil {
+				t.Fatalf("Pretty presorted failed: %v", err)
+			}
+			opts.SortKeys = tc.order
+			got, err := Pretty([]byte(sortKeysInput), &opts)
+			if err != nil {
+				t.Fatalf("Pretty sorted failed: %v", err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Fatalf("order=%d semi=%v unwrap=%v\nexpected:\n%s\nactual:\n%s", tc.order, semi, tc.unwrap, want, got)
+			}
+		}
+
+		opts := *DefaultOptions
+		opts.Unwrap = tc.unwrap
+		want, err := CompactToBuffer(strings.NewReader(tc.expected), &opts)
+		if err != nil {
+			t.Fatalf("CompactToBuffer presorted failed: %v", err)
+		}
+		opts.SortKeys = tc.order
+		opts.Prefix = "> "
+		got, err := CompactToBuffer(strings.NewReader(sortKeysInput+"\n[]"), &opts)
+		if err != nil {
+			t.Fatalf("CompactToBuffer sorted failed: %v", err)
+		}
+		if string(got) != string(want)+"[]\n" {
+			t.Fatalf("order=%d compact\nexpected:\n%s[]\nactual:\n%s", tc.order, want, got)
+		}
+	}
+}
+
+func TestSortKeys_ColorAndDuplicates(t *testing.T) {
+	opts := *DefaultOptions
+	opts.ForceColor = true
+	opts.SortKeys = KeyOrderBytes
+
+	got, err := Pretty([]byte(`{"b":1,"a":2,"b":0}`), &opts)
+	if err != nil {
+		t.Fatalf("Pretty failed: %v", err)
+	}
+	opts.SortKeys = KeyOrderInput
+	want, err := Pretty([]byte(`{"a":2,"b":1,"b":0}`), &opts)
+	if err != nil {
+		t.Fatalf("Pretty failed: %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatalf("expected stable coloured output\nexpected: %q\nactual:   %q", want, got)
+	}
+}
+
+func TestSortKeys_Errors(t *testing.T) {
+	opts := *DefaultOptions
+	opts.SortKeys = KeyOrderBytes
+	for _, input := range []string{`{"a":1,}`, `{"a" 1}`, `{"a":1 "b":2}`, `{"a":[1,}`, `{"a":`, `{1:2}`} {
+		var se *SyntaxError
+		if err := PrettyStream(io.Discard, strings.NewReader(input), &opts); !errors.As(err, &se) {
+			t.Fatalf("expected syntax error for %q, got %v", input, err)
+		}
+		if err := CompactTo(io.Discard, strings.NewReader(input), &opts); !errors.As(err, &se) {
+			t.Fatalf("expected compact syntax error for %q, got %v", input, err)
+		}
+	}
+	if err := PrettyStream(errWriter{}, strings.NewReader(`{"b":1,"a":2}`), &opts); err == nil {
+		t.Fatalf("expected writer error")
+	}
+	if err := CompactTo(errWriter{}, strings.NewReader(`{"b":1,"a":2}`), &opts); err == nil {
+		t.Fatalf("expected compact writer error")
+	}
+}
+
+func TestCompareUTF16(t *testing.T) {
+	cases := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"a", "", 1},
+		{"", "a", -1},
+		{"ab", "ac", -1},
+		{"\U0001F600", "ｚ", -1},
+		{"ｚ", "\U0001F600", 1},
+		{"\U0001F600", "\U0001F601", -1},
+		{"\U0001F601", "\U0001F600", 1},
+		{"é", "e", 1},
+	}
+	for _, tc := range cases {
+		if got := compareUTF16([]byte(tc.a), []byte(tc.b)); got != tc.want {
+			t.Fatalf("compareUTF16(%q, %q) = %d, want %d", tc.a, tc.b, got, tc.want)
+		}
+	}
+}
+
+func TestAppendUnquoted(t *testing.T) {
+	got := appendUnquoted(nil, []byte(`a\"\\\/\b\f\n\r\té😀\ud83d`))
+	want := "a\"\\/\b\f\n\r\té\U0001F600�"
+	if string(got) != want {
+		t.Fatalf("unexpected decode %q", got)
+	}
+}
@@ -3,7 +3,6 @@ package prettyx
 import (
 	"bytes"
 	"errors"
-	"fmt"
 	"io"
 	"unicode/utf16"
 	"unicode/utf8"
@@ -15,23 +14,98 @@ func streamPretty(w io.Writer, r io.Reader, opts *Options, pal ColorPalette, com
 	p := acquireParser()
 	defer releaseParser(p)
 	p.reset(r, w, opts, pal, compact)
+	return p.stream()
+}
 
+// stream formats every document in the input, one per line.
+func (p *parser) stream() error {
+	if p.passthrough {
+		return p.streamLines()
+	}
+	if p.recover != nil {
+		return p.streamRecover()
+	}
 	for {
-		err := p.scanner.skipSpace()
+		err := p.skipSpace()
 		if err == io.EOF {
-			return nil
+			return p.writeComments(0, false)
 		}
 		if err != nil {
 			return err
 		}
-		if err := p.parseValue(0); err != nil {
+		p.doc++
+		err = p.parseDocument()
+		p.scanner.unfence()
+		if err != nil {
+			if err == io.EOF {
+				return p.errorfNext("unexpected end of input")
+			}
 			return err
 		}
-		if err := p.formatter.writeByte('\n'); err != nil {
-			return err
+	}
+}
+
+// parseDocument formats the next document, or with a path set, the values it
+// selects from the document.
+func (p *parser) parseDocument() error {
+	if p.trackPath {
+		p.pathKeys = p.pathKeys[:0]
+		p.pathSteps = p.pathSteps[:0]
+		p.redactAt = -1
+	}
+	if len(p.path) > 0 {
+		return p.selectDocument()
+	}
+	b, err := p.readNonSpace()
+	if err != nil {
+		return err
+	}
+	return p.writeDocument(b)
+}
+
+// writeDocument formats the value starting with first as a document of its
+// own: followed by a newline, or flattened.
+func (p *parser) writeDocument(first byte) error {
+	switch {
+	case p.flatten:
+		return p.flattenDocument(first)
+	case p.output == OutputYAML:
+		return p.yamlDocument(first)
+	}
+	if err := p.parseValueWithFirst(0, first); err != nil {
+		return err
+	}
+	if err := p.formatter.writeByte('\n'); err != nil {
+		return err
+	}
+	p.formatter.lineLen = 0
+	return nil
+}
+
+// replayDocument is for renderers that need whole values: the value starting
+// with first is formatted compactly into memory, sorted and unwrapped as
+// configured, and emit is called with a parser that reads it back.
+func (p *parser) replayDocument(first byte, emit func(v *parser, first byte) error) error {
+	out := p.formatter
+	frame := p.pushSortFrame()
+	p.formatter = &frame.fmt
+	err := p.parseValueWithFirst(0, first)
+	p.formatter = out
+	if err == nil {
+		v := acquireParser()
+		v.sliceReader.Reset(frame.buf)
+		v.scanner.Reset(&v.sliceReader)
+		v.formatter = out
+		v.doc = p.doc
+		v.flatPath = append(v.flatPath[:0], p.flatPath...)
+		var b byte
+		if b, err = v.readNonSpace(); err == nil {
+			err = emit(v, b)
 		}
-		p.formatter.lineLen = 0
+		releaseParser(v)
 	}
+	p.popSortFrame()
+	return err
 }
 
 type formatter struct {
@@ -167,6 +241,23 @@ func (f *formatter) writeStyledString(style string, s string) error {
 	return nil
 }
 
+func (f *formatter) writeStyledBytes(style string, b []byte) error {
+	if style != "" {
+		if err := f.writeANSI(style); err != nil {
+			return err
+		}
+	}
+	if err := f.writeBytes(b); err != nil {
+		return err
+	}
+	if style != "" {
+		if err := f.writeANSI(ansi.Reset); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (f *formatter) writeStyledByte(style string, b byte) error {
 	if style != "" {
 		if err := f.writeANSI(style); err != nil {
@@ -228,54 +319,198 @@ func (f *formatter) writeLiteral(lit string, style string) error {
 	return f.writeStyledString(style, lit)
 }
 
+// byteSink collects formatter output in memory.
+type byteSink struct {
+	buf []byte
+}
+
+func (s *byteSink) Write(p []byte) (int, error) {
+	s.buf = append(s.buf, p...)
+	return len(p), nil
+}
+
+func (s *byteSink) WriteString(str string) (int, error) {
+	s.buf = append(s.buf, str...)
+	return len(str), nil
+}
+
+func (s *byteSink) WriteByte(b byte) error {
+	s.buf = append(s.buf, b)
+	return nil
+}
+
+func (s *byteSink) clear() {
+	if cap(s.buf) > maxScratchCap {
+		s.buf = nil
+	} else {
+		s.buf = s.buf[:0]
+	}
+}
+
 type parser struct {
 	scanner     scanner
 	formatter   *formatter
 	fmt         formatter
+	skipFmt     formatter
+	path        []pathSegment
+	flatten     bool
+	output      OutputFormat
+	written     int
+	flatPath    []byte
 	unwrapDepth int
+	unwrap      unwrapPolicy
+	unwrapBufs  [2][]byte
 	silentErr   bool
+	doc         int
+	sortKeys    KeyOrder
+	canonical   bool
+	log         LogLayout
+	logKeys     *LogKeys
+	passthrough bool
+	repair      bool
+	onRepair    func(Repair)
+	dialect     Dialect
+	keepComment bool
+	commentBuf  []byte
+	commentEnds []int
+	recover     func(*SyntaxError)
+	rec         recoverReader
+	docOut      byteSink
+	out         io.Writer
+	sortFrames  []*sortFrame
+	sortDepth   int
+	lineBuf     []byte
+	spans       []mixedSpan
+	spanStack   []int
 	scratch     []byte
 	decodedBuf  []byte
 	sliceReader bytes.Reader
+	redact      *Redactor
+	trackPath   bool
+	pathKeys    []byte
+	pathSteps   []pathStep
+	redactAt    int
 }
 
 func (p *parser) reset(r io.Reader, w io.Writer, opts *Options, pal ColorPalette, compact bool) {
+	p.recover = nil
+	p.out = nil
+	if opts != nil && opts.Recover != nil && !opts.Passthrough {
+		p.recover = opts.Recover
+		p.out = w
+		p.rec.reset(r)
+		r = &p.rec
+		w = &p.docOut
+	}
 	p.scanner.Reset(r)
 	p.formatter = &p.fmt
 	p.fmt.reset(w, pal, opts, compact)
+	p.skipFmt.reset(io.Discard, ColorPalette{}, nil, true)
+	p.path = nil
+	p.flatten = false
+	p.output = OutputJSON
+	p.written = 0
 	p.unwrapDepth = 0
 	p.silentErr = false
-	if opts != nil && opts.Unwrap {
-		p.unwrapDepth = MaxNestedJSONDepth
-		if p.unwrapDepth <= 0 {
-			p.unwrapDepth = 1
+	p.doc = 0
+	p.canonical = false
+	p.sortKeys = KeyOrderInput
+	p.log = LogOff
+	p.logKeys = DefaultLogKeys
+	p.passthrough = false
+	p.repair = false
+	p.onRepair = nil
+	p.dialect = DialectJSON
+	p.keepComment = false
+	p.commentBuf = p.commentBuf[:0]
+	p.commentEnds = p.commentEnds[:0]
+	p.redact = nil
+	p.unwrap = unwrapPolicy{}
+	p.pathKeys = p.pathKeys[:0]
+	p.pathSteps = p.pathSteps[:0]
+	p.redactAt = -1
+	if opts != nil {
+		p.redact = opts.Redact
+		p.passthrough = opts.Passthrough
+		p.repair = opts.Repair
+		p.onRepair = opts.OnRepair
+		p.dialect = opts.Dialect
+		if opts.Path != nil {
+			p.path = opts.Path.segs
+		}
+		p.flatten = opts.Flatten
+		p.output = opts.Output
+		plain := !p.flatten && p.output == OutputJSON
+		p.keepComment = opts.KeepComments && opts.Dialect != DialectJSON && !compact &&
+			opts.SortKeys == KeyOrderInput && opts.Log == LogOff && len(p.path) == 0 && plain
+		p.sortKeys = opts.SortKeys
+		if plain {
+			p.log = opts.Log
+		}
+		if opts.LogKeys != nil {
+			p.logKeys = opts.LogKeys
 		}
 	}
+	if opts != nil && opts.Unwrap {
+		p.unwrapDepth = unwrapDepth(opts)
+		p.unwrap = newUnwrapPolicy(opts)
+	}
+	p.trackPath = p.redact.tracksPath() || p.unwrap.tracksPath()
 }
 
 var errInvalidJSON = errors.New("json: invalid")
 
+// errorf reports a syntax error at the byte the scanner consumed last.
 func (p *parser) errorf(format string, args ...any) error {
 	if p != nil && p.silentErr {
 		return errInvalidJSON
 	}
-	return fmt.Errorf(format, args...)
+	return newSyntaxError(&p.scanner, p.scanner.pos-1, p.doc, format, args...)
+}
+
+// errorfNext reports a syntax error at the next unread byte, for checks made
+// on a peeked byte or at end of input.
+func (p *parser) errorfNext(format string, args ...any) error {
+	if p != nil && p.silentErr {
+		return errInvalidJSON
+	}
+	return newSyntaxError(&p.scanner, p.scanner.pos, p.doc, format, args...)
 }
 
 func (p *parser) parseValue(depth int) error {
-	b, err := p.scanner.readNonSpace()
+	b, err := p.readNonSpace()
 	if err != nil {
+		if p.repairEOF(err, RepairMissingValue) {
+			if err := p.formatter.ensureLineStart(depth); err != nil {
+				return err
+			}
+			return p.formatter.writeLiteral("null", p.formatter.pal.Null)
+		}
 		return err
 	}
 	return p.parseValueWithFirst(depth, b)
 }
 
 func (p *parser) parseValueWithFirst(depth int, first byte) error {
+	if len(p.commentEnds) > 0 {
+		if err := p.writeComments(depth, false); err != nil {
+			return err
+		}
+	}
 	if err := p.formatter.ensureLineStart(depth); err != nil {
 		return err
 	}
+	if p.redactPending() {
+		return p.writeRedactedValue(first)
+	}
 	switch first {
 	case '{':
+		if p.log != LogOff && depth == 0 && p.sortDepth == 0 {
+			return p.parseLogRecord(depth)
+		}
+		if p.sortKeys != KeyOrderInput {
+			return p.parseSortedObject(depth)
+		}
 		return p.parseObject(depth)
 	case '[':
 		return p.parseArray(depth)
@@ -286,7 +521,10 @@ func (p *parser) parseValueWithFirst(depth int, first byte) error {
 	case '-', '0', '1', '2', '3', '4', '5', '6', '7', '8', '9':
 		return p.parseNumber(first)
 	default:
-		return p.errorf("json: unexpected character %q", first)
+		if p.repair || p.dialect == DialectJSON5 {
+			return p.parseExtendedValue(first)
+		}
+		return p.errorf("unexpected character %q", first)
 	}
 }
 
@@ -296,12 +534,15 @@ func (p *parser) parseObject(depth int) error {
 	}
 	innerDepth := depth + 1
 
-	b, err := p.scanner.readNonSpace()
+	b, err := p.readNonSpace()
 	if err != nil {
+		if p.repairEOF(err, RepairUnclosedObject) {
+			return p.formatter.writeBracket('}')
+		}
 		return err
 	}
 	if b == '}' {
-		return p.formatter.writeBracket('}')
+		return p.closeContainer(depth, false, '}')
 	}
 
 	multiline := false
@@ -313,13 +554,28 @@ func (p *parser) parseObject(depth int) error {
 	}
 
 	for {
-		if b != '"' {
-			return p.errorf("json: expected object key")
+		if len(p.commentEnds) > 0 {
+			if err := p.writeComments(innerDepth, true); err != nil {
+				return err
+			}
 		}
-		if err := p.copyStringToken(p.formatter.pal.Key); err != nil {
-			return err
+		if p.trackPath {
+			if err := p.writeMemberKey(b); err != nil {
+				return err
+			}
+		} else if p.extendedKeys() {
+			if err := p.writeRepairKey(b); err != nil {
+				return err
+			}
+		} else {
+			if b != '"' {
+				return p.errorf("expected object key")
+			}
+			if err := p.copyStringToken(p.formatter.pal.Key); err != nil {
+				return err
+			}
 		}
-		if err := p.expectColon(); err != nil {
+		if err := p.expectColon(); err != nil && !(p.repair && err == io.EOF) {
 			return err
 		}
 		if p.formatter.compact {
@@ -334,12 +590,25 @@ func (p *parser) parseObject(depth int) error {
 		if err := p.parseValue(innerDepth); err != nil {
 			return err
 		}
-		b, err = p.scanner.readNonSpace()
+		if p.trackPath {
+			p.leave()
+		}
+		b, err = p.readNonSpace()
 		if err != nil {
+			if p.repairEOF(err, RepairUnclosedObject) {
+				return p.closeContainer(depth, multiline, '}')
+			}
 			return err
 		}
 		switch b {
 		case ',':
+			b, err = p.readAfterComma('}')
+			if err != nil {
+				if err == errRepairClose {
+					return p.closeContainer(depth, multiline, '}')
+				}
+				return err
+			}
 			if p.formatter.compact {
 				if err := p.formatter.writePunctuation(","); err != nil {
 					return err
@@ -353,20 +622,11 @@ func (p *parser) parseObject(depth int) error {
 					multiline = true
 				}
 			}
-			b, err = p.scanner.readNonSpace()
-			if err != nil {
-				return err
-			}
 			continue
 		case '}':
-			if !p.formatter.compact && multiline {
-				if err := p.formatter.newline(depth); err != nil {
-					return err
-				}
-			}
-			return p.formatter.writeBracket('}')
+			return p.closeContainer(depth, multiline, '}')
 		default:
-			return p.errorf("json: expected ',' or '}'")
+			return p.errorf("expected ',' or '}'")
 		}
 	}
 }
@@ -377,12 +637,15 @@ func (p *parser) parseArray(depth int) error {
 	}
 	innerDepth := depth + 1
 
-	b, err := p.scanner.readNonSpace()
+	b, err := p.readNonSpace()
 	if err != nil {
+		if p.repairEOF(err, RepairUnclosedArray) {
+			return p.formatter.writeBracket(']')
+		}
 		return err
 	}
 	if b == ']' {
-		return p.formatter.writeBracket(']')
+		return p.closeContainer(depth, false, ']')
 	}
 
 	multiline := false
@@ -399,16 +662,32 @@ func (p *parser) parseArray(depth int) error {
 		}
 	}
 
-	for {
+	for i := 0; ; i++ {
+		if p.trackPath {
+			p.enterElement(i)
+		}
 		if err := p.parseValueWithFirst(innerDepth, b); err != nil {
 			return err
 		}
-		b, err = p.scanner.readNonSpace()
+		if p.trackPath {
+			p.leave()
+		}
+		b, err = p.readNonSpace()
 		if err != nil {
+			if p.repairEOF(err, RepairUnclosedArray) {
+				return p.closeContainer(depth, multiline, ']')
+			}
 			return err
 		}
 		switch b {
 		case ',':
+			b, err = p.readAfterComma(']')
+			if err != nil {
+				if err == errRepairClose {
+					return p.closeContainer(depth, multiline, ']')
+				}
+				return err
+			}
 			if p.formatter.compact {
 				if err := p.formatter.writePunctuation(","); err != nil {
 					return err
@@ -422,24 +701,32 @@ func (p *parser) parseArray(depth int) error {
 					multiline = true
 				}
 			}
-			b, err = p.scanner.readNonSpace()
-			if err != nil {
-				return err
-			}
 			continue
 		case ']':
-			if !p.formatter.compact && multiline {
-				if err := p.formatter.newline(depth); err != nil {
-					return err
-				}
-			}
-			return p.formatter.writeBracket(']')
+			return p.closeContainer(depth, multiline, ']')
 		default:
-			return p.errorf("json: expected ',' or ']'")
+			return p.errorf("expected ',' or ']'")
 		}
 	}
 }
 
+// closeContainer writes the closing bracket of an object or array, on its own
+// line when the container was broken across lines.
+func (p *parser) closeContainer(depth int, multiline bool, bracket byte) error {
+	if len(p.commentEnds) > 0 {
+		if err := p.writeClosingComments(depth + 1); err != nil {
+			return err
+		}
+		multiline = true
+	}
+	if !p.formatter.compact && multiline {
+		if err := p.formatter.newline(depth); err != nil {
+			return err
+		}
+	}
+	return p.formatter.writeBracket(bracket)
+}
+
 func (p *parser) writeSeparator(depth int) (bool, error) {
 	if !p.formatter.semiCompact {
 		if err := p.formatter.writePunctuation(","); err != nil {
@@ -475,7 +762,10 @@ func (p *parser) writeSeparator(depth int) (bool, error) {
 }
 
 func (p *parser) parseStringValue(depth int) error {
-	if p.unwrapDepth <= 0 {
+	if p.unwrapDepth <= 0 && !p.canonical && !p.repair && p.dialect != DialectJSON5 {
+		if p.redact != nil && len(p.redact.values) > 0 {
+			return p.writeRedactedString()
+		}
 		return p.copyStringToken(p.formatter.pal.String)
 	}
 
@@ -483,9 +773,14 @@ func (p *parser) parseStringValue(depth int) error {
 	if err != nil {
 		return err
 	}
-	trimmed := trimSpaceBytes(val)
-	if looksLikeJSONBytes(trimmed) {
-		ok, err := p.tryUnwrapBytes(trimmed, depth)
+	if p.canonical && !utf8.Valid(val) {
+		return p.errorf("invalid UTF-8 in string")
+	}
+	if p.unwrapDepth <= 0 {
+		return p.writeQuotedBytes(p.redactString(val), p.formatter.pal.String)
+	}
+	if src, ok := p.embeddedJSON(trimSpaceBytes(val)); ok {
+		ok, err := p.tryUnwrapBytes(src, depth)
 		if err != nil {
 			return err
 		}
@@ -493,7 +788,7 @@ func (p *parser) parseStringValue(depth int) error {
 			return nil
 		}
 	}
-	return p.writeQuotedBytes(val, p.formatter.pal.String)
+	return p.writeQuotedBytes(p.redactString(val), p.formatter.pal.String)
 }
 
 func (p *parser) tryUnwrapBytes(src []byte, depth int) (bool, error) {
@@ -519,7 +814,10 @@ func (p *parser) tryUnwrapBytes(src []byte, depth int) (bool, error) {
 	v.scanner.Reset(&v.sliceReader)
 	v.formatter = p.formatter
 	v.unwrapDepth = p.unwrapDepth - 1
+	v.sortKeys = p.sortKeys
+	v.canonical = p.canonical
 	v.silentErr = false
+	v.inheritPath(p)
 	err := v.parseValue(depth)
 	releaseParser(v)
 	if err != nil {
@@ -543,7 +841,7 @@ func (p *parser) copyStringToken(style string) error {
 			return err
 		}
 		if b < 0x20 {
-			return p.errorf("json: invalid control character in string")
+			return p.errorf("invalid control character in string")
 		}
 		if err := p.formatter.writeByte(b); err != nil {
 			return err
@@ -572,11 +870,11 @@ func (p *parser) copyStringToken(style string) error {
 						return err
 					}
 					if !isHex(ch) {
-						return p.errorf("json: invalid unicode escape")
+						return p.errorf("invalid unicode escape")
 					}
 				}
 			default:
-				return p.errorf("json: invalid escape sequence")
+				return p.errorf("invalid escape sequence")
 			}
 		}
 	}
@@ -607,17 +905,33 @@ func (p *parser) writeQuotedBytes(val []byte, style string) error {
 }
 
 func (p *parser) readStringValue() ([]byte, error) {
+	return p.readQuoted('"')
+}
+
+// readQuoted decodes a string whose opening quote has been consumed. Only
+// repair mode calls it with a quote other than '"'.
+func (p *parser) readQuoted(quote byte) ([]byte, error) {
 	p.decodedBuf = p.decodedBuf[:0]
 	for {
 		b, err := p.scanner.readByte()
 		if err != nil {
+			if p.repairEOF(err, RepairUnterminatedString) {
+				return p.decodedBuf, nil
+			}
 			return nil, err
 		}
-		if b == '"' {
+		if b == quote {
+			return p.decodedBuf, nil
+		}
+		if p.repair && p.truncatedAt(b) {
+			p.reportRepair(RepairUnterminatedString, p.scanner.pos)
 			return p.decodedBuf, nil
 		}
 		if b < 0x20 {
-			return nil, p.errorf("json: invalid control character in string")
+			if !p.repair {
+				return nil, p.errorf("invalid control character in string")
+			}
+			p.reportRepair(RepairControlCharacter, p.scanner.pos-1)
 		}
 		if b != '\\' {
 			p.decodedBuf = append(p.decodedBuf, b)
@@ -625,11 +939,19 @@ func (p *parser) readStringValue() ([]byte, error) {
 		}
 		esc, err := p.scanner.readByte()
 		if err != nil {
+			if p.repairEOF(err, RepairUnterminatedString) {
+				return p.decodedBuf, nil
+			}
 			return nil, err
 		}
 		switch esc {
 		case '"', '\\', '/':
 			p.decodedBuf = append(p.decodedBuf, esc)
+		case '\'':
+			if !p.repair && p.dialect != DialectJSON5 {
+				return nil, p.errorf("invalid escape sequence")
+			}
+			p.decodedBuf = append(p.decodedBuf, esc)
 		case 'b':
 			p.decodedBuf = append(p.decodedBuf, '\b')
 		case 'f':
@@ -643,11 +965,22 @@ func (p *parser) readStringValue() ([]byte, error) {
 		case 'u':
 			r, err := p.readUnicodeEscape()
 			if err != nil {
+				if p.repairEOF(err, RepairUnterminatedString) {
+					return p.decodedBuf, nil
+				}
 				return nil, err
 			}
 			p.decodedBuf = utf8.AppendRune(p.decodedBuf, r)
 		default:
-			return nil, p.errorf("json: invalid escape sequence")
+			if p.dialect != DialectJSON5 {
+				return nil, p.errorf("invalid escape sequence")
+			}
+			if err := p.readJSON5Escape(esc); err != nil {
+				if p.repairEOF(err, RepairUnterminatedString) {
+					return p.decodedBuf, nil
+				}
+				return nil, err
+			}
 		}
 	}
 }
@@ -657,6 +990,9 @@ func (p *parser) readUnicodeEscape() (rune, error) {
 	if err != nil {
 		return 0, err
 	}
+	if p.canonical && n1 >= 0xDC00 && n1 <= 0xDFFF {
+		return utf8.RuneError, p.errorf("invalid surrogate pair")
+	}
 	if n1 < 0xD800 || n1 > 0xDBFF {
 		return n1, nil
 	}
@@ -665,21 +1001,21 @@ func (p *parser) readUnicodeEscape() (rune, error) {
 		return 0, err
 	}
 	if b != '\\' {
-		return utf8.RuneError, p.errorf("json: invalid surrogate pair")
+		return utf8.RuneError, p.errorf("invalid surrogate pair")
 	}
 	b, err = p.scanner.readByte()
 	if err != nil {
 		return 0, err
 	}
 	if b != 'u' {
-		return utf8.RuneError, p.errorf("json: invalid surrogate pair")
+		return utf8.RuneError, p.errorf("invalid surrogate pair")
 	}
 	n2, err := p.readHex4()
 	if err != nil {
 		return 0, err
 	}
 	if n2 < 0xDC00 || n2 > 0xDFFF {
-		return utf8.RuneError, p.errorf("json: invalid surrogate pair")
+		return utf8.RuneError, p.errorf("invalid surrogate pair")
 	}
 	return utf16.DecodeRune(n1, n2), nil
 }
@@ -692,7 +1028,7 @@ func (p *parser) readHex4() (rune, error) {
 			return 0, err
 		}
 		if !isHex(b) {
-			return 0, p.errorf("json: invalid unicode escape")
+			return 0, p.errorf("invalid unicode escape")
 		}
 		val = val<<4 | rune(fromHex(b))
 	}
@@ -700,12 +1036,12 @@ func (p *parser) readHex4() (rune, error) {
 }
 
 func (p *parser) expectColon() error {
-	b, err := p.scanner.readNonSpace()
+	b, err := p.readNonSpace()
 	if err != nil {
 		return err
 	}
 	if b != ':' {
-		return p.errorf("json: expected ':' after object key")
+		return p.errorf("expected ':' after object key")
 	}
 	return nil
 }
@@ -724,24 +1060,42 @@ func (p *parser) parseLiteral(first byte) error {
 		lit = "null"
 		style = p.formatter.pal.Null
 	default:
-		return p.errorf("json: invalid literal")
+		return p.errorf("invalid literal")
 	}
+	if err := p.matchLiteral(lit); err != nil {
+		return err
+	}
+	return p.formatter.writeLiteral(lit, style)
+}
+
+// matchLiteral consumes the rest of lit after its first byte. In repair mode
+// a literal cut off by the end of input is completed.
+func (p *parser) matchLiteral(lit string) error {
 	for i := 1; i < len(lit); i++ {
 		b, err := p.scanner.readByte()
 		if err != nil {
+			if p.repairEOF(err, RepairTruncatedLiteral) {
+				return nil
+			}
 			return err
 		}
 		if b != lit[i] {
-			return p.errorf("json: invalid literal")
+			return p.errorf("invalid literal")
 		}
 	}
-	return p.formatter.writeLiteral(lit, style)
+	return nil
 }
 
 func (p *parser) parseNumber(first byte) error {
+	if p.canonical {
+		return p.parseCanonicalNumber(first)
+	}
+	if p.dialect == DialectJSON5 {
+		return p.parseJSON5Number(first)
+	}
 	state, ok := numStartState(first)
 	if !ok {
-		return p.errorf("json: invalid number")
+		return p.errorf("invalid number")
 	}
 	if p.formatter.pal.Number != "" {
 		if err := p.formatter.writeANSI(p.formatter.pal.Number); err != nil {
@@ -764,7 +1118,7 @@ func (p *parser) parseNumber(first byte) error {
 		}
 		next, ok := numNextState(state, b)
 		if !ok {
-			return p.errorf("json: invalid number")
+			return p.errorfNext("invalid number")
 		}
 		state = next
 		_, _ = p.scanner.readByte()
@@ -773,7 +1127,14 @@ func (p *parser) parseNumber(first byte) error {
 		}
 	}
 	if !numIsTerminal(state) {
-		return p.errorf("json: invalid number")
+		if !p.repair {
+			return p.errorfNext("invalid number")
+		}
+		// Every non-terminal state becomes valid with one more digit.
+		p.reportRepair(RepairIncompleteNumber, p.scanner.pos)
+		if err := p.formatter.writeByte('0'); err != nil {
+			return err
+		}
 	}
 	if p.formatter.pal.Number != "" {
 		if err := p.formatter.writeANSI(ansi.Reset); err != nil {
@@ -809,15 +1170,70 @@ type scanner struct {
 	buf [4096]byte
 	pos int
 	n   int
+
+	// base is the stream offset of buf[0]. Newlines are counted lazily:
+	// bufLines/bufLineStart describe the stream up to buf[0], while
+	// lines/lineStart extend that through buf[:counted].
+	base         int64
+	bufLines     int
+	bufLineStart int64
+	lines        int
+	lineStart    int64
+	counted      int
+
+	// fenced makes the scanner report end of input at n, which fence moved
+	// back from fenceN, so repair mode can end a truncated document there.
+	fenced bool
+	fenceN int
 }
 
 func (s *scanner) Reset(r io.Reader) {
 	s.r = r
 	s.pos = 0
 	s.n = 0
+	s.base = 0
+	s.bufLines = 0
+	s.bufLineStart = 0
+	s.lines = 0
+	s.lineStart = 0
+	s.counted = 0
+	s.fenced = false
+}
+
+// resume continues scanning from r, which is positioned at stream offset
+// off. lines is the number of newlines before off and lineStart the offset
+// of the line containing it.
+func (s *scanner) resume(r io.Reader, off int64, lines int, lineStart int64) {
+	s.r = r
+	s.pos = 0
+	s.n = 0
+	s.base = off
+	s.bufLines = lines
+	s.bufLineStart = lineStart
+	s.lines = lines
+	s.lineStart = lineStart
+	s.counted = 0
+	s.fenced = false
+}
+
+// fence ends the input at the next unread byte until unfence is called.
+func (s *scanner) fence() {
+	if !s.fenced {
+		s.fenced, s.fenceN, s.n = true, s.n, s.pos
+	}
+}
+
+func (s *scanner) unfence() {
+	if s.fenced {
+		s.fenced, s.n = false, s.fenceN
+	}
 }
 
 func (s *scanner) fill() error {
+	if s.fenced {
+		return io.EOF
+	}
+	s.lineInfo(s.n)
 	n, err := s.r.Read(s.buf[:])
 	if n == 0 {
 		if err == nil {
@@ -825,11 +1241,64 @@ func (s *scanner) fill() error {
 		}
 		return err
 	}
+	s.bufLines = s.lines
+	s.bufLineStart = s.lineStart
+	s.counted = 0
+	s.base += int64(s.n)
 	s.pos = 0
 	s.n = n
 	return nil
 }
 
+// lineInfo returns the number of newlines before buf[idx] and the stream
+// offset at which the line containing buf[idx] starts. Successive calls with
+// increasing idx only count the bytes in between.
+func (s *scanner) lineInfo(idx int) (int, int64) {
+	if idx < s.counted {
+		s.lines = s.bufLines
+		s.lineStart = s.bufLineStart
+		s.counted = 0
+	}
+	seg := s.buf[s.counted:idx]
+	if c := bytes.Count(seg, newlineBytes); c > 0 {
+		s.lines += c
+		s.lineStart = s.base + int64(s.counted+bytes.LastIndexByte(seg, '\n')) + 1
+	}
+	s.counted = idx
+	return s.lines, s.lineStart
+}
+
+// position reports the stream offset and the 1-based line and byte column of
+// buf[idx].
+func (s *scanner) position(idx int) (offset int64, line, column int) {
+	lines, lineStart := s.lineInfo(idx)
+	offset = s.base + int64(idx)
+	return offset, lines + 1, int(offset-lineStart) + 1
+}
+
+// excerpt returns the part of the current line surrounding buf[idx] that is
+// still buffered, together with the index of buf[idx] within it.
+func (s *scanner) excerpt(idx int) (string, int) {
+	start := idx - excerptRadius
+	if start < 0 {
+		start = 0
+	}
+	end := idx + excerptRadius
+	if end > s.n {
+		end = s.n
+	}
+	if i := bytes.LastIndexByte(s.buf[start:idx], '\n'); i >= 0 {
+		start += i + 1
+	}
+	if i := bytes.IndexByte(s.buf[idx:end], '\n'); i >= 0 {
+		end = idx + i
+	}
+	if end > idx && s.buf[end-1] == '\r' {
+		end--
+	}
+	return string(s.buf[start:end]), idx - start
+}
+
 func (s *scanner) readByte() (byte, error) {
 	if s.pos >= s.n {
 		if err := s.fill(); err != nil {
@@ -850,6 +1319,25 @@ func (s *scanner) peekByte() (byte, error) {
 	return s.buf[s.pos], nil
 }
 
+// readLine appends the bytes up to the next newline to dst and consumes the
+// newline. At end of input it returns the final partial line with io.EOF.
+func (s *scanner) readLine(dst []byte) ([]byte, error) {
+	for {
+		if s.pos >= s.n {
+			if err := s.fill(); err != nil {
+				return dst, err
+			}
+		}
+		chunk := s.buf[s.pos:s.n]
+		if i := bytes.IndexByte(chunk, '\n'); i >= 0 {
+			s.pos += i + 1
+			return append(dst, chunk[:i]...), nil
+		}
+		dst = append(dst, chunk...)
+		s.pos = s.n
+	}
+}
+
 func (s *scanner) skipSpace() error {
 	for {
 		b, err := s.peekByte()
@@ -0,0 +1,78 @@
+package prettyx
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// excerptRadius bounds how many bytes on either side of the offending byte
+// are kept in SyntaxError.Excerpt.
+const excerptRadius = 32
+
+// SyntaxError describes malformed JSON input. It is returned by PrettyStream,
+// CompactTo and the functions built on them.
+type SyntaxError struct {
+	// Msg describes the problem, e.g. "expected ',' or '}'".
+	Msg string
+	// Offset is the 0-based byte offset of the offending byte in the input.
+	Offset int64
+	// Line and Column are 1-based; Column counts bytes, not runes. Both are
+	// 0 for binary input such as CBOR, which has no lines.
+	Line   int
+	Column int
+	// Document is the 1-based index of the document within the input stream.
+	Document int
+	// Excerpt holds the input surrounding the offending byte, limited to a
+	// single line. ExcerptOffset is the index of the offending byte within
+	// Excerpt (equal to len(Excerpt) at end of input).
+	Excerpt       string
+	ExcerptOffset int
+}
+
+func (e *SyntaxError) Error() string {
+	if e.Line == 0 {
+		msg := "json: " + e.Msg + " at offset " + strconv.FormatInt(e.Offset, 10)
+		if e.Document > 1 {
+			msg += " (document " + strconv.Itoa(e.Document) + ")"
+		}
+		return msg
+	}
+	msg := "json: " + e.Msg + " at line " + strconv.Itoa(e.Line) + ", column " + strconv.Itoa(e.Column) +
+		" (offset " + strconv.FormatInt(e.Offset, 10)
+	if e.Document > 1 {
+		msg += ", document " + strconv.Itoa(e.Document)
+	}
+	return msg + ")"
+}
+
+// newSyntaxError builds a SyntaxError for s.buf[idx]. idx is clamped to the
+// buffered range so callers may pass pos-1 right after a refill.
+func newSyntaxError(s *scanner, idx int, doc int, format string, args ...any) *SyntaxError {
+	if idx < 0 {
+		idx = 0
+	}
+	if idx > s.n {
+		idx = s.n
+	}
+	off, line, col := s.position(idx)
+	excerpt, excerptOff := s.excerpt(idx)
+	return &SyntaxError{
+		Msg:           fmt.Sprintf(format, args...),
+		Offset:        off,
+		Line:          line,
+		Column:        col,
+		Document:      doc,
+		Excerpt:       excerpt,
+		ExcerptOffset: excerptOff,
+	}
+}
+
+// rebase moves a SyntaxError found in a sub-stream that started at the given
+// stream position so that it reports positions in the outer stream.
+func (e *SyntaxError) rebase(offset int64, line, column int) {
+	if e.Line == 1 {
+		e.Column += column - 1
+	}
+	e.Line += line - 1
+	e.Offset += offset
+}
@@ -0,0 +1,155 @@
+package prettyx
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestSyntaxError_PositionsAcrossEntryPoints(t *testing.T) {
+	cases := []struct {
+		name     string
+		input    string
+		msg      string
+		offset   int64
+		line     int
+		column   int
+		document int
+		excerpt  string
+		caret    int
+	}{
+		{
+			name:     "missing colon in second document",
+			input:    "{\"a\":1}\n{\"b\":2,\n  \"c\" 3}\n",
+			msg:      "expected ':' after object key",
+			offset:   22,
+			line:     3,
+			column:   7,
+			document: 2,
+			excerpt:  "  \"c\" 3}",
+			caret:    6,
+		},
+		{
+			name:     "truncated array",
+			input:    "[1,2",
+			msg:      "unexpected end of input",
+			offset:   4,
+			line:     1,
+			column:   5,
+			document: 1,
+			excerpt:  "[1,2",
+			caret:    4,
+		},
+		{
+			name:     "invalid number at peeked terminator",
+			input:    "{\"a\":1.}",
+			msg:      "invalid number",
+			offset:   7,
+			line:     1,
+			column:   8,
+			document: 1,
+			excerpt:  "{\"a\":1.}",
+			caret:    7,
+		},
+		{
+			name:     "invalid escape",
+			input:    "\r\n{\"a\":\"x\\q\"}",
+			msg:      "invalid escape sequence",
+			offset:   10,
+			line:     2,
+			column:   9,
+			document: 1,
+			excerpt:  "{\"a\":\"x\\q\"}",
+			caret:    8,
+		},
+	}
+
+	for _, tc := range cases {
+		for _, unwrap := range []bool{false, true} {
+			opts := *DefaultOptions
+			opts.Unwrap = unwrap
+			opts.Palette = "none"
+
+			for entry, run := range map[string]func() error{
+				"PrettyStream": func() error { return PrettyStream(io.Discard, strings.NewReader(tc.input), &opts) },
+				"CompactTo":    func() error { return CompactTo(io.Discard, strings.NewReader(tc.input), &opts) },
+			} {
+				err := run()
+				var se *SyntaxError
+				if !errors.As(err, &se) {
+					t.Fatalf("%s/%s unwrap=%v: expected *SyntaxError, got %T %v", tc.name, entry, unwrap, err, err)
+				}
+				if se.Msg != tc.msg || se.Offset != tc.offset || se.Line != tc.line || se.Column != tc.column || se.Document != tc.document {
+					t.Fatalf("%s/%s unwrap=%v: unexpected error %+v", tc.name, entry, unwrap, se)
+				}
+				if se.Excerpt != tc.excerpt || se.ExcerptOffset != tc.caret {
+					t.Fatalf("%s/%s unwrap=%v: unexpected excerpt %q at %d", tc.name, entry, unwrap, se.Excerpt, se.ExcerptOffset)
+				}
+			}
+		}
+	}
+}
+
+func TestSyntaxError_AcrossBufferRefills(t *testing.T) {
+	var b strings.Builder
+	for i := 0; i < 500; i++ {
+		b.WriteString("{\"n\":12345}\n")
+	}
+	b.WriteString("{\"n\":12345,}\n")
+	input := b.String()
+	want := int64(len(input) - 2)
+
+	for _, unwrap := range []bool{false, true} {
+		opts := *DefaultOptions
+		opts.Unwrap = unwrap
+		for _, err := range []error{
+			PrettyStream(io.Discard, strings.NewReader(input), &opts),
+			CompactTo(io.Discard, strings.NewReader(input), &opts),
+		} {
+			var se *SyntaxError
+			if !errors.As(err, &se) {
+				t.Fatalf("unwrap=%v: expected *SyntaxError, got %v", unwrap, err)
+			}
+			if se.Offset != want || se.Line != 501 || se.Column != 12 || se.Document != 501 {
+				t.Fatalf("unwrap=%v: unexpected position %+v", unwrap, se)
+			}
+		}
+	}
+}
+
+func TestSyntaxError_Message(t *testing.T) {
+	se := &SyntaxError{Msg: "invalid literal", Offset: 9, Line: 2, Column: 3, Document: 1}
+	if got := se.Error(); got != "json: invalid literal at line 2, column 3 (offset 9)" {
+		t.Fatalf("unexpected message %q", got)
+	}
+	se.Document = 4
+	if got := se.Error(); got != "json: invalid literal at line 2, column 3 (offset 9, document 4)" {
+		t.Fatalf("unexpected message %q", got)
+	}
+}
+
+func TestSyntaxError_WriterAndReaderErrorsPassThrough(t *testing.T) {
+	var se *SyntaxError
+	if err := CompactTo(errWriter{}, strings.NewReader("{\"a\":1}"), DefaultOptions); err == nil || errors.As(err, &se) {
+		t.Fatalf("expected plain writer error, got %v", err)
+	}
+	r := &errAfterReader{data: []byte("{\"a\":\"abc")}
+	if err := CompactTo(io.Discard, r, DefaultOptions); err == nil || errors.As(err, &se) {
+		t.Fatalf("expected plain reader error, got %v", err)
+	}
+}
+
+func TestSyntaxError_OversizedDocumentFallsBack(t *testing.T) {
+	input := "[" + strings.Repeat("1,", maxScratchCap) + "]"
+	var buf bytes.Buffer
+	err := CompactTo(&buf, strings.NewReader(input), DefaultOptions)
+	var se *SyntaxError
+	if !errors.As(err, &se) {
+		t.Fatalf("expected *SyntaxError, got %v", err)
+	}
+	if se.Document != 1 || se.Offset != int64(len(input)) {
+		t.Fatalf("unexpected fallback position %+v", se)
+	}
+}
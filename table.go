@@ -0,0 +1,314 @@
+package prettyx
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"io"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"pkt.systems/prettyx/internal/ansi"
+)
+
+// tableValueColumn names the column that holds array elements which are not
+// objects.
+const tableValueColumn = "value"
+
+func isTableOutput(format OutputFormat) bool {
+	return format == OutputCSV || format == OutputTSV || format == OutputTable
+}
+
+// writeTable renders every document from r as rows of one table: each
+// element of a top-level array is a row, and any other document is a row of
+// its own, so NDJSON streams work as well as list responses. The whole input
+// is read before anything is written because the columns are the union of
+// the keys of all rows.
+func writeTable(w io.Writer, r io.Reader, opts *Options, pal ColorPalette) error {
+	t := &table{flatten: opts.TableFlatten, index: make(map[string]int)}
+	if err := compactDocuments(r, opts, t.addDocument); err != nil {
+		return err
+	}
+	t.sortColumns(opts.SortKeys)
+	bw := bufio.NewWriter(w)
+	var err error
+	switch opts.Output {
+	case OutputCSV:
+		err = t.writeCSV(bw)
+	case OutputTSV:
+		err = t.writeTSV(bw)
+	default:
+		err = t.writeAligned(bw, pal)
+	}
+	if err != nil {
+		_ = bw.Flush()
+		return err
+	}
+	return bw.Flush()
+}
+
+// table collects rows; rows[i][c] is the cell of row i in column cols[c],
+// or nil when the row has no such member.
+type table struct {
+	flatten bool
+	cols    []string
+	index   map[string]int
+	rows    [][]*inputNode
+}
+
+func (t *table) addDocument(line []byte) error {
+	node, err := decodeJSONLine(line)
+	if err != nil {
+		return err
+	}
+	if node.kind != inputArray {
+		t.addRow(node)
+		return nil
+	}
+	for _, item := range node.items {
+		t.addRow(item)
+	}
+	return nil
+}
+
+func (t *table) addRow(n *inputNode) {
+	var row []*inputNode
+	if n.kind == inputObject {
+		row = t.addCells(row, "", n)
+	} else {
+		row = t.setCell(row, tableValueColumn, n)
+	}
+	t.rows = append(t.rows, row)
+}
+
+// addCells adds the members of obj to row. With flatten set, non-empty
+// nested objects become columns named by their dotted path, and keys that
+// contain '.' or start with '"' are JSON-quoted so that {"a.b":1} and
+// {"a":{"b":1}} get different columns.
+func (t *table) addCells(row []*inputNode, prefix string, obj *inputNode) []*inputNode {
+	for i, key := range obj.keys {
+		val := obj.items[i]
+		name := prefix + key
+		if t.flatten && (strings.Contains(key, ".") || strings.HasPrefix(key, `"`)) {
+			name = prefix + string(appendQuotedBytes(nil, []byte(key)))
+		}
+		if t.flatten && val.kind == inputObject && len(val.keys) > 0 {
+			row = t.addCells(row, name+".", val)
+			continue
+		}
+		row = t.setCell(row, name, val)
+	}
+	return row
+}
+
+func (t *table) setCell(row []*inputNode, name string, val *inputNode) []*inputNode {
+	c, ok := t.index[name]
+	if !ok {
+		c = len(t.cols)
+		t.cols = append(t.cols, name)
+		t.index[name] = c
+	}
+	for len(row) <= c {
+		row = append(row, nil)
+	}
+	row[c] = val
+	return row
+}
+
+// sortColumns orders the columns by name, keeping first-seen order for
+// KeyOrderInput.
+func (t *table) sortColumns(order KeyOrder) {
+	if order == KeyOrderInput {
+		return
+	}
+	perm := make([]int, len(t.cols))
+	for i := range perm {
+		perm[i] = i
+	}
+	slices.SortStableFunc(perm, func(a, b int) int {
+		if order == KeyOrderUTF16 {
+			return compareUTF16([]byte(t.cols[a]), []byte(t.cols[b]))
+		}
+		return strings.Compare(t.cols[a], t.cols[b])
+	})
+	cols := make([]string, len(perm))
+	for i, c := range perm {
+		cols[i] = t.cols[c]
+	}
+	for r, row := range t.rows {
+		sorted := make([]*inputNode, len(perm))
+		for i, c := range perm {
+			if c < len(row) {
+				sorted[i] = row[c]
+			}
+		}
+		t.rows[r] = sorted
+	}
+	t.cols = cols
+}
+
+// cell returns the cell of row in column c, or nil.
+func cell(row []*inputNode, c int) *inputNode {
+	if c < len(row) {
+		return row[c]
+	}
+	return nil
+}
+
+// cellText returns a cell as text: strings unquoted, other scalars as JSON,
+// nested values as compact JSON, and null and missing cells as nullText.
+func cellText(v *inputNode, nullText string) string {
+	switch {
+	case v == nil:
+		return ""
+	case v.kind == inputNull:
+		return nullText
+	case v.kind == inputObject || v.kind == inputArray:
+		return string(appendInputJSON(nil, v))
+	default:
+		return v.text
+	}
+}
+
+func (t *table) writeCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(t.cols); err != nil {
+		return err
+	}
+	rec := make([]string, len(t.cols))
+	for _, row := range t.rows {
+		for c := range t.cols {
+			rec[c] = cellText(cell(row, c), "")
+		}
+		if err := cw.Write(rec); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// tsvEscaper keeps each record on one line with the escapes that
+// PostgreSQL's text format and most TSV readers understand.
+var tsvEscaper = strings.NewReplacer("\\", `\\`, "\t", `\t`, "\n", `\n`, "\r", `\r`)
+
+func (t *table) writeTSV(w *bufio.Writer) error {
+	writeRecord := func(fields func(c int) string) error {
+		for c := range t.cols {
+			if c > 0 {
+				w.WriteByte('\t')
+			}
+			tsvEscaper.WriteString(w, fields(c))
+		}
+		return w.WriteByte('\n')
+	}
+	if err := writeRecord(func(c int) string { return t.cols[c] }); err != nil {
+		return err
+	}
+	for _, row := range t.rows {
+		if err := writeRecord(func(c int) string { return cellText(cell(row, c), "") }); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// tableEscaper keeps cells of the aligned table on one line.
+var tableEscaper = strings.NewReplacer("\t", `\t`, "\n", `\n`, "\r", `\r`)
+
+// writeAligned writes the table with columns padded to their widest cell and
+// separated by two spaces. The header uses the palette's Key style and cells
+// the style of their JSON type; columns holding only numbers are right
+// aligned.
+func (t *table) writeAligned(w io.Writer, pal ColorPalette) error {
+	widths := make([]int, len(t.cols))
+	numeric := make([]bool, len(t.cols))
+	texts := make([][]string, len(t.rows))
+	for c, name := range t.cols {
+		widths[c] = utf8.RuneCountInString(name)
+		numeric[c] = true
+	}
+	for r, row := range t.rows {
+		texts[r] = make([]string, len(t.cols))
+		for c := range t.cols {
+			v := cell(row, c)
+			s := tableEscaper.Replace(cellText(v, "null"))
+			texts[r][c] = s
+			widths[c] = max(widths[c], utf8.RuneCountInString(s))
+			if v != nil && v.kind != inputNumber && v.kind != inputNull {
+				numeric[c] = false
+			}
+		}
+	}
+	var line []byte
+	appendCell := func(c int, s, style string) {
+		pad := widths[c] - utf8.RuneCountInString(s)
+		if c > 0 {
+			line = append(line, "  "...)
+		}
+		if numeric[c] {
+			line = appendSpaces(line, pad)
+		}
+		if style != "" && s != "" {
+			line = append(line, style...)
+			line = append(line, s...)
+			line = append(line, ansi.Reset...)
+		} else {
+			line = append(line, s...)
+		}
+		if !numeric[c] {
+			line = appendSpaces(line, pad)
+		}
+	}
+	// Trailing padding is trimmed so empty trailing cells leave no spaces.
+	writeLine := func() error {
+		line = append(bytes.TrimRight(line, " "), '\n')
+		_, err := w.Write(line)
+		line = line[:0]
+		return err
+	}
+	for c, name := range t.cols {
+		appendCell(c, name, pal.Key)
+	}
+	if err := writeLine(); err != nil {
+		return err
+	}
+	for r, row := range t.rows {
+		for c := range t.cols {
+			appendCell(c, texts[r][c], cellStyle(cell(row, c), pal))
+		}
+		if err := writeLine(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func appendSpaces(dst []byte, n int) []byte {
+	for ; n > 0; n-- {
+		dst = append(dst, ' ')
+	}
+	return dst
+}
+
+func cellStyle(v *inputNode, pal ColorPalette) string {
+	if v == nil {
+		return ""
+	}
+	switch v.kind {
+	case inputNull:
+		return pal.Null
+	case inputBool:
+		if v.text == "true" {
+			return pal.True
+		}
+		return pal.False
+	case inputNumber:
+		return pal.Number
+	case inputString:
+		return pal.String
+	default:
+		return pal.Punctuation
+	}
+}
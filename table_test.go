@@ -0,0 +1,96 @@
+package prettyx
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+const tableInput = `[{"name":"web","replicas":3,"meta":{"ns":"prod","labels":{"a":"b"}},"tags":["x"]},{"name":"db","ready":true,"replicas":12,"meta":null,"note":"a,b\nc"}]
+{"name":"cache","extra":1.5}
+"loose"
+`
+
+func renderTable(t *testing.T, input string, opts *Options) string {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := PrettyStream(&buf, strings.NewReader(input), opts); err != nil {
+		t.Fatalf("PrettyStream failed: %v", err)
+	}
+	return buf.String()
+}
+
+func TestTableCSV(t *testing.T) {
+	got := renderTable(t, tableInput, &Options{Output: OutputCSV})
+	want := `name,replicas,meta,tags,ready,note,extra,value
+web,3,"{""ns"":""prod"",""labels"":{""a"":""b""}}","[""x""]",,,,
+db,12,,,true,"a,b
+c",,
+cache,,,,,,1.5,
+,,,,,,,loose
+`
+	if got != want {
+		t.Fatalf("unexpected output\nexpected:\n%s\nactual:\n%s", want, got)
+	}
+}
+
+func TestTableTSVFlattenSorted(t *testing.T) {
+	opts := &Options{Output: OutputTSV, TableFlatten: true, SortKeys: KeyOrderBytes}
+	got := renderTable(t, tableInput, opts)
+	want := "extra\tmeta\tmeta.labels.a\tmeta.ns\tname\tnote\tready\treplicas\ttags\tvalue\n" +
+		"\t\tb\tprod\tweb\t\t\t3\t[\"x\"]\t\n" +
+		"\t\t\t\tdb\ta,b\\nc\ttrue\t12\t\t\n" +
+		"1.5\t\t\t\tcache\t\t\t\t\t\n" +
+		"\t\t\t\t\t\t\t\t\tloose\n"
+	if got != want {
+		t.Fatalf("unexpected output\nexpected:\n%q\nactual:\n%q", want, got)
+	}
+}
+
+func TestTableFlattenDottedKeys(t *testing.T) {
+	opts := &Options{Output: OutputCSV, TableFlatten: true}
+	got := renderTable(t, `{"a":{"b":1,"c.d":2},"a.b":3,"\"a.b\"":4}`, opts)
+	want := `a.b,"a.""c.d""","""a.b""","""\""a.b\"""""` + "\n1,2,3,4\n"
+	if got != want {
+		t.Fatalf("unexpected output\nexpected:\n%s\nactual:\n%s", want, got)
+	}
+}
+
+func TestTableAligned(t *testing.T) {
+	input := `{"name":"web","replicas":3,"ok":true}
+{"name":"database","replicas":12,"ok":null,"note":"x\ty"}
+`
+	opts := *DefaultOptions
+	opts.Palette = "none"
+	opts.Output = OutputTable
+	got := renderTable(t, input, &opts)
+	want := "name      replicas  ok    note\n" +
+		"web              3  true\n" +
+		"database        12  null  x\\ty\n"
+	if got != want {
+		t.Fatalf("unexpected output\nexpected:\n%s\nactual:\n%s", want, got)
+	}
+
+	opts.Palette = "default"
+	opts.ForceColor = true
+	pal, err := resolvePalette(&opts, true)
+	if err != nil {
+		t.Fatalf("resolvePalette failed: %v", err)
+	}
+	colored := renderTable(t, `[{"n":1,"s":"x"}]`, &opts)
+	if !strings.Contains(colored, pal.Key+"n\x1b[0m") || !strings.Contains(colored, pal.Number+"1\x1b[0m") ||
+		!strings.Contains(colored, pal.String+"x\x1b[0m") {
+		t.Fatalf("expected palette styles in %q", colored)
+	}
+}
+
+func TestTableCompactToIsUncoloured(t *testing.T) {
+	var buf bytes.Buffer
+	opts := &Options{Output: OutputTable, ForceColor: true, Unwrap: true}
+	if err := CompactTo(&buf, strings.NewReader(`{"a":"{\"b\":1}"}`), opts); err != nil {
+		t.Fatalf("CompactTo failed: %v", err)
+	}
+	if got, want := buf.String(), "a\n{\"b\":1}\n"; got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,636 @@
+package prettyx
+
+import (
+	"bytes"
+	"io"
+	"strconv"
+	"unicode/utf8"
+)
+
+// tomlStream converts a TOML document, which has to be read whole because
+// later tables can add to earlier ones, to a single JSON object. Dates and
+// times become strings in their TOML form.
+type tomlStream struct {
+	r    io.Reader
+	done bool
+}
+
+func newTOMLStream(r io.Reader) *tomlStream {
+	return &tomlStream{r: r}
+}
+
+func (s *tomlStream) next(dst []byte) ([]byte, error) {
+	if s.done {
+		return dst, io.EOF
+	}
+	s.done = true
+	src, err := io.ReadAll(s.r)
+	if err != nil {
+		return dst, err
+	}
+	t := tomlParser{src: src, root: &inputNode{kind: inputObject}}
+	if err := t.parse(); err != nil {
+		return dst, err
+	}
+	dst = appendInputJSON(dst, t.root)
+	return append(dst, '\n'), nil
+}
+
+type tomlParser struct {
+	src  []byte
+	pos  int
+	root *inputNode
+	// explicit holds tables defined by a [header]; frozen holds inline
+	// tables and arrays, which cannot be extended; tableArrays holds arrays
+	// created by [[header]].
+	explicit    map[*inputNode]bool
+	frozen      map[*inputNode]bool
+	tableArrays map[*inputNode]bool
+}
+
+func (t *tomlParser) errorAt(pos int, msg string) error {
+	return textError(t.src, pos, 0, 1, 1, msg)
+}
+
+func (t *tomlParser) peek() byte {
+	if t.pos >= len(t.src) {
+		return 0
+	}
+	return t.src[t.pos]
+}
+
+func (t *tomlParser) hasPrefix(s string) bool {
+	return bytes.HasPrefix(t.src[t.pos:], []byte(s))
+}
+
+func (t *tomlParser) skipSpace() {
+	for c := t.peek(); c == ' ' || c == '\t'; c = t.peek() {
+		t.pos++
+	}
+}
+
+// skipBlank skips whitespace, line breaks and comments.
+func (t *tomlParser) skipBlank() {
+	for t.pos < len(t.src) {
+		switch t.src[t.pos] {
+		case ' ', '\t', '\r', '\n':
+			t.pos++
+		case '#':
+			t.skipComment()
+		default:
+			return
+		}
+	}
+}
+
+func (t *tomlParser) skipComment() {
+	if i := bytes.IndexByte(t.src[t.pos:], '\n'); i >= 0 {
+		t.pos += i
+	} else {
+		t.pos = len(t.src)
+	}
+}
+
+// endLine checks that only whitespace and a comment follow on the line.
+func (t *tomlParser) endLine() error {
+	t.skipSpace()
+	if t.peek() == '#' {
+		t.skipComment()
+	}
+	switch {
+	case t.pos == len(t.src), t.hasPrefix("\n"), t.hasPrefix("\r\n"):
+		return nil
+	default:
+		return t.errorAt(t.pos, "expected end of line")
+	}
+}
+
+func (t *tomlParser) parse() error {
+	current := t.root
+	for {
+		t.skipBlank()
+		if t.pos == len(t.src) {
+			return nil
+		}
+		var err error
+		switch {
+		case t.hasPrefix("[["):
+			current, err = t.parseTableArrayHeader()
+		case t.peek() == '[':
+			current, err = t.parseTableHeader()
+		default:
+			err = t.parseKeyValue(current)
+		}
+		if err != nil {
+			return err
+		}
+		if err := t.endLine(); err != nil {
+			return err
+		}
+	}
+}
+
+func (t *tomlParser) parseTableHeader() (*inputNode, error) {
+	start := t.pos
+	t.pos++
+	keys, err := t.parseKey()
+	if err != nil {
+		return nil, err
+	}
+	if t.peek() != ']' {
+		return nil, t.errorAt(t.pos, "expected ']'")
+	}
+	t.pos++
+	parent, err := t.descend(t.root, keys[:len(keys)-1], start)
+	if err != nil {
+		return nil, err
+	}
+	last := keys[len(keys)-1]
+	table := parent.member(last)
+	switch {
+	case table == nil:
+		table = &inputNode{kind: inputObject}
+		parent.set(last, table)
+	case table.kind != inputObject || t.explicit[table] || t.frozen[table]:
+		return nil, t.errorAt(start, "duplicate table")
+	}
+	if t.explicit == nil {
+		t.explicit = make(map[*inputNode]bool)
+	}
+	t.explicit[table] = true
+	return table, nil
+}
+
+func (t *tomlParser) parseTableArrayHeader() (*inputNode, error) {
+	start := t.pos
+	t.pos += 2
+	keys, err := t.parseKey()
+	if err != nil {
+		return nil, err
+	}
+	if !t.hasPrefix("]]") {
+		return nil, t.errorAt(t.pos, "expected ']]'")
+	}
+	t.pos += 2
+	parent, err := t.descend(t.root, keys[:len(keys)-1], start)
+	if err != nil {
+		return nil, err
+	}
+	last := keys[len(keys)-1]
+	arr := parent.member(last)
+	switch {
+	case arr == nil:
+		arr = &inputNode{kind: inputArray}
+		parent.set(last, arr)
+		if t.tableArrays == nil {
+			t.tableArrays = make(map[*inputNode]bool)
+		}
+		t.tableArrays[arr] = true
+	case !t.tableArrays[arr]:
+		return nil, t.errorAt(start, "duplicate key")
+	}
+	table := &inputNode{kind: inputObject}
+	arr.items = append(arr.items, table)
+	return table, nil
+}
+
+// descend walks the tables named by keys from table, creating missing ones.
+// A name that holds an array of tables continues in its last table.
+func (t *tomlParser) descend(table *inputNode, keys []string, pos int) (*inputNode, error) {
+	for _, key := range keys {
+		next := table.member(key)
+		switch {
+		case next == nil:
+			next = &inputNode{kind: inputObject}
+			table.set(key, next)
+		case t.tableArrays[next]:
+			next = next.items[len(next.items)-1]
+		case next.kind != inputObject || t.frozen[next]:
+			return nil, t.errorAt(pos, "key "+strconv.Quote(key)+" is not a table")
+		}
+		table = next
+	}
+	return table, nil
+}
+
+func (t *tomlParser) parseKeyValue(table *inputNode) error {
+	start := t.pos
+	keys, err := t.parseKey()
+	if err != nil {
+		return err
+	}
+	if t.peek() != '=' {
+		return t.errorAt(t.pos, "expected '=' after key")
+	}
+	t.pos++
+	t.skipSpace()
+	val, err := t.parseValue()
+	if err != nil {
+		return err
+	}
+	parent, err := t.descend(table, keys[:len(keys)-1], start)
+	if err != nil {
+		return err
+	}
+	last := keys[len(keys)-1]
+	if parent.member(last) != nil {
+		return t.errorAt(start, "duplicate key "+strconv.Quote(last))
+	}
+	parent.set(last, val)
+	return nil
+}
+
+// parseKey reads a bare, quoted or dotted key and the whitespace after it.
+func (t *tomlParser) parseKey() ([]string, error) {
+	var keys []string
+	for {
+		t.skipSpace()
+		var key string
+		switch c := t.peek(); {
+		case c == '"':
+			s, err := t.parseBasicString()
+			if err != nil {
+				return nil, err
+			}
+			key = s
+		case c == '\'':
+			s, err := t.parseLiteralString()
+			if err != nil {
+				return nil, err
+			}
+			key = s
+		default:
+			start := t.pos
+			for isTOMLBareKeyByte(t.peek()) {
+				t.pos++
+			}
+			if t.pos == start {
+				return nil, t.errorAt(t.pos, "expected key")
+			}
+			key = string(t.src[start:t.pos])
+		}
+		keys = append(keys, key)
+		t.skipSpace()
+		if t.peek() != '.' {
+			return keys, nil
+		}
+		t.pos++
+	}
+}
+
+func isTOMLBareKeyByte(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '-'
+}
+
+func (t *tomlParser) parseValue() (*inputNode, error) {
+	switch c := t.peek(); {
+	case c == '"':
+		var s string
+		var err error
+		if t.hasPrefix(`"""`) {
+			s, err = t.parseMultilineBasicString()
+		} else {
+			s, err = t.parseBasicString()
+		}
+		return &inputNode{kind: inputString, text: s}, err
+	case c == '\'':
+		var s string
+		var err error
+		if t.hasPrefix("'''") {
+			s, err = t.parseMultilineLiteralString()
+		} else {
+			s, err = t.parseLiteralString()
+		}
+		return &inputNode{kind: inputString, text: s}, err
+	case c == '[':
+		return t.parseArray()
+	case c == '{':
+		return t.parseInlineTable()
+	case t.hasPrefix("true") && !isTOMLBareKeyByte(t.peekAt(t.pos+4)):
+		t.pos += 4
+		return &inputNode{kind: inputBool, text: "true"}, nil
+	case t.hasPrefix("false") && !isTOMLBareKeyByte(t.peekAt(t.pos+5)):
+		t.pos += 5
+		return &inputNode{kind: inputBool, text: "false"}, nil
+	default:
+		return t.parseNumberOrDate()
+	}
+}
+
+func (t *tomlParser) peekAt(i int) byte {
+	if i >= len(t.src) {
+		return 0
+	}
+	return t.src[i]
+}
+
+func (t *tomlParser) parseArray() (*inputNode, error) {
+	start := t.pos
+	t.pos++
+	arr := &inputNode{kind: inputArray}
+	for {
+		t.skipBlank()
+		if t.pos == len(t.src) {
+			return nil, t.errorAt(start, "unterminated array")
+		}
+		if t.peek() == ']' {
+			t.pos++
+			break
+		}
+		val, err := t.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		arr.items = append(arr.items, val)
+		t.skipBlank()
+		switch t.peek() {
+		case ',':
+			t.pos++
+		case ']':
+		case 0:
+			return nil, t.errorAt(start, "unterminated array")
+		default:
+			return nil, t.errorAt(t.pos, "expected ',' or ']'")
+		}
+	}
+	t.freeze(arr)
+	return arr, nil
+}
+
+func (t *tomlParser) parseInlineTable() (*inputNode, error) {
+	t.pos++
+	table := &inputNode{kind: inputObject}
+	t.skipSpace()
+	if t.peek() == '}' {
+		t.pos++
+		t.freeze(table)
+		return table, nil
+	}
+	for {
+		if err := t.parseKeyValue(table); err != nil {
+			return nil, err
+		}
+		t.skipSpace()
+		switch t.peek() {
+		case ',':
+			t.pos++
+		case '}':
+			t.pos++
+			t.freeze(table)
+			return table, nil
+		default:
+			return nil, t.errorAt(t.pos, "expected ',' or '}'")
+		}
+	}
+}
+
+func (t *tomlParser) freeze(n *inputNode) {
+	if t.frozen == nil {
+		t.frozen = make(map[*inputNode]bool)
+	}
+	t.frozen[n] = true
+}
+
+func (t *tomlParser) parseBasicString() (string, error) {
+	start := t.pos
+	t.pos++
+	var b []byte
+	for {
+		if t.pos == len(t.src) || t.src[t.pos] == '\n' {
+			return "", t.errorAt(start, "unterminated string")
+		}
+		switch c := t.src[t.pos]; c {
+		case '"':
+			t.pos++
+			return string(b), nil
+		case '\\':
+			var err error
+			if b, err = t.appendEscape(b); err != nil {
+				return "", err
+			}
+		default:
+			b = append(b, c)
+			t.pos++
+		}
+	}
+}
+
+// parseMultilineBasicString reads a """ string. A line break right after
+// the opening quotes is dropped, and a backslash at the end of a line removes
+// the break and the whitespace that follows.
+func (t *tomlParser) parseMultilineBasicString() (string, error) {
+	start := t.pos
+	t.pos += 3
+	t.skipLeadingBreak()
+	var b []byte
+	for {
+		if t.pos == len(t.src) {
+			return "", t.errorAt(start, "unterminated string")
+		}
+		c := t.src[t.pos]
+		switch {
+		case t.hasPrefix(`"""`):
+			// Up to two quotes may directly precede the closing delimiter.
+			t.pos += 3
+			for i := 0; i < 2 && t.peek() == '"'; i++ {
+				b = append(b, '"')
+				t.pos++
+			}
+			return string(b), nil
+		case c == '\\' && t.lineEndingBackslash():
+			t.pos++
+			for t.pos < len(t.src) && bytes.IndexByte([]byte(" \t\r\n"), t.src[t.pos]) >= 0 {
+				t.pos++
+			}
+		case c == '\\':
+			var err error
+			if b, err = t.appendEscape(b); err != nil {
+				return "", err
+			}
+		default:
+			b = append(b, c)
+			t.pos++
+		}
+	}
+}
+
+// lineEndingBackslash reports whether the backslash at the current position
+// is followed by nothing but whitespace up to the end of the line.
+func (t *tomlParser) lineEndingBackslash() bool {
+	for i := t.pos + 1; i < len(t.src); i++ {
+		switch t.src[i] {
+		case ' ', '\t', '\r':
+		case '\n':
+			return true
+		default:
+			return false
+		}
+	}
+	return false
+}
+
+func (t *tomlParser) skipLeadingBreak() {
+	if t.hasPrefix("\r\n") {
+		t.pos += 2
+	} else if t.hasPrefix("\n") {
+		t.pos++
+	}
+}
+
+func (t *tomlParser) appendEscape(b []byte) ([]byte, error) {
+	start := t.pos
+	t.pos++
+	size := 0
+	switch c := t.peek(); c {
+	case 'b':
+		b = append(b, '\b')
+	case 't':
+		b = append(b, '\t')
+	case 'n':
+		b = append(b, '\n')
+	case 'f':
+		b = append(b, '\f')
+	case 'r':
+		b = append(b, '\r')
+	case 'e':
+		b = append(b, 0x1b)
+	case '"', '\\':
+		b = append(b, c)
+	case 'u':
+		size = 4
+	case 'U':
+		size = 8
+	default:
+		return nil, t.errorAt(start, "invalid escape sequence")
+	}
+	t.pos++
+	if size == 0 {
+		return b, nil
+	}
+	if t.pos+size > len(t.src) {
+		return nil, t.errorAt(start, "invalid escape sequence")
+	}
+	n, err := strconv.ParseUint(string(t.src[t.pos:t.pos+size]), 16, 32)
+	if err != nil || !utf8.ValidRune(rune(n)) {
+		return nil, t.errorAt(start, "invalid escape sequence")
+	}
+	t.pos += size
+	return utf8.AppendRune(b, rune(n)), nil
+}
+
+func (t *tomlParser) parseLiteralString() (string, error) {
+	start := t.pos
+	t.pos++
+	end := bytes.IndexAny(t.src[t.pos:], "'\n")
+	if end < 0 || t.src[t.pos+end] != '\'' {
+		return "", t.errorAt(start, "unterminated string")
+	}
+	s := string(t.src[t.pos : t.pos+end])
+	t.pos += end + 1
+	return s, nil
+}
+
+func (t *tomlParser) parseMultilineLiteralString() (string, error) {
+	start := t.pos
+	t.pos += 3
+	t.skipLeadingBreak()
+	end := bytes.Index(t.src[t.pos:], []byte("'''"))
+	if end < 0 {
+		return "", t.errorAt(start, "unterminated string")
+	}
+	end += t.pos
+	// Up to two quotes may directly precede the closing delimiter.
+	for i := 0; i < 2 && end+3 < len(t.src) && t.src[end+3] == '\''; i++ {
+		end++
+	}
+	s := string(t.src[t.pos:end])
+	t.pos = end + 3
+	return s, nil
+}
+
+// parseNumberOrDate reads an integer, float, date or time. inf becomes
+// ±1.7976931348623157e+308 and nan null, as for JSON5.
+func (t *tomlParser) parseNumberOrDate() (*inputNode, error) {
+	start := t.pos
+	for c := t.peek(); isTOMLBareKeyByte(c) || c == '+' || c == '.' || c == ':'; c = t.peek() {
+		t.pos++
+	}
+	tok := string(t.src[start:t.pos])
+	if tok == "" {
+		return nil, t.errorAt(start, "expected value")
+	}
+	if isTOMLDateTime(tok) {
+		// A date and a time may be separated by a space instead of 'T'.
+		if len(tok) == 10 && t.peek() == ' ' && t.peekAt(t.pos+3) == ':' {
+			t.pos++
+			for c := t.peek(); isTOMLBareKeyByte(c) || c == '+' || c == '.' || c == ':'; c = t.peek() {
+				t.pos++
+			}
+			tok = string(t.src[start:t.pos])
+		}
+		return &inputNode{kind: inputString, text: tok}, nil
+	}
+	switch tok {
+	case "inf", "+inf", "-inf":
+		num := maxFloat64Text
+		if tok[0] == '-' {
+			num = "-" + num
+		}
+		return &inputNode{kind: inputNumber, text: num}, nil
+	case "nan", "+nan", "-nan":
+		return &inputNode{}, nil
+	}
+	digits, ok := tomlDigits(tok)
+	if !ok {
+		return nil, t.errorAt(start, "invalid value")
+	}
+	num, ok := appendJSONNumber(nil, digits)
+	if !ok {
+		return nil, t.errorAt(start, "invalid number")
+	}
+	return &inputNode{kind: inputNumber, text: string(num)}, nil
+}
+
+// isTOMLDateTime reports whether tok starts like a date (1979-05-27) or a
+// local time (07:32:00).
+func isTOMLDateTime(tok string) bool {
+	isDigits := func(s string) bool {
+		for i := 0; i < len(s); i++ {
+			if s[i] < '0' || s[i] > '9' {
+				return false
+			}
+		}
+		return true
+	}
+	return len(tok) >= 10 && isDigits(tok[:4]) && tok[4] == '-' && isDigits(tok[5:7]) && tok[7] == '-' ||
+		len(tok) >= 8 && isDigits(tok[:2]) && tok[2] == ':' && isDigits(tok[3:5]) && tok[5] == ':'
+}
+
+// tomlDigits removes the underscores from a number, which must sit between
+// digits, and rejects leading zeros in decimal integers.
+func tomlDigits(tok string) (string, bool) {
+	var b []byte
+	for i := 0; i < len(tok); i++ {
+		c := tok[i]
+		if c != '_' {
+			b = append(b, c)
+			continue
+		}
+		if i == 0 || i+1 == len(tok) || !isHex(tok[i-1]) || !isHex(tok[i+1]) {
+			return "", false
+		}
+	}
+	s := string(b)
+	body := s
+	if body != "" && (body[0] == '+' || body[0] == '-') {
+		body = body[1:]
+	}
+	if len(body) > 1 && body[0] == '0' && body[1] >= '0' && body[1] <= '9' {
+		return "", false
+	}
+	if len(body) > 1 && body[0] == '0' && (body[1] == 'x' || body[1] == 'o' || body[1] == 'b') && body != s {
+		// Only decimal numbers take a sign.
+		return "", false
+	}
+	return s, true
+}
@@ -0,0 +1,120 @@
+package prettyx
+
+// unwrapPolicy holds the Options that decide which strings Unwrap decodes,
+// besides the depth.
+type unwrapPolicy struct {
+	minLength  int
+	keys       []string
+	skipKeys   []string
+	decoders   []UnwrapDecoder
+	maxDecoded int
+	jwt        JWTMode
+}
+
+func newUnwrapPolicy(opts *Options) unwrapPolicy {
+	u := unwrapPolicy{
+		minLength:  opts.UnwrapMinLength,
+		keys:       opts.UnwrapKeys,
+		skipKeys:   opts.UnwrapSkipKeys,
+		decoders:   opts.UnwrapDecoders,
+		maxDecoded: opts.UnwrapMaxDecoded,
+		jwt:        opts.UnwrapJWT,
+	}
+	if u.maxDecoded <= 0 {
+		u.maxDecoded = DefaultUnwrapMaxDecoded
+	}
+	return u
+}
+
+// embeddedJSON returns the text of the trimmed string value that Unwrap
+// should parse: the string itself when it looks like JSON, or else what the
+// JWT decoding and the decoders make of it, decoding up to maxUnwrapDecodes
+// times until the result looks like JSON. Decoded text is kept in bufs,
+// whose two buffers take turns as source and destination.
+func (u *unwrapPolicy) embeddedJSON(bufs *[2][]byte, trimmed []byte) ([]byte, bool) {
+	if len(trimmed) < u.minLength {
+		return nil, false
+	}
+	src := trimmed
+	for step := 0; ; step++ {
+		// Decoded bytes are only trimmed to be checked, since binary data
+		// such as gzip may well end in bytes that look like whitespace.
+		if text := trimSpaceBytes(src); looksLikeJSONBytes(text) {
+			return text, true
+		}
+		if step == maxUnwrapDecodes || (len(u.decoders) == 0 && u.jwt == JWTKeep) {
+			return nil, false
+		}
+		buf := &bufs[step%2]
+		out, decoded := (*buf)[:0], false
+		if u.jwt != JWTKeep {
+			out, decoded = decodeJWT(out, src, u.maxDecoded, u.jwt == JWTDecodeTimes)
+		}
+		for i := 0; !decoded && i < len(u.decoders); i++ {
+			out, decoded = u.decoders[i]((*buf)[:0], src, u.maxDecoded)
+		}
+		if !decoded {
+			return nil, false
+		}
+		*buf, src = out, out
+	}
+}
+
+// tracksPath reports whether the policy depends on the members around a
+// string, so the parser has to keep the path to the current value.
+func (u *unwrapPolicy) tracksPath() bool {
+	return len(u.keys) > 0 || len(u.skipKeys) > 0
+}
+
+// unwrapDepth returns how many levels of nested JSON opts decodes, falling
+// back to MaxNestedJSONDepth when opts sets none.
+func unwrapDepth(opts *Options) int {
+	if opts == nil || !opts.Unwrap {
+		return 0
+	}
+	depth := opts.UnwrapDepth
+	if depth <= 0 {
+		depth = MaxNestedJSONDepth
+	}
+	if depth <= 0 {
+		depth = 1
+	}
+	return depth
+}
+
+// embeddedJSON returns the JSON text the trimmed string value about to be
+// written holds, if it may be unwrapped where it is in the document. Encoded
+// strings that a redaction value pattern matches, such as JWTs, are masked
+// rather than decoded.
+func (p *parser) embeddedJSON(trimmed []byte) ([]byte, bool) {
+	if !p.allowsUnwrap() {
+		return nil, false
+	}
+	if p.redact != nil && !looksLikeJSONBytes(trimmed) && p.redact.matchValue(trimmed) {
+		return nil, false
+	}
+	return p.unwrap.embeddedJSON(&p.unwrapBufs, trimmed)
+}
+
+// allowsUnwrap reports whether the key rules let the string value about to
+// be written be unwrapped.
+func (p *parser) allowsUnwrap() bool {
+	if !p.unwrap.tracksPath() {
+		return true
+	}
+	allowed := len(p.unwrap.keys) == 0
+	for i := range p.pathSteps {
+		step := &p.pathSteps[i]
+		if step.index >= 0 {
+			continue
+		}
+		key := p.pathKeys[step.start:step.end]
+		if matchAnyGlobFold(p.unwrap.skipKeys, key) {
+			return false
+		}
+		if !allowed && matchAnyGlobFold(p.unwrap.keys, key) {
+			allowed = true
+		}
+	}
+	return allowed
+}
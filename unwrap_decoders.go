@@ -0,0 +1,194 @@
+package prettyx
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"io"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// UnwrapDecoder recognises an encoding that hides JSON inside string values,
+// such as base64, for Unwrap. It appends the decoded form of src to dst and
+// returns the result and true, or returns false when src is not in its
+// encoding or would decode to more than limit bytes. Decoders need not check
+// that the result is JSON; Unwrap does.
+type UnwrapDecoder func(dst, src []byte, limit int) ([]byte, bool)
+
+// DefaultUnwrapMaxDecoded is the number of bytes a decoder may produce from
+// one string when Options.UnwrapMaxDecoded is zero.
+const DefaultUnwrapMaxDecoded = 1 << 20
+
+// maxUnwrapDecodes bounds how many decoders are chained on one string, as in
+// gzip inside base64.
+const maxUnwrapDecodes = 4
+
+// DefaultUnwrapDecoders holds the built-in decoders, for
+// Options.UnwrapDecoders.
+var DefaultUnwrapDecoders = []UnwrapDecoder{DecodeBase64, DecodeGzip, DecodeURLEncoded}
+
+// DecodeBase64 decodes standard and URL-safe base64, with or without
+// padding. Strings of fewer than four characters are not decoded.
+func DecodeBase64(dst, src []byte, limit int) ([]byte, bool) {
+	if len(src) < 4 {
+		return dst, false
+	}
+	urlSafe, padded := false, false
+	for i, c := range src {
+		switch {
+		case c >= 'A' && c <= 'Z', c >= 'a' && c <= 'z', c >= '0' && c <= '9':
+		case c == '+' || c == '/':
+		case c == '-' || c == '_':
+			urlSafe = true
+		case c == '=' && i >= len(src)-2:
+			padded = true
+		default:
+			return dst, false
+		}
+	}
+	if urlSafe && bytes.ContainsAny(src, "+/") {
+		return dst, false
+	}
+	var enc *base64.Encoding
+	switch {
+	case urlSafe && padded:
+		enc = base64.URLEncoding
+	case urlSafe:
+		enc = base64.RawURLEncoding
+	case padded:
+		enc = base64.StdEncoding
+	default:
+		enc = base64.RawStdEncoding
+	}
+	if enc.DecodedLen(len(src)) > limit {
+		return dst, false
+	}
+	out, err := enc.AppendDecode(dst, src)
+	if err != nil {
+		return dst, false
+	}
+	return out, true
+}
+
+var gzipReaderPool sync.Pool
+
+// DecodeGzip decompresses gzip data, recognised by its magic number. It is
+// meant to follow DecodeBase64, which turns compressed payloads into the
+// bytes it reads. Output beyond limit bytes fails the decoding instead of
+// being inflated.
+func DecodeGzip(dst, src []byte, limit int) ([]byte, bool) {
+	if len(src) < 18 || src[0] != 0x1f || src[1] != 0x8b {
+		return dst, false
+	}
+	zr, _ := gzipReaderPool.Get().(*gzip.Reader)
+	var err error
+	if zr == nil {
+		zr, err = gzip.NewReader(bytes.NewReader(src))
+	} else {
+		err = zr.Reset(bytes.NewReader(src))
+	}
+	if err != nil {
+		return dst, false
+	}
+	defer gzipReaderPool.Put(zr)
+	start := len(dst)
+	buf := bytes.NewBuffer(dst)
+	n, err := buf.ReadFrom(io.LimitReader(zr, int64(limit)+1))
+	if err != nil || n > int64(limit) {
+		return dst[:start], false
+	}
+	return buf.Bytes(), true
+}
+
+// DecodeURLEncoded decodes percent-encoding. A string that unescapes to JSON
+// is decoded as a whole; an application/x-www-form-urlencoded string such as
+// payload=%7B%22id%22%3A1%7D&sig=abc becomes an object of its fields, with
+// the values of repeated fields collected in arrays, when at least one value
+// is JSON. The field values stay strings that Unwrap decodes on the next
+// level. Strings without a '%' escape are not decoded.
+func DecodeURLEncoded(dst, src []byte, limit int) ([]byte, bool) {
+	if len(src) > limit || bytes.IndexByte(src, '%') < 0 {
+		return dst, false
+	}
+	s := string(src)
+	if whole, err := url.QueryUnescape(s); err == nil && looksLikeJSONBytes(trimSpaceBytes([]byte(whole))) {
+		return append(dst, whole...), true
+	}
+	if !mayHoldJSON(s) {
+		return dst, false
+	}
+	type field struct {
+		key    string
+		values []string
+	}
+	var fields []field
+	index := make(map[string]int)
+	hasJSON := false
+	for part := range strings.SplitSeq(s, "&") {
+		rawKey, rawValue, ok := strings.Cut(part, "=")
+		if !ok || rawKey == "" {
+			return dst, false
+		}
+		key, err := url.QueryUnescape(rawKey)
+		if err != nil {
+			return dst, false
+		}
+		value, err := url.QueryUnescape(rawValue)
+		if err != nil {
+			return dst, false
+		}
+		hasJSON = hasJSON || looksLikeJSONBytes(trimSpaceBytes([]byte(value)))
+		i, ok := index[key]
+		if !ok {
+			i = len(fields)
+			index[key] = i
+			fields = append(fields, field{key: key})
+		}
+		fields[i].values = append(fields[i].values, value)
+	}
+	if !hasJSON {
+		return dst, false
+	}
+	start := len(dst)
+	dst = append(dst, '{')
+	for i, f := range fields {
+		if i > 0 {
+			dst = append(dst, ',')
+		}
+		dst = appendJSONString(dst, f.key)
+		dst = append(dst, ':')
+		if len(f.values) > 1 {
+			dst = append(dst, '[')
+		}
+		for j, v := range f.values {
+			if j > 0 {
+				dst = append(dst, ',')
+			}
+			dst = appendJSONString(dst, v)
+		}
+		if len(f.values) > 1 {
+			dst = append(dst, ']')
+		}
+	}
+	dst = append(dst, '}')
+	if len(dst)-start > limit {
+		return dst[:start], false
+	}
+	return dst, true
+}
+
+// mayHoldJSON reports whether a percent-encoded string contains an opening
+// bracket, literal or escaped, without which no field value can be JSON.
+func mayHoldJSON(s string) bool {
+	if strings.ContainsAny(s, "{[") {
+		return true
+	}
+	for _, esc := range []string{"%7B", "%7b", "%5B", "%5b"} {
+		if strings.Contains(s, esc) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,178 @@
+package prettyx
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func gzipBase64(t *testing.T, s string) string {
+	t.Helper()
+	var b bytes.Buffer
+	zw := gzip.NewWriter(&b)
+	if _, err := zw.Write([]byte(s)); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return base64.StdEncoding.EncodeToString(b.Bytes())
+}
+
+func TestUnwrapDecoders(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		opts Options
+		want string
+	}{
+		{
+			name: "base64",
+			in:   `{"data":"` + base64.StdEncoding.EncodeToString([]byte(`{"id":1}`)) + `"}`,
+			want: `{"data":{"id":1}}`,
+		},
+		{
+			name: "base64url without padding",
+			in:   `{"data":"` + base64.RawURLEncoding.EncodeToString([]byte(`[{"q":"??>>"}]`)) + `"}`,
+			want: `{"data":[{"q":"??>>"}]}`,
+		},
+		{
+			name: "gzip inside base64",
+			in:   `{"data":"` + gzipBase64(t, `{"big":[1,2,3]}`) + `"}`,
+			want: `{"data":{"big":[1,2,3]}}`,
+		},
+		{
+			name: "base64 of a string holding JSON",
+			in:   `["` + base64.StdEncoding.EncodeToString([]byte(`{"inner":"{\"x\":true}"}`)) + `"]`,
+			want: `[{"inner":{"x":true}}]`,
+		},
+		{
+			name: "percent-encoded JSON",
+			in:   `{"q":"%7B%22a%22%3A%5B1%2C2%5D%7D"}`,
+			want: `{"q":{"a":[1,2]}}`,
+		},
+		{
+			name: "form body",
+			in:   `"payload=%7B%22type%22%3A%22push%22%7D&token=abc+def&tag=x&tag=y"`,
+			want: `{"payload":{"type":"push"},"token":"abc def","tag":["x","y"]}`,
+		},
+		{
+			name: "not encoded JSON",
+			in:   `{"word":"hello123","b64":"` + base64.StdEncoding.EncodeToString([]byte("plain text")) + `","form":"a=1&b=%20","pct":"100%"}`,
+			want: `{"word":"hello123","b64":"cGxhaW4gdGV4dA==","form":"a=1&b=%20","pct":"100%"}`,
+		},
+		{
+			name: "invalid JSON after decoding",
+			in:   `{"data":"` + base64.StdEncoding.EncodeToString([]byte(`{"id":}`)) + `"}`,
+			want: `{"data":"eyJpZCI6fQ=="}`,
+		},
+		{
+			name: "over the limit",
+			in:   `{"data":"` + gzipBase64(t, `{"big":"`+strings.Repeat("a", 4096)+`"}`) + `"}`,
+			opts: Options{UnwrapMaxDecoded: 1024},
+			want: `{"data":"` + gzipBase64(t, `{"big":"`+strings.Repeat("a", 4096)+`"}`) + `"}`,
+		},
+		{
+			name: "depth counts decoded levels",
+			in:   `{"f":"p=%7B%22a%22%3A%22%5B1%5D%22%7D"}`,
+			opts: Options{UnwrapDepth: 2},
+			want: `{"f":{"p":{"a":"[1]"}}}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := tt.opts
+			opts.Unwrap = true
+			opts.UnwrapDecoders = DefaultUnwrapDecoders
+			got, err := CompactToBuffer(strings.NewReader(tt.in), &opts)
+			if err != nil {
+				t.Fatalf("CompactTo: %v", err)
+			}
+			if string(got) != tt.want+"\n" {
+				t.Fatalf("CompactTo = %s, want %s", got, tt.want)
+			}
+
+			// The parser must agree with the jpact path.
+			opts.SortKeys = KeyOrderInput
+			opts.Indent = ""
+			opts.Palette = "none"
+			var b strings.Builder
+			if err := PrettyStream(&b, strings.NewReader(tt.in), &opts); err != nil {
+				t.Fatalf("PrettyStream: %v", err)
+			}
+			if got := strings.NewReplacer("\n", "", "\": ", "\":").Replace(b.String()); got != tt.want {
+				t.Fatalf("PrettyStream = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnwrapDecodersOff(t *testing.T) {
+	in := `{"data":"` + base64.StdEncoding.EncodeToString([]byte(`{"id":1}`)) + `"}`
+	got, err := CompactToBuffer(strings.NewReader(in), &Options{Unwrap: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != in+"\n" {
+		t.Fatalf("got %s, want the input unchanged", got)
+	}
+}
+
+func TestUnwrapDecoderCustomWithPath(t *testing.T) {
+	rot := func(dst, src []byte, limit int) ([]byte, bool) {
+		if !bytes.HasPrefix(src, []byte("rev:")) || len(src)-4 > limit {
+			return dst, false
+		}
+		for i := len(src) - 1; i >= 4; i-- {
+			dst = append(dst, src[i])
+		}
+		return dst, true
+	}
+	path, err := ParsePath(".msg.a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := &Options{Unwrap: true, UnwrapDecoders: []UnwrapDecoder{rot}, Path: path}
+	got, err := CompactToBuffer(strings.NewReader(`{"msg":"rev:}]2,1[:\"a\"{"}`), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "[1,2]\n" {
+		t.Fatalf("got %q", got)
+	}
+}
+
+func TestDecodeGzipLimit(t *testing.T) {
+	var b bytes.Buffer
+	zw := gzip.NewWriter(&b)
+	_, _ = zw.Write(bytes.Repeat([]byte{'x'}, 1<<16))
+	_ = zw.Close()
+	if _, ok := DecodeGzip(nil, b.Bytes(), 1<<16-1); ok {
+		t.Fatal("DecodeGzip decoded past the limit")
+	}
+	out, ok := DecodeGzip([]byte("ab"), b.Bytes(), 1<<16)
+	if !ok || len(out) != 2+1<<16 || string(out[:3]) != "abx" {
+		t.Fatalf("DecodeGzip = %d bytes, %v", len(out), ok)
+	}
+	if _, ok := DecodeGzip(nil, []byte("not gzip at all, no"), 100); ok {
+		t.Fatal("DecodeGzip accepted data without the magic number")
+	}
+}
+
+func TestDecodeURLEncodedManyFields(t *testing.T) {
+	var b strings.Builder
+	for i := 0; i < 50000; i++ {
+		fmt.Fprintf(&b, "k%d=%%41&", i)
+	}
+	if _, ok := DecodeURLEncoded(nil, []byte(b.String()+"k0=x"), 1<<20); ok {
+		t.Fatal("DecodeURLEncoded decoded a form without JSON")
+	}
+	b.WriteString("k0=%7B%7D")
+	out, ok := DecodeURLEncoded(nil, []byte(b.String()), 1<<22)
+	if !ok || !bytes.HasPrefix(out, []byte(`{"k0":["A","{}"],"k1":"A",`)) || bytes.Count(out, []byte(`:"A"`)) != 49999 {
+		t.Fatalf("DecodeURLEncoded = %.80s, %v", out, ok)
+	}
+}
@@ -3,7 +3,6 @@ package prettyx
 import (
 	"bytes"
 	"errors"
-	"fmt"
 	"io"
 	"sync"
 	"unicode/utf16"
@@ -20,7 +19,11 @@ type unwrapReader struct {
 	sources    []unwrapSource
 	sourcesBuf [defaultUnwrapDepth]unwrapSource
 	used       int
+	policy     unwrapPolicy
 	validator  parser
+	// err keeps the first failure so it survives callers that replace read
+	// errors with their own messages.
+	err error
 }
 
 type unwrapSource struct {
@@ -42,6 +45,9 @@ type unwrapSource struct {
 	scratchArr [defaultStringBufCap]byte
 	rawBuf     []byte
 	rawArr     [defaultStringBufCap]byte
+	// unwrapBufs hold strings decoded by the unwrap decoders while a source
+	// reads them.
+	unwrapBufs [2][]byte
 
 	sliceReader bytes.Reader
 }
@@ -91,6 +97,8 @@ func (u *unwrapReader) reset(r io.Reader, depth int) {
 		u.sources = u.sources[:1]
 	}
 	u.used = 1
+	u.policy = unwrapPolicy{}
+	u.err = nil
 	u.sources[0].resetFromReader(r, depth)
 }
 
@@ -103,6 +111,7 @@ func (u *unwrapReader) clear() {
 	}
 	u.sources = u.sources[:0]
 	u.used = 0
+	u.err = nil
 	u.validator.scanner.Reset(nil)
 	u.validator.fmt.clear()
 	u.validator.formatter = nil
@@ -157,6 +166,9 @@ func (u *unwrapReader) nextByte() (byte, error) {
 			u.sources = u.sources[:len(u.sources)-1]
 			continue
 		}
+		if err != nil && u.err == nil {
+			u.err = err
+		}
 		return b, err
 	}
 }
@@ -233,10 +245,25 @@ func (s *unwrapSource) clear() {
 	} else {
 		s.rawBuf = s.rawBuf[:0]
 	}
+	for i := range s.unwrapBufs {
+		if cap(s.unwrapBufs[i]) > maxScratchCap {
+			s.unwrapBufs[i] = nil
+		}
+	}
 }
 
 var errContinue = errors.New("continue")
 
+// errorf reports a syntax error at the byte the scanner consumed last.
+func (s *unwrapSource) errorf(format string, args ...any) error {
+	return newSyntaxError(&s.scanner, s.scanner.pos-1, 0, format, args...)
+}
+
+// errorfNext reports a syntax error at the next unread byte.
+func (s *unwrapSource) errorfNext(format string, args ...any) error {
+	return newSyntaxError(&s.scanner, s.scanner.pos, 0, format, args...)
+}
+
 var (
 	litTrue  = [...]byte{'t', 'r', 'u', 'e'}
 	litFalse = [...]byte{'f', 'a', 'l', 's', 'e'}
@@ -260,6 +287,9 @@ func (s *unwrapSource) nextByte(u *unwrapReader) (byte, error) {
 	b, err := s.scanner.readNonSpace()
 	if err != nil {
 		if err == io.EOF && s.topValueSeen {
+			if len(s.stack) != 0 {
+				return 0, s.errorfNext("unexpected end of input")
+			}
 			s.done = true
 			return 0, io.EOF
 		}
@@ -268,7 +298,7 @@ func (s *unwrapSource) nextByte(u *unwrapReader) (byte, error) {
 
 	if len(s.stack) == 0 {
 		if s.topValueSeen {
-			return 0, fmt.Errorf("json: multiple top-level values")
+			return 0, s.errorf("multiple top-level values")
 		}
 		s.topValueSeen = true
 		return s.handleValue(u, b)
@@ -280,14 +310,14 @@ func (s *unwrapSource) nextByte(u *unwrapReader) (byte, error) {
 		case objExpectKey:
 			if b == '}' {
 				if frame.objCount != 0 {
-					return 0, fmt.Errorf("json: expected object key")
+					return 0, s.errorf("expected object key")
 				}
 				s.stack = s.stack[:len(s.stack)-1]
 				s.valueComplete()
 				return '}', nil
 			}
 			if b != '"' {
-				return 0, fmt.Errorf("json: expected object key")
+				return 0, s.errorf("expected object key")
 			}
 			token, err := s.readRawStringToken()
 			if err != nil {
@@ -298,7 +328,7 @@ func (s *unwrapSource) nextByte(u *unwrapReader) (byte, error) {
 			return s.nextByte(u)
 		case objExpectColon:
 			if b != ':' {
-				return 0, fmt.Errorf("json: expected ':' after object key")
+				return 0, s.errorf("expected ':' after object key")
 			}
 			frame.objPhase = objExpectValue
 			return ':', nil
@@ -314,7 +344,7 @@ func (s *unwrapSource) nextByte(u *unwrapReader) (byte, error) {
 				s.valueComplete()
 				return '}', nil
 			}
-			return 0, fmt.Errorf("json: expected ',' or '}'")
+			return 0, s.errorf("expected ',' or '}'")
 		}
 	}
 
@@ -322,7 +352,7 @@ func (s *unwrapSource) nextByte(u *unwrapReader) (byte, error) {
 	if frame.arrExpectValue {
 		if b == ']' {
 			if frame.arrCount != 0 {
-				return 0, fmt.Errorf("json: expected array value")
+				return 0, s.errorf("expected array value")
 			}
 			s.stack = s.stack[:len(s.stack)-1]
 			s.valueComplete()
@@ -341,7 +371,7 @@ func (s *unwrapSource) nextByte(u *unwrapReader) (byte, error) {
 		s.valueComplete()
 		return ']', nil
 	}
-	return 0, fmt.Errorf("json: expected ',' or ']'")
+	return 0, s.errorf("expected ',' or ']'")
 }
 
 func (s *unwrapSource) handleValue(u *unwrapReader, first byte) (byte, error) {
@@ -358,10 +388,10 @@ func (s *unwrapSource) handleValue(u *unwrapReader, first byte) (byte, error) {
 			return 0, err
 		}
 		if s.depthLeft > 0 {
-			trimmed := trimSpaceBytes(val)
-			if looksLikeJSONBytes(trimmed) && u.validateJSONBytes(trimmed) {
+			src, ok := u.policy.embeddedJSON(&s.unwrapBufs, trimSpaceBytes(val))
+			if ok && u.validateJSONBytes(src) {
 				s.valueComplete()
-				u.pushSource(trimmed, s.depthLeft-1)
+				u.pushSource(src, s.depthLeft-1)
 				return 0, errContinue
 			}
 		}
@@ -387,7 +417,7 @@ func (s *unwrapSource) handleValue(u *unwrapReader, first byte) (byte, error) {
 			s.valueComplete()
 			return s.nextByte(u)
 		}
-		return 0, fmt.Errorf("json: unexpected character %q", first)
+		return 0, s.errorf("unexpected character %q", first)
 	}
 }
 
@@ -437,15 +467,15 @@ func (s *unwrapSource) readLiteral(first byte) ([]byte, error) {
 	case 'n':
 		lit = litNull[:]
 	default:
-		return nil, fmt.Errorf("json: invalid literal")
+		return nil, s.errorf("invalid literal")
 	}
 	for i := 1; i < len(lit); i++ {
 		b, err := s.scanner.readByte()
 		if err != nil {
-			return nil, fmt.Errorf("json: unexpected end in literal")
+			return nil, s.errorfNext("unexpected end in literal")
 		}
 		if b != lit[i] {
-			return nil, fmt.Errorf("json: invalid literal")
+			return nil, s.errorf("invalid literal")
 		}
 	}
 	return lit, nil
@@ -454,7 +484,7 @@ func (s *unwrapSource) readLiteral(first byte) ([]byte, error) {
 func (s *unwrapSource) readNumber(first byte) ([]byte, error) {
 	state, ok := numStartState(first)
 	if !ok {
-		return nil, fmt.Errorf("json: invalid number")
+		return nil, s.errorf("invalid number")
 	}
 	s.scratch = s.scratch[:0]
 	s.scratch = append(s.scratch, first)
@@ -471,14 +501,14 @@ func (s *unwrapSource) readNumber(first byte) ([]byte, error) {
 		}
 		next, ok := numNextState(state, b)
 		if !ok {
-			return nil, fmt.Errorf("json: invalid number")
+			return nil, s.errorfNext("invalid number")
 		}
 		state = next
 		_, _ = s.scanner.readByte()
 		s.scratch = append(s.scratch, b)
 	}
 	if !numIsTerminal(state) {
-		return nil, fmt.Errorf("json: invalid number")
+		return nil, s.errorfNext("invalid number")
 	}
 	return s.scratch, nil
 }
@@ -489,10 +519,10 @@ func (s *unwrapSource) readRawStringToken() ([]byte, error) {
 	for {
 		b, err := s.scanner.readByte()
 		if err != nil {
-			return nil, fmt.Errorf("json: unterminated string")
+			return nil, s.errorfNext("unterminated string")
 		}
 		if b < 0x20 {
-			return nil, fmt.Errorf("json: invalid control character in string")
+			return nil, s.errorf("invalid control character in string")
 		}
 		s.rawBuf = append(s.rawBuf, b)
 		if b == '"' {
@@ -501,7 +531,7 @@ func (s *unwrapSource) readRawStringToken() ([]byte, error) {
 		if b == '\\' {
 			esc, err := s.scanner.readByte()
 			if err != nil {
-				return nil, fmt.Errorf("json: unterminated escape sequence")
+				return nil, s.errorfNext("unterminated escape sequence")
 			}
 			s.rawBuf = append(s.rawBuf, esc)
 			switch esc {
@@ -511,15 +541,15 @@ func (s *unwrapSource) readRawStringToken() ([]byte, error) {
 				for i := 0; i < 4; i++ {
 					ch, err := s.scanner.readByte()
 					if err != nil {
-						return nil, fmt.Errorf("json: invalid unicode escape")
+						return nil, s.errorfNext("invalid unicode escape")
 					}
 					if !isHex(ch) {
-						return nil, fmt.Errorf("json: invalid unicode escape")
+						return nil, s.errorf("invalid unicode escape")
 					}
 					s.rawBuf = append(s.rawBuf, ch)
 				}
 			default:
-				return nil, fmt.Errorf("json: invalid escape sequence")
+				return nil, s.errorf("invalid escape sequence")
 			}
 		}
 	}
@@ -530,13 +560,13 @@ func (s *unwrapSource) readStringValue() ([]byte, error) {
 	for {
 		b, err := s.scanner.readByte()
 		if err != nil {
-			return nil, fmt.Errorf("json: unterminated string")
+			return nil, s.errorfNext("unterminated string")
 		}
 		if b == '"' {
 			return s.decodedBuf, nil
 		}
 		if b < 0x20 {
-			return nil, fmt.Errorf("json: invalid control character in string")
+			return nil, s.errorf("invalid control character in string")
 		}
 		if b != '\\' {
 			s.decodedBuf = append(s.decodedBuf, b)
@@ -544,7 +574,7 @@ func (s *unwrapSource) readStringValue() ([]byte, error) {
 		}
 		esc, err := s.scanner.readByte()
 		if err != nil {
-			return nil, fmt.Errorf("json: unterminated escape sequence")
+			return nil, s.errorfNext("unterminated escape sequence")
 		}
 		switch esc {
 		case '"', '\\', '/':
@@ -566,7 +596,7 @@ func (s *unwrapSource) readStringValue() ([]byte, error) {
 			}
 			s.decodedBuf = utf8.AppendRune(s.decodedBuf, r)
 		default:
-			return nil, fmt.Errorf("json: invalid escape sequence")
+			return nil, s.errorf("invalid escape sequence")
 		}
 	}
 }
@@ -581,24 +611,24 @@ func (s *unwrapSource) readUnicodeEscape() (rune, error) {
 	}
 	b, err := s.scanner.readByte()
 	if err != nil {
-		return 0, fmt.Errorf("json: invalid surrogate pair")
+		return 0, s.errorfNext("invalid surrogate pair")
 	}
 	if b != '\\' {
-		return utf8.RuneError, fmt.Errorf("json: invalid surrogate pair")
+		return utf8.RuneError, s.errorf("invalid surrogate pair")
 	}
 	b, err = s.scanner.readByte()
 	if err != nil {
-		return 0, fmt.Errorf("json: invalid surrogate pair")
+		return 0, s.errorfNext("invalid surrogate pair")
 	}
 	if b != 'u' {
-		return utf8.RuneError, fmt.Errorf("json: invalid surrogate pair")
+		return utf8.RuneError, s.errorf("invalid surrogate pair")
 	}
 	n2, err := s.readHex4()
 	if err != nil {
 		return 0, err
 	}
 	if n2 < 0xDC00 || n2 > 0xDFFF {
-		return utf8.RuneError, fmt.Errorf("json: invalid surrogate pair")
+		return utf8.RuneError, s.errorf("invalid surrogate pair")
 	}
 	return utf16.DecodeRune(n1, n2), nil
 }
@@ -608,10 +638,10 @@ func (s *unwrapSource) readHex4() (rune, error) {
 	for i := 0; i < 4; i++ {
 		b, err := s.scanner.readByte()
 		if err != nil {
-			return 0, fmt.Errorf("json: invalid unicode escape")
+			return 0, s.errorfNext("invalid unicode escape")
 		}
 		if !isHex(b) {
-			return 0, fmt.Errorf("json: invalid unicode escape")
+			return 0, s.errorf("invalid unicode escape")
 		}
 		val = val<<4 | rune(fromHex(b))
 	}
@@ -0,0 +1,149 @@
+package prettyx
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestUnwrapOptions(t *testing.T) {
+	const nested = `{"a":"{\"b\":\"{\\\"c\\\":1}\"}","short":"[]","meta":{"raw":"{\"x\":1}"},"list":["[1]"]}`
+	tests := []struct {
+		name string
+		opts Options
+		want string
+	}{
+		{
+			name: "depth from options",
+			opts: Options{Unwrap: true, UnwrapDepth: 1},
+			want: `{"a":{"b":"{\"c\":1}"},"short":[],"meta":{"raw":{"x":1}},"list":[[1]]}`,
+		},
+		{
+			name: "minimum length",
+			opts: Options{Unwrap: true, UnwrapMinLength: 4},
+			want: `{"a":{"b":{"c":1}},"short":"[]","meta":{"raw":{"x":1}},"list":["[1]"]}`,
+		},
+		{
+			name: "allowed keys apply below the member",
+			opts: Options{Unwrap: true, UnwrapKeys: []string{"A", "li*"}},
+			want: `{"a":{"b":{"c":1}},"short":"[]","meta":{"raw":"{\"x\":1}"},"list":[[1]]}`,
+		},
+		{
+			name: "skipped keys win",
+			opts: Options{Unwrap: true, UnwrapKeys: []string{"meta", "a"}, UnwrapSkipKeys: []string{"raw", "b"}},
+			want: `{"a":{"b":"{\"c\":1}"},"short":"[]","meta":{"raw":"{\"x\":1}"},"list":["[1]"]}`,
+		},
+		{
+			name: "skipped keys alone",
+			opts: Options{Unwrap: true, UnwrapSkipKeys: []string{"meta"}},
+			want: `{"a":{"b":{"c":1}},"short":[],"meta":{"raw":"{\"x\":1}"},"list":[[1]]}`,
+		},
+		{
+			name: "ignored without unwrap",
+			opts: Options{UnwrapDepth: 3, UnwrapKeys: []string{"a"}},
+			want: nested,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := CompactToBuffer(strings.NewReader(nested), &tt.opts)
+			if err != nil {
+				t.Fatalf("CompactTo: %v", err)
+			}
+			if string(got) != tt.want+"\n" {
+				t.Fatalf("CompactTo = %s, want %s", got, tt.want)
+			}
+
+			// The parser path must agree with the jpact path.
+			opts := tt.opts
+			opts.Indent = ""
+			opts.Palette = "none"
+			var b strings.Builder
+			if err := PrettyStream(&b, strings.NewReader(nested), &opts); err != nil {
+				t.Fatalf("PrettyStream: %v", err)
+			}
+			if got := strings.NewReplacer("\n", "", ": ", ":").Replace(b.String()); got != tt.want {
+				t.Fatalf("PrettyStream = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnwrapKeysTopLevelString(t *testing.T) {
+	opts := &Options{Unwrap: true, UnwrapKeys: []string{"*"}}
+	got, err := CompactToBuffer(strings.NewReader(`"{\"a\":1}"`), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != `"{\"a\":1}"`+"\n" {
+		t.Fatalf("got %s", got)
+	}
+	opts.UnwrapKeys = nil
+	got, err = CompactToBuffer(strings.NewReader(`"{\"a\":1}"`), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != `{"a":1}`+"\n" {
+		t.Fatalf("got %s", got)
+	}
+}
+
+func TestUnwrapKeysWithPathAndSortKeys(t *testing.T) {
+	const in = `{"z":"{\"y\":2,\"x\":1}","body":"{\"id\":\"{\\\"n\\\":1}\"}"}`
+	opts := &Options{Unwrap: true, UnwrapKeys: []string{"body"}, SortKeys: KeyOrderBytes}
+	got, err := CompactToBuffer(strings.NewReader(in), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"body":{"id":{"n":1}},"z":"{\"y\":2,\"x\":1}"}` + "\n"; string(got) != want {
+		t.Fatalf("sorted: got %s, want %s", got, want)
+	}
+
+	opts = &Options{Unwrap: true, UnwrapKeys: []string{"body"}}
+	for path, want := range map[string]string{".body.id.n": "1\n", ".z.x": ""} {
+		opts.Path, err = ParsePath(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := CompactToBuffer(strings.NewReader(in), opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != want {
+			t.Fatalf("path %s: got %q, want %q", path, got, want)
+		}
+	}
+}
+
+func TestUnwrapDepthConcurrent(t *testing.T) {
+	const in = `{"a":"{\"b\":\"{\\\"c\\\":\\\"[1]\\\"}\"}"}`
+	wants := map[int]string{
+		1: `{"a":{"b":"{\"c\":\"[1]\"}"}}`,
+		2: `{"a":{"b":{"c":"[1]"}}}`,
+		3: `{"a":{"b":{"c":[1]}}}`,
+	}
+	var wg sync.WaitGroup
+	errs := make(chan error, 3*50)
+	for depth, want := range wants {
+		for range 50 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				opts := &Options{Unwrap: true, UnwrapDepth: depth}
+				got, err := CompactToBuffer(strings.NewReader(in), opts)
+				if err == nil && string(got) != want+"\n" {
+					err = fmt.Errorf("depth %d: got %s, want %s", depth, got, want)
+				}
+				if err != nil {
+					errs <- err
+				}
+			}()
+		}
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,372 @@
+package prettyx
+
+import (
+	"bytes"
+	"unicode/utf8"
+)
+
+// OutputFormat selects what the streaming formatter writes.
+type OutputFormat int
+
+const (
+	// OutputJSON writes JSON. This is the default.
+	OutputJSON OutputFormat = iota
+	// OutputYAML writes block-style YAML in the layout kubectl uses:
+	// sequences under a key are not indented, strings are quoted only when
+	// YAML would read them as something else, and multi-line strings become
+	// literal block scalars. Documents are separated by "---".
+	OutputYAML
+	// OutputCBOR writes each document as a CBOR item, as CBORTo does.
+	OutputCBOR
+	// OutputMsgPack writes each document as a MessagePack value, as
+	// MsgPackTo does.
+	OutputMsgPack
+	// OutputCSV writes all documents as one CSV table. Each element of a
+	// top-level array, and each other document, is a row; the header is the
+	// union of the rows' keys in first-seen order, or sorted when SortKeys is
+	// set. Nested values are compact JSON cells unless TableFlatten is set,
+	// and null cells are empty. The whole input is read before the table is
+	// written.
+	OutputCSV
+	// OutputTSV writes the table of OutputCSV tab-separated, with tabs, line
+	// breaks and backslashes in cells escaped as \t, \n, \r and \\.
+	OutputTSV
+	// OutputTable writes the table of OutputCSV aligned for a terminal, with
+	// the header in the palette's Key colour and each cell coloured by its
+	// JSON type. Columns holding only numbers are right aligned.
+	OutputTable
+)
+
+type yamlContext uint8
+
+const (
+	// yamlTop: the value starts a document.
+	yamlTop yamlContext = iota
+	// yamlKey: the value follows "key:" on the same line.
+	yamlKey
+	// yamlItem: the value follows "- " on the same line.
+	yamlItem
+)
+
+// yamlIndent is the width of one nesting level. It matches the width of the
+// "- " sequence indicator so compact nested sequences line up.
+const yamlIndent = "  "
+
+// yamlDocument writes the value starting with first as a YAML document.
+// Sorting, unwrapping and redaction need the whole value, so with any of them
+// enabled the document is replayed from memory.
+func (p *parser) yamlDocument(first byte) error {
+	f := p.formatter
+	if p.written > 0 {
+		if err := f.ensureLineStart(0); err != nil {
+			return err
+		}
+		if err := f.writePunctuation("---"); err != nil {
+			return err
+		}
+		if err := p.yamlNewline(-1); err != nil {
+			return err
+		}
+	}
+	var err error
+	if p.sortKeys == KeyOrderInput && p.unwrapDepth <= 0 && p.redact == nil {
+		err = p.yamlDocumentValue(first)
+	} else {
+		err = p.replayDocument(first, (*parser).yamlDocumentValue)
+	}
+	if err != nil {
+		return err
+	}
+	if err := f.writeByte('\n'); err != nil {
+		return err
+	}
+	f.lineLen = 0
+	p.written++
+	return nil
+}
+
+func (p *parser) yamlDocumentValue(first byte) error {
+	if err := p.formatter.ensureLineStart(0); err != nil {
+		return err
+	}
+	return p.yamlValue(first, 0, yamlTop)
+}
+
+// yamlNewline ends the current line and indents the next one to level. A
+// negative level writes only the prefix.
+func (p *parser) yamlNewline(level int) error {
+	f := p.formatter
+	if err := f.writeByte('\n'); err != nil {
+		return err
+	}
+	f.lineLen = 0
+	if f.prefix != "" {
+		if err := f.writeString(f.prefix); err != nil {
+			return err
+		}
+	}
+	for i := 0; i < level; i++ {
+		if err := f.writeString(yamlIndent); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// yamlValue writes the value starting with first. level is the nesting level
+// of the line the value starts on.
+func (p *parser) yamlValue(first byte, level int, ctx yamlContext) error {
+	switch first {
+	case '{':
+		return p.yamlMapping(level, ctx)
+	case '[':
+		return p.yamlSequence(level, ctx)
+	}
+	if ctx == yamlKey {
+		if err := p.formatter.writeByte(' '); err != nil {
+			return err
+		}
+	}
+	if first == '"' {
+		val, err := p.readQuoted('"')
+		if err != nil {
+			return err
+		}
+		return p.yamlString(val, level)
+	}
+	// Numbers, literals and the extended forms of repair mode and JSON5 are
+	// written as JSON, which YAML reads the same way.
+	return p.parseValueWithFirst(0, first)
+}
+
+// yamlEmpty writes {} or [] for an empty container.
+func (p *parser) yamlEmpty(open, closing byte, ctx yamlContext) error {
+	f := p.formatter
+	if ctx == yamlKey {
+		if err := f.writeByte(' '); err != nil {
+			return err
+		}
+	}
+	if err := f.writeBracket(open); err != nil {
+		return err
+	}
+	return f.writeBracket(closing)
+}
+
+func (p *parser) yamlMapping(level int, ctx yamlContext) error {
+	f := p.formatter
+	b, err := p.readNonSpace()
+	if err != nil {
+		if p.repairEOF(err, RepairUnclosedObject) {
+			return p.yamlEmpty('{', '}', ctx)
+		}
+		return err
+	}
+	if b == '}' {
+		return p.yamlEmpty('{', '}', ctx)
+	}
+	keyLevel := level
+	if ctx != yamlTop {
+		keyLevel++
+	}
+	for first := true; ; first = false {
+		key, err := p.readMemberKey(b)
+		if err != nil {
+			return err
+		}
+		if !first || ctx == yamlKey {
+			if err := p.yamlNewline(keyLevel); err != nil {
+				return err
+			}
+		}
+		if yamlNeedsQuotes(key) {
+			err = p.writeQuotedBytes(key, f.pal.Key)
+		} else {
+			err = f.writeStyledBytes(f.pal.Key, key)
+		}
+		if err != nil {
+			return err
+		}
+		if err := f.writePunctuation(":"); err != nil {
+			return err
+		}
+		if err := p.expectColon(); err != nil {
+			if !p.repairEOF(err, RepairMissingValue) {
+				return err
+			}
+			return p.yamlNull()
+		}
+		if b, err = p.readNonSpace(); err != nil {
+			if !p.repairEOF(err, RepairMissingValue) {
+				return err
+			}
+			return p.yamlNull()
+		}
+		if err := p.yamlValue(b, keyLevel, yamlKey); err != nil {
+			return err
+		}
+		var closed bool
+		if b, closed, err = p.nextMember('}'); err != nil || closed {
+			return err
+		}
+	}
+}
+
+// yamlNull writes the null repair mode substitutes for a missing value.
+func (p *parser) yamlNull() error {
+	if err := p.formatter.writeByte(' '); err != nil {
+		return err
+	}
+	return p.formatter.writeLiteral("null", p.formatter.pal.Null)
+}
+
+func (p *parser) yamlSequence(level int, ctx yamlContext) error {
+	f := p.formatter
+	b, err := p.readNonSpace()
+	if err != nil {
+		if p.repairEOF(err, RepairUnclosedArray) {
+			return p.yamlEmpty('[', ']', ctx)
+		}
+		return err
+	}
+	if b == ']' {
+		return p.yamlEmpty('[', ']', ctx)
+	}
+	itemLevel := level
+	if ctx == yamlItem {
+		itemLevel++
+	}
+	for first := true; ; first = false {
+		if !first || ctx == yamlKey {
+			if err := p.yamlNewline(itemLevel); err != nil {
+				return err
+			}
+		}
+		if err := f.writePunctuation("- "); err != nil {
+			return err
+		}
+		if err := p.yamlValue(b, itemLevel, yamlItem); err != nil {
+			return err
+		}
+		var closed bool
+		if b, closed, err = p.nextMember(']'); err != nil || closed {
+			return err
+		}
+	}
+}
+
+// yamlString writes a decoded string: plain when YAML reads it back as the
+// same string, as a literal block scalar when it spans lines, and double
+// quoted otherwise. JSON string syntax is valid YAML double-quoted syntax.
+func (p *parser) yamlString(val []byte, level int) error {
+	f := p.formatter
+	if bytes.IndexByte(val, '\n') >= 0 && yamlBlockSafe(val) {
+		return p.yamlBlock(val, level)
+	}
+	if yamlNeedsQuotes(val) {
+		return p.writeQuotedBytes(val, f.pal.String)
+	}
+	return f.writeStyledBytes(f.pal.String, val)
+}
+
+// yamlBlock writes val as a literal block scalar indented one level deeper
+// than the line it starts on. The chomping indicator keeps the trailing
+// newlines exact.
+func (p *parser) yamlBlock(val []byte, level int) error {
+	f := p.formatter
+	indicator := "|-"
+	if bytes.HasSuffix(val, []byte("\n")) {
+		indicator = "|"
+		val = val[:len(val)-1]
+		if bytes.HasSuffix(val, []byte("\n")) {
+			indicator = "|+"
+		}
+	}
+	if err := f.writePunctuation(indicator); err != nil {
+		return err
+	}
+	for {
+		line := val
+		i := bytes.IndexByte(val, '\n')
+		if i >= 0 {
+			line = val[:i]
+		}
+		indent := level + 1
+		if len(line) == 0 {
+			indent = -1
+		}
+		if err := p.yamlNewline(indent); err != nil {
+			return err
+		}
+		if err := f.writeStyledBytes(f.pal.String, line); err != nil {
+			return err
+		}
+		if i < 0 {
+			return nil
+		}
+		val = val[i+1:]
+	}
+}
+
+// yamlBlockSafe reports whether val can be written as a literal block
+// scalar: valid UTF-8 without control characters other than tab and newline,
+// and a first non-empty line that does not start with a space, which YAML
+// would take as extra indentation.
+func yamlBlockSafe(val []byte) bool {
+	if !utf8.Valid(val) {
+		return false
+	}
+	for _, c := range val {
+		if (c < 0x20 && c != '\n' && c != '\t') || c == 0x7f {
+			return false
+		}
+	}
+	rest := bytes.TrimLeft(val, "\n")
+	return len(rest) > 0 && rest[0] != ' ' && rest[0] != '\t'
+}
+
+// yamlNeedsQuotes reports whether s has to be quoted to be read back as the
+// same string: it is empty, has surrounding or control whitespace, starts
+// with an indicator character, contains ": " or " #", or would resolve to
+// a bool, null or number under YAML 1.1 or 1.2.
+func yamlNeedsQuotes(s []byte) bool {
+	if len(s) == 0 || s[0] == ' ' || s[len(s)-1] == ' ' || s[len(s)-1] == ':' {
+		return true
+	}
+	if !utf8.Valid(s) {
+		return true
+	}
+	for _, c := range s {
+		if c < 0x20 || c == 0x7f {
+			return true
+		}
+	}
+	switch s[0] {
+	case '-', '?', ':', ',', '[', ']', '{', '}', '#', '&', '*', '!', '|', '>', '\'', '"', '%', '@', '`', '~', '=', '<':
+		return true
+	case '+', '.':
+		if len(s) > 1 && s[1] >= '0' && s[1] <= '9' {
+			return true
+		}
+	}
+	if s[0] >= '0' && s[0] <= '9' {
+		return true
+	}
+	if bytes.Contains(s, []byte(": ")) || bytes.Contains(s, []byte(" #")) {
+		return true
+	}
+	if len(s) <= 5 {
+		var lower [5]byte
+		for i, c := range s {
+			if c >= 'A' && c <= 'Z' {
+				c += 'a' - 'A'
+			}
+			lower[i] = c
+		}
+		switch string(lower[:len(s)]) {
+		case "true", "false", "yes", "no", "on", "off", "y", "n", "null", ".inf", ".nan":
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,1029 @@
+package prettyx
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// yamlStream splits a YAML stream into documents at "---" and "..." lines
+// and converts them one at a time, so memory is bounded by the largest
+// document. Empty documents are skipped.
+type yamlStream struct {
+	r       *bufio.Reader
+	src     []byte
+	line    int   // line number of the next line read from r
+	off     int64 // stream offset of the next line read from r
+	pending []byte
+	doc     int
+	eof     bool
+}
+
+func newYAMLStream(r io.Reader) *yamlStream {
+	return &yamlStream{r: bufio.NewReader(r), line: 1}
+}
+
+func (s *yamlStream) next(dst []byte) ([]byte, error) {
+	for {
+		if s.eof && s.pending == nil {
+			return dst, io.EOF
+		}
+		startLine, startOff := s.line, s.off
+		s.src = s.src[:0]
+		if s.pending != nil {
+			s.src = append(s.src, s.pending...)
+			s.pending = nil
+			startLine--
+			startOff -= int64(len(s.src))
+		}
+		if err := s.readDocument(); err != nil {
+			return dst, err
+		}
+		s.doc++
+		y := yamlParser{src: s.src, base: startOff, line: startLine, doc: s.doc}
+		node, err := y.parseDocument()
+		if err != nil {
+			return dst, err
+		}
+		if node == nil {
+			s.doc--
+			continue
+		}
+		dst = appendInputJSON(dst, node)
+		return append(dst, '\n'), nil
+	}
+}
+
+// readDocument appends lines to src up to the next document marker. A "---"
+// line is kept in pending, with the marker blanked out, as the first line of
+// the next document since content may follow it.
+func (s *yamlStream) readDocument() error {
+	for !s.eof {
+		line, err := s.r.ReadBytes('\n')
+		if err == io.EOF {
+			s.eof = true
+		} else if err != nil {
+			return err
+		}
+		if len(line) == 0 {
+			break
+		}
+		s.line++
+		s.off += int64(len(line))
+		switch {
+		case isYAMLMarker(line, "---"):
+			s.pending = append(line[:0:0], line...)
+			copy(s.pending, "   ")
+			return nil
+		case isYAMLMarker(line, "..."):
+			return nil
+		case line[0] == '%' && len(bytes.TrimSpace(s.src)) == 0:
+			// Directives only matter to YAML-level tooling.
+			line = line[len(line)-1:]
+			if line[0] != '\n' {
+				line = nil
+			}
+		}
+		s.src = append(s.src, line...)
+	}
+	return nil
+}
+
+func isYAMLMarker(line []byte, marker string) bool {
+	if !bytes.HasPrefix(line, []byte(marker)) {
+		return false
+	}
+	return len(line) == 3 || line[3] == ' ' || line[3] == '\t' || line[3] == '\n' || line[3] == '\r'
+}
+
+// yamlParser converts one YAML document. It covers block and flow
+// collections, plain, quoted and block scalars, anchors and aliases, merge
+// keys and the core schema tags; complex keys are not supported.
+type yamlParser struct {
+	src     []byte
+	pos     int
+	base    int64
+	line    int
+	doc     int
+	anchors map[string]*inputNode
+	sizes   map[*inputNode]int // expanded node counts of aliased nodes
+	aliased int                // nodes added to the document by aliases
+}
+
+// maxYAMLAliasNodes bounds how many nodes aliases may add to one document.
+// Aliases share the node they refer to, so nested anchors grow the output
+// exponentially ("billion laughs") while the input stays tiny.
+const maxYAMLAliasNodes = 1 << 20
+
+func (y *yamlParser) errorAt(pos int, msg string) error {
+	return textError(y.src, pos, y.base, y.line, y.doc, msg)
+}
+
+func (y *yamlParser) eof() bool {
+	return y.pos >= len(y.src)
+}
+
+func (y *yamlParser) peek() byte {
+	if y.pos >= len(y.src) {
+		return 0
+	}
+	return y.src[y.pos]
+}
+
+func (y *yamlParser) peekAt(i int) byte {
+	if i >= len(y.src) {
+		return 0
+	}
+	return y.src[i]
+}
+
+func (y *yamlParser) col(pos int) int {
+	return pos - (bytes.LastIndexByte(y.src[:pos], '\n') + 1)
+}
+
+func isYAMLSpace(c byte) bool {
+	return c == ' ' || c == '\t'
+}
+
+// isYAMLBreak reports whether c ends a token: end of input, a line break or
+// a space.
+func isYAMLBreak(c byte) bool {
+	return c == 0 || c == '\n' || c == '\r' || c == ' ' || c == '\t'
+}
+
+// skipSpace skips spaces, line breaks and comments up to the next content.
+func (y *yamlParser) skipSpace() {
+	for !y.eof() {
+		switch c := y.src[y.pos]; {
+		case isYAMLSpace(c) || c == '\n' || c == '\r':
+			y.pos++
+		case c == '#':
+			y.skipLine()
+		default:
+			return
+		}
+	}
+}
+
+// skipLine moves to the line break ending the current line.
+func (y *yamlParser) skipLine() {
+	if i := bytes.IndexByte(y.src[y.pos:], '\n'); i >= 0 {
+		y.pos += i
+	} else {
+		y.pos = len(y.src)
+	}
+}
+
+// lineRestEmpty skips spaces and reports whether only a comment or nothing
+// is left on the line.
+func (y *yamlParser) lineRestEmpty() bool {
+	for isYAMLSpace(y.peek()) {
+		y.pos++
+	}
+	c := y.peek()
+	return c == 0 || c == '\n' || c == '\r' || c == '#'
+}
+
+func (y *yamlParser) atSequenceEntry() bool {
+	return y.peek() == '-' && isYAMLBreak(y.peekAt(y.pos+1))
+}
+
+func (y *yamlParser) parseDocument() (*inputNode, error) {
+	y.skipSpace()
+	if y.eof() {
+		return nil, nil
+	}
+	node, err := y.parseNode(-1)
+	if err != nil {
+		return nil, err
+	}
+	y.skipSpace()
+	if !y.eof() {
+		return nil, y.errorAt(y.pos, "unexpected content")
+	}
+	return node, nil
+}
+
+// parseNode parses the node starting at the current position. indent is the
+// column of the parent mapping key or sequence entry, or -1 at the top.
+func (y *yamlParser) parseNode(indent int) (*inputNode, error) {
+	anchor, tag, err := y.parseProperties()
+	if err != nil {
+		return nil, err
+	}
+	var node *inputNode
+	if (anchor != "" || tag != "") && y.lineRestEmpty() {
+		y.skipSpace()
+		if !y.eof() && y.col(y.pos) > indent {
+			node, err = y.parseNode(indent)
+		} else {
+			node = &inputNode{}
+		}
+	} else {
+		node, err = y.parseNodeValue(indent, tag)
+	}
+	if err != nil {
+		return nil, err
+	}
+	node = applyYAMLTag(node, tag)
+	if anchor != "" {
+		if y.anchors == nil {
+			y.anchors = make(map[string]*inputNode)
+		}
+		y.anchors[anchor] = node
+	}
+	return node, nil
+}
+
+func (y *yamlParser) parseNodeValue(indent int, tag string) (*inputNode, error) {
+	switch c := y.peek(); {
+	case y.atSequenceEntry():
+		return y.parseSequence(y.col(y.pos))
+	case c == '|' || c == '>':
+		return y.parseBlockScalar(indent)
+	case c == '[' || c == '{':
+		node, err := y.parseFlow()
+		if err != nil {
+			return nil, err
+		}
+		if !y.lineRestEmpty() {
+			return nil, y.errorAt(y.pos, "unexpected content after flow collection")
+		}
+		return node, nil
+	case c == '*':
+		return y.parseAlias(false)
+	case y.atMappingKey():
+		return y.parseMapping(y.col(y.pos))
+	case c == '"' || c == '\'':
+		s, err := y.parseQuoted()
+		if err != nil {
+			return nil, err
+		}
+		if !y.lineRestEmpty() {
+			return nil, y.errorAt(y.pos, "unexpected content after quoted scalar")
+		}
+		return &inputNode{kind: inputString, text: s}, nil
+	default:
+		s := y.parsePlain(indent)
+		if tag != "" {
+			return &inputNode{kind: inputString, text: s}, nil
+		}
+		return resolveYAMLScalar(s), nil
+	}
+}
+
+// parseProperties reads an anchor and a tag in either order.
+func (y *yamlParser) parseProperties() (anchor, tag string, err error) {
+	for {
+		c := y.peek()
+		if c != '&' && c != '!' {
+			return anchor, tag, nil
+		}
+		start := y.pos
+		for !isYAMLBreak(y.peek()) && !isFlowIndicator(y.peek()) {
+			y.pos++
+		}
+		if c == '&' {
+			if y.pos == start+1 {
+				return "", "", y.errorAt(start, "missing anchor name")
+			}
+			anchor = string(y.src[start+1 : y.pos])
+		} else {
+			tag = string(y.src[start:y.pos])
+		}
+		for isYAMLSpace(y.peek()) {
+			y.pos++
+		}
+	}
+}
+
+func (y *yamlParser) parseAlias(flow bool) (*inputNode, error) {
+	start := y.pos
+	y.pos++
+	for !isYAMLBreak(y.peek()) && !(flow && isFlowIndicator(y.peek())) {
+		y.pos++
+	}
+	node, ok := y.anchors[string(y.src[start+1:y.pos])]
+	if !ok {
+		return nil, y.errorAt(start, "unknown alias")
+	}
+	y.aliased += y.expandedSize(node)
+	if y.aliased > maxYAMLAliasNodes {
+		return nil, y.errorAt(start, "too many nodes expanded from aliases")
+	}
+	return node, nil
+}
+
+// expandedSize returns the number of nodes n stands for once every shared
+// node is written out, saturating just past maxYAMLAliasNodes.
+func (y *yamlParser) expandedSize(n *inputNode) int {
+	if len(n.items) == 0 {
+		return 1
+	}
+	if size, ok := y.sizes[n]; ok {
+		return size
+	}
+	size := 1
+	for _, item := range n.items {
+		size += y.expandedSize(item)
+		if size > maxYAMLAliasNodes {
+			size = maxYAMLAliasNodes + 1
+			break
+		}
+	}
+	if y.sizes == nil {
+		y.sizes = make(map[*inputNode]int)
+	}
+	y.sizes[n] = size
+	return size
+}
+
+// applyYAMLTag applies the core schema tags to a resolved scalar: !!str and
+// the non-specific ! keep it a string, and !!int, !!float, !!bool and !!null
+// resolve a quoted one. Other tags are ignored.
+func applyYAMLTag(node *inputNode, tag string) *inputNode {
+	switch tag {
+	case "!!int", "!!float", "!!bool", "!!null":
+		if node.kind == inputString {
+			if r := resolveYAMLScalar(node.text); r.kind != inputString {
+				return r
+			}
+		}
+	}
+	return node
+}
+
+// atMappingKey reports whether the current line holds "key:" at the current
+// position.
+func (y *yamlParser) atMappingKey() bool {
+	i := y.pos
+	switch y.peekAt(i) {
+	case '"', '\'':
+		q := y.src[i]
+		for i++; i < len(y.src) && y.src[i] != '\n'; i++ {
+			if q == '"' && y.src[i] == '\\' {
+				i++
+				continue
+			}
+			if y.src[i] == q {
+				if q == '\'' && y.peekAt(i+1) == '\'' {
+					i++
+					continue
+				}
+				i++
+				for isYAMLSpace(y.peekAt(i)) {
+					i++
+				}
+				return y.peekAt(i) == ':' && isYAMLBreak(y.peekAt(i+1))
+			}
+		}
+		return false
+	case '[', '{', '#', '|', '>', '*', '&', '!', '%', '@', '`':
+		return false
+	}
+	for ; i < len(y.src) && y.src[i] != '\n'; i++ {
+		switch y.src[i] {
+		case ':':
+			if isYAMLBreak(y.peekAt(i + 1)) {
+				return true
+			}
+		case '#':
+			if i > y.pos && isYAMLSpace(y.src[i-1]) {
+				return false
+			}
+		}
+	}
+	return false
+}
+
+func (y *yamlParser) parseMapping(col int) (*inputNode, error) {
+	node := &inputNode{kind: inputObject}
+	var merges []*inputNode
+	for {
+		key, err := y.parseKey()
+		if err != nil {
+			return nil, err
+		}
+		var val *inputNode
+		if y.lineRestEmpty() {
+			y.skipSpace()
+			switch {
+			case !y.eof() && y.col(y.pos) > col:
+				val, err = y.parseNode(col)
+			case !y.eof() && y.col(y.pos) == col && y.atSequenceEntry():
+				val, err = y.parseSequence(col)
+			default:
+				val = &inputNode{}
+			}
+		} else {
+			val, err = y.parseNode(col)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if key == "<<" {
+			merges = append(merges, val)
+		} else {
+			node.set(key, val)
+		}
+
+		y.skipSpace()
+		if y.eof() || y.col(y.pos) < col {
+			break
+		}
+		if y.col(y.pos) > col {
+			return nil, y.errorAt(y.pos, "unexpected indentation")
+		}
+		if y.atSequenceEntry() {
+			break
+		}
+		if !y.atMappingKey() {
+			return nil, y.errorAt(y.pos, "expected mapping key")
+		}
+	}
+	for _, m := range merges {
+		sources := []*inputNode{m}
+		if m.kind == inputArray {
+			sources = m.items
+		}
+		for _, src := range sources {
+			for i, k := range src.keys {
+				if node.member(k) == nil {
+					node.set(k, src.items[i])
+				}
+			}
+		}
+	}
+	return node, nil
+}
+
+// parseKey reads a mapping key and the ':' after it.
+func (y *yamlParser) parseKey() (string, error) {
+	var key string
+	if c := y.peek(); c == '"' || c == '\'' {
+		var err error
+		if key, err = y.parseQuoted(); err != nil {
+			return "", err
+		}
+		for isYAMLSpace(y.peek()) {
+			y.pos++
+		}
+	} else {
+		start := y.pos
+		for !(y.peek() == ':' && isYAMLBreak(y.peekAt(y.pos+1))) {
+			y.pos++
+		}
+		key = string(bytes.TrimRight(y.src[start:y.pos], " \t"))
+	}
+	if y.peek() != ':' {
+		return "", y.errorAt(y.pos, "expected ':' after mapping key")
+	}
+	y.pos++
+	return key, nil
+}
+
+func (y *yamlParser) parseSequence(col int) (*inputNode, error) {
+	node := &inputNode{kind: inputArray}
+	for {
+		y.pos++
+		var item *inputNode
+		var err error
+		if y.lineRestEmpty() {
+			y.skipSpace()
+			if !y.eof() && y.col(y.pos) > col {
+				item, err = y.parseNode(col)
+			} else {
+				item = &inputNode{}
+			}
+		} else {
+			item, err = y.parseNode(col)
+		}
+		if err != nil {
+			return nil, err
+		}
+		node.items = append(node.items, item)
+
+		y.skipSpace()
+		if y.eof() || y.col(y.pos) < col {
+			return node, nil
+		}
+		if y.col(y.pos) > col {
+			return nil, y.errorAt(y.pos, "unexpected indentation")
+		}
+		if !y.atSequenceEntry() {
+			return node, nil
+		}
+	}
+}
+
+// parsePlain reads a plain scalar. Lines indented deeper than indent
+// continue it; line breaks fold to spaces and blank lines to newlines.
+func (y *yamlParser) parsePlain(indent int) string {
+	var b strings.Builder
+	breaks := 0
+	for {
+		start := y.pos
+		for !y.eof() {
+			c := y.src[y.pos]
+			if c == '\n' || c == '\r' || (c == '#' && y.pos > start && isYAMLSpace(y.src[y.pos-1])) {
+				break
+			}
+			y.pos++
+		}
+		text := bytes.TrimRight(y.src[start:y.pos], " \t")
+		if len(text) > 0 {
+			if b.Len() > 0 {
+				if breaks == 1 {
+					b.WriteByte(' ')
+				}
+				for i := 1; i < breaks; i++ {
+					b.WriteByte('\n')
+				}
+			}
+			b.Write(text)
+		}
+		if y.peek() == '#' {
+			return b.String()
+		}
+
+		// Look ahead for a continuation line.
+		next, n := y.pos, 0
+		for next < len(y.src) {
+			lineStart := next
+			if y.src[next] == '\r' {
+				next++
+			}
+			if next < len(y.src) && y.src[next] == '\n' {
+				next++
+				n++
+			}
+			for next < len(y.src) && isYAMLSpace(y.src[next]) {
+				next++
+			}
+			if next >= len(y.src) || (y.src[next] != '\n' && y.src[next] != '\r') {
+				break
+			}
+			if next == lineStart {
+				break
+			}
+		}
+		if next >= len(y.src) || n == 0 || y.col(next) <= indent || y.src[next] == '#' ||
+			(y.col(next) == 0 && (isYAMLMarker(y.src[next:], "---") || isYAMLMarker(y.src[next:], "..."))) {
+			return b.String()
+		}
+		if indent >= 0 && y.col(next) > indent && (y.peekAt(next) == '-' && isYAMLBreak(y.peekAt(next+1))) {
+			return b.String()
+		}
+		saved := y.pos
+		y.pos = next
+		if y.atMappingKey() {
+			y.pos = saved
+			return b.String()
+		}
+		breaks = n
+	}
+}
+
+// parseQuoted reads a single- or double-quoted scalar, which may span lines.
+func (y *yamlParser) parseQuoted() (string, error) {
+	start := y.pos
+	q := y.src[y.pos]
+	y.pos++
+	var b []byte
+	for {
+		if y.eof() {
+			return "", y.errorAt(start, "unterminated quoted scalar")
+		}
+		c := y.src[y.pos]
+		switch {
+		case c == q && q == '\'' && y.peekAt(y.pos+1) == '\'':
+			b = append(b, '\'')
+			y.pos += 2
+		case c == q:
+			y.pos++
+			return string(b), nil
+		case c == '\\' && q == '"':
+			var err error
+			if b, err = y.appendEscape(b); err != nil {
+				return "", err
+			}
+		case c == '\n' || c == '\r':
+			b = bytes.TrimRight(b, " \t")
+			b = y.foldQuotedBreak(b)
+		default:
+			b = append(b, c)
+			y.pos++
+		}
+	}
+}
+
+// foldQuotedBreak folds the line break at the current position inside a
+// quoted scalar: one break becomes a space, and each further blank line a
+// newline. Leading spaces on the next line are dropped.
+func (y *yamlParser) foldQuotedBreak(b []byte) []byte {
+	breaks := 0
+	for !y.eof() {
+		c := y.src[y.pos]
+		if c == '\n' {
+			breaks++
+		} else if c != '\r' && !isYAMLSpace(c) {
+			break
+		}
+		y.pos++
+	}
+	if breaks == 1 {
+		return append(b, ' ')
+	}
+	for i := 1; i < breaks; i++ {
+		b = append(b, '\n')
+	}
+	return b
+}
+
+// yamlEscapes maps the single-character escapes of double-quoted scalars.
+var yamlEscapes = map[byte]string{
+	'0': "\x00", 'a': "\a", 'b': "\b", 't': "\t", '\t': "\t", 'n': "\n", 'v': "\v", 'f': "\f",
+	'r': "\r", 'e': "\x1b", ' ': " ", '"': "\"", '/': "/", '\\': "\\", 'N': "\u0085",
+	'_': "\u00a0", 'L': "\u2028", 'P': "\u2029",
+}
+
+func (y *yamlParser) appendEscape(b []byte) ([]byte, error) {
+	start := y.pos
+	y.pos++
+	if y.eof() {
+		return nil, y.errorAt(start, "unterminated quoted scalar")
+	}
+	c := y.src[y.pos]
+	y.pos++
+	if s, ok := yamlEscapes[c]; ok {
+		return append(b, s...), nil
+	}
+	size := 0
+	switch c {
+	case '\n', '\r':
+		// An escaped line break joins the lines without a space.
+		y.pos--
+		for !y.eof() && (y.src[y.pos] == '\n' || y.src[y.pos] == '\r') {
+			y.pos++
+			if y.src[y.pos-1] == '\n' {
+				break
+			}
+		}
+		for isYAMLSpace(y.peek()) {
+			y.pos++
+		}
+		return b, nil
+	case 'x':
+		size = 2
+	case 'u':
+		size = 4
+	case 'U':
+		size = 8
+	default:
+		return nil, y.errorAt(start, "invalid escape sequence")
+	}
+	if y.pos+size > len(y.src) {
+		return nil, y.errorAt(start, "invalid escape sequence")
+	}
+	n, err := strconv.ParseUint(string(y.src[y.pos:y.pos+size]), 16, 32)
+	if err != nil || n > utf8.MaxRune {
+		return nil, y.errorAt(start, "invalid escape sequence")
+	}
+	y.pos += size
+	return utf8.AppendRune(b, rune(n)), nil
+}
+
+// parseBlockScalar reads a literal (|) or folded (>) block scalar whose
+// parent is at column indent.
+func (y *yamlParser) parseBlockScalar(indent int) (*inputNode, error) {
+	literal := y.src[y.pos] == '|'
+	y.pos++
+	chomp := byte(0)
+	explicit := 0
+	for {
+		c := y.peek()
+		switch {
+		case (c == '+' || c == '-') && chomp == 0:
+			chomp = c
+		case c >= '1' && c <= '9' && explicit == 0:
+			explicit = int(c - '0')
+		default:
+			goto header
+		}
+		y.pos++
+	}
+header:
+	if !y.lineRestEmpty() {
+		return nil, y.errorAt(y.pos, "invalid block scalar header")
+	}
+	y.skipLine()
+	if !y.eof() {
+		y.pos++
+	}
+
+	contentIndent := -1
+	if explicit > 0 {
+		contentIndent = max(indent, 0) + explicit
+	}
+	var lines [][]byte
+	for !y.eof() {
+		end := bytes.IndexByte(y.src[y.pos:], '\n')
+		lineEnd := len(y.src)
+		if end >= 0 {
+			lineEnd = y.pos + end
+		}
+		line := bytes.TrimRight(y.src[y.pos:lineEnd], "\r")
+		spaces := 0
+		for spaces < len(line) && line[spaces] == ' ' {
+			spaces++
+		}
+		if spaces == len(line) {
+			if contentIndent >= 0 && spaces > contentIndent {
+				lines = append(lines, line[contentIndent:])
+			} else {
+				lines = append(lines, nil)
+			}
+		} else {
+			if contentIndent < 0 {
+				if spaces <= indent {
+					break
+				}
+				contentIndent = spaces
+			}
+			if spaces < contentIndent {
+				break
+			}
+			lines = append(lines, line[contentIndent:])
+		}
+		y.pos = lineEnd
+		if y.pos < len(y.src) {
+			y.pos++
+		}
+	}
+	// Leave the position on the line break before the next token so the
+	// caller sees the following line's indentation.
+	if y.pos > 0 && y.pos <= len(y.src) && y.src[y.pos-1] == '\n' {
+		y.pos--
+	}
+
+	last := len(lines)
+	for last > 0 && len(lines[last-1]) == 0 {
+		last--
+	}
+	trailing := len(lines) - last
+	var b []byte
+	prevMore := false
+	empty := 0
+	for i, line := range lines[:last] {
+		if len(line) == 0 {
+			empty++
+			continue
+		}
+		more := line[0] == ' ' || line[0] == '\t'
+		if i > 0 {
+			switch {
+			case literal:
+				b = append(b, '\n')
+				for range empty {
+					b = append(b, '\n')
+				}
+			case !more && !prevMore && empty == 0:
+				b = append(b, ' ')
+			case !more && !prevMore:
+				for range empty {
+					b = append(b, '\n')
+				}
+			default:
+				b = append(b, '\n')
+				for range empty {
+					b = append(b, '\n')
+				}
+			}
+		} else {
+			for range empty {
+				b = append(b, '\n')
+			}
+		}
+		b = append(b, line...)
+		empty = 0
+		prevMore = more
+	}
+	switch chomp {
+	case '-':
+	case '+':
+		if last > 0 {
+			b = append(b, '\n')
+		}
+		for range trailing {
+			b = append(b, '\n')
+		}
+	default:
+		if last > 0 {
+			b = append(b, '\n')
+		}
+	}
+	return &inputNode{kind: inputString, text: string(b)}, nil
+}
+
+func isFlowIndicator(c byte) bool {
+	return c == ',' || c == '[' || c == ']' || c == '{' || c == '}'
+}
+
+func (y *yamlParser) parseFlow() (*inputNode, error) {
+	open := y.src[y.pos]
+	start := y.pos
+	y.pos++
+	node := &inputNode{kind: inputArray}
+	closing := byte(']')
+	if open == '{' {
+		node.kind = inputObject
+		closing = '}'
+	}
+	for {
+		y.skipSpace()
+		if y.eof() {
+			return nil, y.errorAt(start, "unterminated flow collection")
+		}
+		if y.peek() == closing {
+			y.pos++
+			return node, nil
+		}
+		key, err := y.parseFlowNode()
+		if err != nil {
+			return nil, err
+		}
+		y.skipSpace()
+		var val *inputNode
+		if y.peek() == ':' {
+			y.pos++
+			y.skipSpace()
+			if c := y.peek(); c == ',' || c == closing {
+				val = &inputNode{}
+			} else if val, err = y.parseFlowNode(); err != nil {
+				return nil, err
+			}
+			y.skipSpace()
+		}
+		switch {
+		case node.kind == inputObject:
+			if key.kind == inputObject || key.kind == inputArray {
+				return nil, y.errorAt(y.pos, "unsupported complex key")
+			}
+			if val == nil {
+				val = &inputNode{}
+			}
+			node.set(key.text, val)
+		case val != nil:
+			pair := &inputNode{kind: inputObject}
+			pair.set(key.text, val)
+			node.items = append(node.items, pair)
+		default:
+			node.items = append(node.items, key)
+		}
+		switch y.peek() {
+		case ',':
+			y.pos++
+		case closing:
+		case 0:
+			return nil, y.errorAt(start, "unterminated flow collection")
+		default:
+			return nil, y.errorAt(y.pos, "expected ',' or '"+string(closing)+"'")
+		}
+	}
+}
+
+func (y *yamlParser) parseFlowNode() (*inputNode, error) {
+	anchor, tag, err := y.parseProperties()
+	if err != nil {
+		return nil, err
+	}
+	var node *inputNode
+	switch c := y.peek(); c {
+	case '[', '{':
+		node, err = y.parseFlow()
+	case '*':
+		node, err = y.parseAlias(true)
+	case '"', '\'':
+		var s string
+		s, err = y.parseQuoted()
+		node = &inputNode{kind: inputString, text: s}
+	default:
+		s := y.parseFlowPlain()
+		if s == "" {
+			return nil, y.errorAt(y.pos, "expected flow node")
+		}
+		if tag != "" {
+			node = &inputNode{kind: inputString, text: s}
+		} else {
+			node = resolveYAMLScalar(s)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	node = applyYAMLTag(node, tag)
+	if anchor != "" {
+		if y.anchors == nil {
+			y.anchors = make(map[string]*inputNode)
+		}
+		y.anchors[anchor] = node
+	}
+	return node, nil
+}
+
+// parseFlowPlain reads a plain scalar inside a flow collection, where it
+// ends at a flow indicator or ": ".
+func (y *yamlParser) parseFlowPlain() string {
+	var b []byte
+	for !y.eof() {
+		c := y.src[y.pos]
+		if isFlowIndicator(c) || (c == ':' && (isYAMLBreak(y.peekAt(y.pos+1)) || isFlowIndicator(y.peekAt(y.pos+1)))) {
+			break
+		}
+		if c == '#' && y.pos > 0 && isYAMLBreak(y.src[y.pos-1]) {
+			break
+		}
+		if c == '\n' || c == '\r' || c == '\t' {
+			c = ' '
+		}
+		if c != ' ' || (len(b) > 0 && b[len(b)-1] != ' ') {
+			b = append(b, c)
+		}
+		y.pos++
+	}
+	return string(bytes.TrimRight(b, " "))
+}
+
+// resolveYAMLScalar resolves a plain scalar with the YAML 1.2 core schema.
+// .inf becomes ±1.7976931348623157e+308 and .nan null, as for JSON5.
+func resolveYAMLScalar(s string) *inputNode {
+	switch s {
+	case "", "~", "null", "Null", "NULL":
+		return &inputNode{}
+	case "true", "True", "TRUE", "false", "False", "FALSE":
+		return &inputNode{kind: inputBool, text: strings.ToLower(s)}
+	case ".nan", ".NaN", ".NAN":
+		return &inputNode{}
+	}
+	t := strings.TrimLeft(s, "+-")
+	if len(s)-len(t) <= 1 {
+		switch t {
+		case ".inf", ".Inf", ".INF":
+			num := maxFloat64Text
+			if s[0] == '-' {
+				num = "-" + num
+			}
+			return &inputNode{kind: inputNumber, text: num}
+		}
+		if isYAMLNumber(s) {
+			if num, ok := appendJSONNumber(nil, s); ok {
+				return &inputNode{kind: inputNumber, text: string(num)}
+			}
+		}
+	}
+	return &inputNode{kind: inputString, text: s}
+}
+
+// isYAMLNumber matches the core schema int and float forms, including 0o
+// and 0x integers.
+func isYAMLNumber(s string) bool {
+	if len(s) > 2 && s[0] == '0' && (s[1] == 'o' || s[1] == 'x') {
+		for _, c := range []byte(s[2:]) {
+			if !(c >= '0' && c <= '7') && !(s[1] == 'x' && isHex(c)) {
+				return false
+			}
+		}
+		return true
+	}
+	if s[0] == '+' || s[0] == '-' {
+		s = s[1:]
+	}
+	digits, i := 0, 0
+	for ; i < len(s) && s[i] >= '0' && s[i] <= '9'; i++ {
+		digits++
+	}
+	if i < len(s) && s[i] == '.' {
+		for i++; i < len(s) && s[i] >= '0' && s[i] <= '9'; i++ {
+			digits++
+		}
+	}
+	if digits == 0 {
+		return false
+	}
+	if i < len(s) && (s[i] == 'e' || s[i] == 'E') {
+		i++
+		if i < len(s) && (s[i] == '+' || s[i] == '-') {
+			i++
+		}
+		exp := i
+		for ; i < len(s) && s[i] >= '0' && s[i] <= '9'; i++ {
+		}
+		if i == exp {
+			return false
+		}
+	}
+	return i == len(s)
+}
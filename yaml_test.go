@@ -0,0 +1,178 @@
+package prettyx
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestYAML(t *testing.T) {
+	input := `{"kind":"Pod","metadata":{"name":"x","labels":{"a: b":"yes"},"annotations":{}},"spec":{"containers":[{"name":"c","args":["-v",""],"env":[]}],"nested":[[1,2],[{"a":1,"b":[]}]],"n":null,"t":true},"text":"one\ntwo\n","keep":"a\n\n","strip":"a\nb","lead":" a\nb","ver":"1.2.3"}
+"scalar"
+[]`
+	want := `kind: Pod
+metadata:
+  name: x
+  labels:
+    "a: b": "yes"
+  annotations: {}
+spec:
+  containers:
+  - name: c
+    args:
+    - "-v"
+    - ""
+    env: []
+  nested:
+  - - 1
+    - 2
+  - - a: 1
+      b: []
+  "n": null
+  t: true
+text: |
+  one
+  two
+keep: |+
+  a
+
+strip: |-
+  a
+  b
+lead: " a\nb"
+ver: "1.2.3"
+---
+scalar
+---
+[]
+`
+	opts := *DefaultOptions
+	opts.Palette = "none"
+	opts.Output = OutputYAML
+	var buf bytes.Buffer
+	if err := PrettyStream(&buf, strings.NewReader(input), &opts); err != nil {
+		t.Fatalf("PrettyStream failed: %v", err)
+	}
+	if buf.String() != want {
+		t.Fatalf("unexpected output\nexpected:\n%s\nactual:\n%s", want, buf.String())
+	}
+}
+
+func TestYAML_UnwrapSorted(t *testing.T) {
+	opts := *DefaultOptions
+	opts.Palette = "none"
+	opts.Output = OutputYAML
+	opts.Unwrap = true
+	opts.SortKeys = KeyOrderBytes
+	opts.Prefix = "> "
+	var buf bytes.Buffer
+	if err := PrettyStream(&buf, strings.NewReader(`{"z":"{\"b\":[1,{\"y\":\"x\\ny\"}],\"a\":{}}","c":1}`), &opts); err != nil {
+		t.Fatalf("PrettyStream failed: %v", err)
+	}
+	want := `> c: 1
+> z:
+>   a: {}
+>   b:
+>   - 1
+>   - "y": |-
+>       x
+>       y
+`
+	if buf.String() != want {
+		t.Fatalf("unexpected output\nexpected:\n%s\nactual:\n%s", want, buf.String())
+	}
+}
+
+func TestYAML_ColorAndRepair(t *testing.T) {
+	opts := *DefaultOptions
+	opts.ForceColor = true
+	opts.Output = OutputYAML
+	opts.Repair = true
+	pal, err := resolvePalette(&opts, true)
+	if err != nil {
+		t.Fatalf("resolvePalette failed: %v", err)
+	}
+	out, err := Pretty([]byte(`{'a': [1, True], b: `), &opts)
+	if err != nil {
+		t.Fatalf("Pretty failed: %v", err)
+	}
+	want := pal.Key + "a\x1b[0m" + pal.Punctuation + ":\x1b[0m\n" +
+		pal.Punctuation + "- \x1b[0m" + pal.Number + "1\x1b[0m\n" +
+		pal.Punctuation + "- \x1b[0m" + pal.True + "true\x1b[0m\n" +
+		pal.Key + "b\x1b[0m" + pal.Punctuation + ":\x1b[0m " + pal.Null + "null\x1b[0m\n"
+	if string(out) != want {
+		t.Fatalf("unexpected output\nexpected: %q\nactual:   %q", want, out)
+	}
+}
+
+func TestYAMLNeedsQuotes(t *testing.T) {
+	for s, want := range map[string]bool{
+		"plain":         false,
+		"with space":    false,
+		"key:value":     false,
+		"a#b":           false,
+		"ünïcode":       false,
+		"":              true,
+		" lead":         true,
+		"trail ":        true,
+		"ends:":         true,
+		"a: b":          true,
+		"a #b":          true,
+		"-dash":         true,
+		"*alias":        true,
+		"~":             true,
+		"123":           true,
+		"1.5":           true,
+		"2024-01-01":    true,
+		".5":            true,
+		"+1":            true,
+		"True":          true,
+		"NO":            true,
+		"y":             true,
+		"Null":          true,
+		".inf":          true,
+		"tab\there":     true,
+		"line\nbreak":   true,
+		"\xff\xfe":      true,
+		"yesterday":     false,
+		"<<":            true,
+		"@handle":       true,
+		"`cmd`":         true,
+		"100%":          true,
+		"x100%":         false,
+		"question?":     false,
+		"{not a map}":   true,
+		"[not a list]":  true,
+		"'single'":      true,
+		"\"double\"":    true,
+		"!tag":          true,
+		"&anchor":       true,
+		"|pipe":         true,
+		">fold":         true,
+		"%directive":    true,
+		",comma":        true,
+		"?question":     true,
+		":colon":        true,
+		"=equals":       true,
+		"#comment":      true,
+		"nul\x00":       true,
+		"del\x7f":       true,
+		"off":           true,
+		"On":            true,
+		"n":             true,
+		"false":         true,
+		".nan":          true,
+		".hidden":       false,
+		"x.5":           false,
+		"v1.2":          false,
+		"-":             true,
+		"a-b":           false,
+		"http://x.y/z":  false,
+		"C:\\path":      false,
+		"spaces inside": false,
+	} {
+		if got := yamlNeedsQuotes([]byte(s)); got != want {
+			t.Fatalf("yamlNeedsQuotes(%q) = %v, want %v", s, got, want)
+		}
+	}
+}